@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Acknowledged alerts stop repeating this long unless the ack names a
+// period of its own
+const defaultAckPeriod = time.Hour
+
+// Short ID of an alert in /alerts paths, the same across restarts
+func alertID(key string) string {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// Holds the repeat notifications of a firing alert for period and
+// acknowledges its PagerDuty incident. A recovery still goes out
+func (a *alerter) acknowledge(id string, period time.Duration) (alertEntry, bool) {
+	now := time.Now()
+	a.inUse.Lock()
+	var entry *activeAlert
+	for key, candidate := range a.active {
+		if alertID(key) == id {
+			entry = candidate
+			break
+		}
+	}
+	if entry == nil {
+		a.inUse.Unlock()
+		return alertEntry{}, false
+	}
+	until := now.Add(period)
+	entry.AckedUntil = until
+	services := []string{}
+	if entry.Notified {
+		for service := range entry.services {
+			services = append(services, service)
+		}
+		if entry.Escalated && a.escalation.EventServiceKey != "" {
+			services = append(services, a.escalation.EventServiceKey)
+		}
+	}
+	dedup, about := a.incidentKey(entry), entry.alert
+	acked := entryOf(entry)
+	a.inUse.Unlock()
+	stdlog.Printf("[alerter] Acknowledged %s until %s", acked.Key, until.UTC().Format(time.RFC3339))
+	for _, service := range services {
+		if err := a.notifyAcknowledge(service, dedup, about); err != nil {
+			errlog.Print(err)
+		}
+	}
+	return acked, true
+}
+
+// POST /alerts-<chain>/<id>/ack, for is an optional period such as 30m.
+// Only served with the admin basic-auth set
+func (m *monitor) ackAlertJSON(auth basicAuthParams, chain string) http.Handler {
+	ack := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/alerts-"+chain+"/"), "/")
+		if len(parts) != 2 || parts[1] != "ack" {
+			http.NotFound(w, req)
+			return
+		}
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		period := m.alerts.ackPeriod
+		if p := req.URL.Query().Get("for"); p != "" {
+			d, err := time.ParseDuration(p)
+			if err != nil || d <= 0 {
+				http.Error(w, "invalid ack period "+p, http.StatusBadRequest)
+				return
+			}
+			period = d
+		}
+		acked, exists := m.alerts.acknowledge(parts[0], period)
+		if !exists {
+			http.Error(w, "Unknown alert "+parts[0], http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(acked)
+	})
+	if auth.Username == "" {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			http.Error(w, "acknowledging alerts needs http-reporter.admin.basic-auth", http.StatusForbidden)
+		})
+	}
+	return requireBasicAuth(ack, auth)
+}
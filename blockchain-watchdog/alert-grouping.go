@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Alerts of a shard that started firing within one group-window, held
+// back until the window closes
+type alertGroup struct {
+	keys  []string
+	start time.Time
+}
+
+// Expects a.inUse to be held, true when the alert was held back
+func (a *alerter) holdForGroup(entry *activeAlert, now time.Time) bool {
+	if a.groupWindow == 0 {
+		return false
+	}
+	shard := entry.Shard
+	group, exists := a.groups[shard]
+	if !exists {
+		group = &alertGroup{start: now}
+		a.groups[shard] = group
+		time.AfterFunc(a.groupWindow, func() { a.flushGroup(shard) })
+	}
+	group.keys = append(group.keys, entry.Key)
+	entry.grouping = true
+	return true
+}
+
+// A lone alert goes out as usual, several as one incident listing all of
+// the failing checks that their later updates and recovery also go to
+func (a *alerter) flushGroup(shard string) {
+	now := time.Now()
+	a.inUse.Lock()
+	group, exists := a.groups[shard]
+	delete(a.groups, shard)
+	// Already flushed by shutdown
+	if !exists {
+		a.inUse.Unlock()
+		return
+	}
+	members := []*activeAlert{}
+	for _, key := range group.keys {
+		if entry, exists := a.active[key]; exists && entry.grouping {
+			entry.grouping = false
+			members = append(members, entry)
+		}
+	}
+	if len(members) == 0 {
+		a.inUse.Unlock()
+		return
+	}
+	if len(members) == 1 {
+		entry := members[0]
+		entry.LastSent, entry.Notified = now, true
+		al, escalated, firstSeen := entry.alert, entry.Escalated, entry.FirstSeen
+		service := a.serviceFor(al)
+		entry.sentTo(service)
+		a.inUse.Unlock()
+		subject, body := a.templates.render(al, now)
+		if err := a.send(service, a.dedupKey(al), subject, body, al, escalated, firstSeen); err != nil {
+			errlog.Print(err)
+		}
+		return
+	}
+	sort.SliceStable(members, func(i, j int) bool { return members[i].Key < members[j].Key })
+	label := "shard " + shard
+	if shard == "" {
+		label = "network"
+	}
+	key := fmt.Sprintf("%d alerts grouped on %s at %s - %s",
+		len(members), label, group.start.UTC().Format(time.RFC3339), a.chain,
+	)
+	severity, escalated := severityInfo, false
+	checks, services := []string{}, []string{}
+	for _, entry := range members {
+		if s := a.serviceFor(entry.alert); !containsString(services, s) {
+			services = append(services, s)
+		}
+	}
+	var lines strings.Builder
+	for _, entry := range members {
+		// The incident is resolved on every service once the last of them clears
+		for _, s := range services {
+			entry.sentTo(s)
+		}
+		entry.Group = key
+		entry.LastSent, entry.Notified = now, true
+		if severityRank[entry.Severity] > severityRank[severity] {
+			severity = entry.Severity
+		}
+		escalated = escalated || entry.Escalated
+		checks = append(checks, entry.Check)
+		fmt.Fprintf(&lines, "  [%s] %s: %s\n", entry.Severity, entry.Check, entry.Key)
+	}
+	a.inUse.Unlock()
+	summary := fmt.Sprintf("%d alerts on %s (%s) - %s", len(members), label, strings.Join(checks, ", "), a.chain)
+	body := fmt.Sprintf(groupedAlertMessage, len(members), label, a.groupWindow, lines.String(), a.chain)
+	about := alert{Key: key, Chain: a.chain, Shard: shard, Check: strings.Join(checks, ","), Severity: severity}
+	// To each service a member is routed to
+	for _, service := range services {
+		if err := a.send(service, key, summary, body, about, escalated, group.start); err != nil {
+			errlog.Print(err)
+		} else {
+			stdlog.Printf("[alerter] Queued alert grouping %d alerts! %s", len(members), key)
+		}
+	}
+}
+
+func (a *alerter) flushGroups() {
+	a.inUse.Lock()
+	shards := []string{}
+	for shard := range a.groups {
+		shards = append(shards, shard)
+	}
+	a.inUse.Unlock()
+	for _, shard := range shards {
+		a.flushGroup(shard)
+	}
+}
+
+// Incident an alert updates and resolves, the group it went out in if any.
+// Expects a.inUse to be held
+func (a *alerter) incidentKey(entry *activeAlert) string {
+	if entry.Group != "" {
+		return entry.Group
+	}
+	return a.dedupKey(entry.alert)
+}
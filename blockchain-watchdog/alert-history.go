@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Oldest resolved alerts are dropped past this many
+const recentAlertsSize = 50
+
+type alertEntry struct {
+	ID        string `json:"id"`
+	Key       string `json:"key"`
+	Shard     string `json:"shard"`
+	Check     string `json:"check"`
+	Severity  string `json:"severity"`
+	Value     string `json:"value"`
+	Threshold string `json:"threshold"`
+	FirstSeen string `json:"first-seen"`
+	Escalated bool   `json:"escalated"`
+	Notified  bool   `json:"notified"`
+	Breaches  int    `json:"checks-in-breach"`
+	// Empty unless acknowledged
+	AckedUntil string `json:"acked-until,omitempty"`
+	Suppressed bool   `json:"suppressed,omitempty"`
+	RolledUp   bool   `json:"rolled-up,omitempty"`
+	// Open maintenance window covering the alert, zero when none
+	Maintenance int `json:"maintenance,omitempty"`
+}
+
+type resolvedAlert struct {
+	alertEntry
+	Resolved string  `json:"resolved"`
+	Duration float64 `json:"duration-seconds"`
+}
+
+type alertsReport struct {
+	Active []alertEntry    `json:"active"`
+	Recent []resolvedAlert `json:"recently-resolved"`
+}
+
+func entryOf(a *activeAlert) alertEntry {
+	acked := ""
+	if time.Now().Before(a.AckedUntil) {
+		acked = a.AckedUntil.UTC().Format(time.RFC3339)
+	}
+	return alertEntry{
+		alertID(a.Key), a.Key, a.Shard, a.Check, a.Severity, a.Value, a.Threshold,
+		a.FirstSeen.UTC().Format(time.RFC3339), a.Escalated, a.Notified, a.Breaches, acked, a.Suppressed, a.RolledUp, 0,
+	}
+}
+
+// Expects a.inUse to be held
+func (a *alerter) recordResolved(entry *activeAlert, now time.Time) {
+	a.resolved = append(a.resolved, resolvedAlert{
+		entryOf(entry), now.UTC().Format(time.RFC3339), now.Sub(entry.FirstSeen).Seconds(),
+	})
+	if len(a.resolved) > recentAlertsSize {
+		a.resolved = a.resolved[len(a.resolved)-recentAlertsSize:]
+	}
+}
+
+// Active alerts oldest first, resolved ones newest first
+func (a *alerter) report() alertsReport {
+	a.inUse.Lock()
+	r := alertsReport{[]alertEntry{}, make([]resolvedAlert, 0, len(a.resolved))}
+	now := time.Now()
+	for _, entry := range a.active {
+		e := entryOf(entry)
+		if window := a.maintenance.covering(entry.alert, now); window != nil {
+			e.Maintenance = window.ID
+		}
+		r.Active = append(r.Active, e)
+	}
+	for i := len(a.resolved) - 1; i >= 0; i-- {
+		r.Recent = append(r.Recent, a.resolved[i])
+	}
+	a.inUse.Unlock()
+	sort.SliceStable(r.Active, func(i, j int) bool {
+		if r.Active[i].FirstSeen != r.Active[j].FirstSeen {
+			return r.Active[i].FirstSeen < r.Active[j].FirstSeen
+		}
+		return r.Active[i].Key < r.Active[j].Key
+	})
+	return r
+}
+
+func (m *monitor) alertsJSON(w http.ResponseWriter, req *http.Request) {
+	json.NewEncoder(w).Encode(m.alerts.report())
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PagerDuty Events API v2 severities, lowest first
+const (
+	severityInfo     = "info"
+	severityWarning  = "warning"
+	severityError    = "error"
+	severityCritical = "critical"
+)
+
+var severityRank = map[string]int{
+	severityInfo:     0,
+	severityWarning:  1,
+	severityError:    2,
+	severityCritical: 3,
+}
+
+// How alerts are grouped into PagerDuty incidents, per-check opens one
+// incident per alert, per-shard one per shard and per-network a single one
+const (
+	dedupPerCheck   = "per-check"
+	dedupPerShard   = "per-shard"
+	dedupPerNetwork = "per-network"
+)
+
+var dedupStrategies = map[string]bool{
+	dedupPerCheck:   true,
+	dedupPerShard:   true,
+	dedupPerNetwork: true,
+}
+
+// After is assumed as seconds, zero disables escalation
+type escalationParams struct {
+	After           seconds `yaml:"after"`
+	Severity        string  `yaml:"severity"`
+	EventServiceKey string  `yaml:"event-service-key"`
+}
+
+// Value and Threshold are only used by message templates
+type alert struct {
+	Key       string
+	Chain     string
+	Shard     string
+	Check     string
+	Message   string
+	Severity  string
+	Value     string
+	Threshold string
+	// Addresses of the nodes the alert is about, where the check knows them
+	Nodes []string
+	// Channel a delivery alert is about, it is never sent through it
+	channel string
+	// When the breach was first seen, set by trigger
+	firstSeen time.Time
+}
+
+// Notified is false while the alert only went out in digests
+type activeAlert struct {
+	alert
+	FirstSeen time.Time
+	LastSent  time.Time
+	Escalated bool
+	Notified  bool
+	// Checks in a row that found it in breach, see page-after-checks
+	Breaches int
+	// Until then repeats are held, see acknowledge
+	AckedUntil time.Time
+	// About suppressed nodes only, see suppress
+	Suppressed bool
+	// Past a rate limit when it started firing, only sent in the rollup
+	RolledUp bool
+	// Held back for the next digest
+	digesting bool
+	// Incident of the alert group it went out in, see group-window
+	Group    string
+	grouping bool
+	// Messengers it was posted to, which then get its recovery
+	posted map[*messenger]bool
+	// PagerDuty services its incident went to, see routing
+	services map[string]bool
+}
+
+// Expects the lock to be held
+func (entry *activeAlert) sentTo(service string) {
+	if entry.services == nil {
+		entry.services = map[string]bool{}
+	}
+	entry.services[service] = true
+}
+
+// Tracks which alerts are currently firing so that a check clearing
+// can be told apart from a check that never fired
+type alerter struct {
+	inUse            sync.Mutex
+	serviceKey       string
+	severity         string
+	notifyOnRecovery bool
+	// PagerDuty incidents are resolved once their alerts clear, true unless
+	// auto-resolve is false
+	autoResolve bool
+	escalation  escalationParams
+	templates   *alertTemplates
+	active      map[string]*activeAlert
+	resolved    []resolvedAlert
+	chain       string
+	digest      time.Duration
+	dedup       string
+	// Whether a digested alert breached since the last digest was sent
+	pending    bool
+	digestOpen bool
+	stopDigest chan struct{}
+	digestDone chan struct{}
+	// Told the shard and check of an alert when it starts firing, changes
+	// severity or clears, with the highest severity of the check still firing
+	onChange func(shard, check, severity string)
+	// Whether alerts about the node are suppressed, nil suppresses none
+	suppresses func(address string) bool
+	// Every PagerDuty event goes through it
+	pager *deliveryQueue
+	// New alerts of a shard are held this long to go out as one
+	groupWindow time.Duration
+	groups      map[string]*alertGroup
+	// See retrigger-interval
+	retrigger time.Duration
+	// See page-after-checks, at least 1
+	pageAfter int
+	ackPeriod time.Duration
+	// Alerts are only logged, see monitor --dry-run
+	dryRun bool
+	// Alerts below it are tracked but never sent to PagerDuty
+	minSeverity string
+	// Keyed by channel, see delivered
+	deliveries       map[string]*channelDeliveries
+	deliveryFailures int
+	// Chat channels beside PagerDuty, see messenger
+	messengers  []*messenger
+	routing     alertRoutes
+	maintenance *maintenanceSchedule
+	// See rate-limit, the rollup is nil unless open
+	limiter       *rateLimiter
+	rollup        *activeAlert
+	rollupPending bool
+	stopRollup    chan struct{}
+	rollupDone    chan struct{}
+	// Of every event handed to a delivery queue, nil when not set
+	audit *auditLog
+}
+
+func newAlerter(params watchParams, audit *auditLog) *alerter {
+	a := &alerter{
+		serviceKey:       params.Auth.PagerDuty.EventServiceKey,
+		severity:         params.Alerting.Severity,
+		notifyOnRecovery: params.Alerting.NotifyOnRecovery,
+		autoResolve:      params.Alerting.AutoResolve == nil || *params.Alerting.AutoResolve,
+		escalation:       params.ShardHealthReporting.Escalation,
+		active:           map[string]*activeAlert{},
+		chain:            params.Network.TargetChain,
+		digest:           params.Alerting.Digest.PagerDuty.Interval.duration(),
+		dedup:            params.Alerting.DedupStrategy,
+		stopDigest:       make(chan struct{}),
+		digestDone:       make(chan struct{}),
+		groupWindow:      params.Alerting.GroupWindow.duration(),
+		groups:           map[string]*alertGroup{},
+		retrigger:        params.Alerting.RetriggerInterval.duration(),
+		pageAfter:        params.Alerting.PageAfterChecks,
+		ackPeriod:        params.Alerting.AckPeriod.duration(),
+		minSeverity:      params.Alerting.MinSeverity.PagerDuty,
+		deliveries:       map[string]*channelDeliveries{},
+		deliveryFailures: params.Alerting.DeliveryFailures,
+		routing:          params.Alerting.Routing,
+		maintenance:      newMaintenanceSchedule(params.Alerting.Maintenance),
+		limiter:          newRateLimiter(params.Alerting.RateLimit),
+		stopRollup:       make(chan struct{}),
+		rollupDone:       make(chan struct{}),
+		audit:            audit,
+	}
+	a.pager = newDeliveryQueue("pagerduty", a.audit, func(err error) { a.delivered("pagerduty", err) })
+	if s := params.Auth.Slack; s.WebhookURL != "" {
+		a.addMessenger("slack", params.Alerting.Templates.Slack, params.Alerting.MinSeverity.Slack,
+			&slackSender{s, params.Alerting.Routing, &http.Client{Timeout: chatTimeout}},
+		)
+	}
+	if t := params.Auth.Telegram; t.BotToken != "" {
+		a.addMessenger("telegram", params.Alerting.Templates.Telegram, params.Alerting.MinSeverity.Telegram,
+			&telegramSender{t, &http.Client{Timeout: chatTimeout}},
+		)
+	}
+	if d := params.Auth.Discord; d.WebhookURL != "" {
+		a.addMessenger("discord", params.Alerting.Templates.Discord, params.Alerting.MinSeverity.Discord,
+			&discordSender{d, &http.Client{Timeout: chatTimeout}},
+		)
+	}
+	if o := params.Auth.OpsGenie; o.APIKey != "" {
+		a.addMessenger("opsgenie", params.Alerting.Templates.OpsGenie, params.Alerting.MinSeverity.OpsGenie,
+			&opsGenieSender{o, &http.Client{Timeout: chatTimeout}},
+		)
+	}
+	if t := params.Auth.Teams; t.WebhookURL != "" {
+		a.addMessenger("teams", params.Alerting.Templates.Teams, params.Alerting.MinSeverity.Teams,
+			&teamsSender{t, &http.Client{Timeout: chatTimeout}},
+		)
+	}
+	if e := params.Auth.Email; e.Host != "" {
+		a.addMessenger("email", params.Alerting.Templates.Email, params.Alerting.MinSeverity.Email, &emailSender{e})
+	}
+	// Texts page whoever is on call, so never for less than critical
+	for _, to := range params.Auth.Twilio.To {
+		a.addMessenger("twilio", params.Alerting.Templates.Twilio, severityCritical,
+			&twilioSender{params.Auth.Twilio, to, &http.Client{Timeout: chatTimeout}},
+		)
+	}
+	for _, u := range params.Auth.Webhook.URLs {
+		a.addMessenger("webhook", params.Alerting.Templates.Webhook, params.Alerting.MinSeverity.Webhook,
+			&webhookSender{u, params.Auth.Webhook.Headers, &http.Client{Timeout: chatTimeout}},
+		)
+	}
+	if a.severity == "" {
+		a.severity = severityCritical
+	}
+	if a.escalation.Severity == "" {
+		a.escalation.Severity = severityCritical
+	}
+	if a.deliveryFailures == 0 {
+		a.deliveryFailures = defaultDeliveryFailures
+	}
+	if a.pageAfter == 0 {
+		a.pageAfter = 1
+	}
+	if a.ackPeriod == 0 {
+		a.ackPeriod = defaultAckPeriod
+	}
+	// Already validated by sanityCheck
+	a.templates, _ = parseTemplates("pagerduty", params.Alerting.Templates.PagerDuty)
+	if a.digest > 0 {
+		go a.digestLoop()
+	}
+	if a.limiter.enabled() {
+		go a.rollupLoop()
+	}
+	return a
+}
+
+// PagerDuty dedup key of the incident the alert goes to, alerts without a
+// shard are grouped per network under per-shard
+func (a *alerter) dedupKey(al alert) string {
+	switch {
+	case a.dedup == dedupPerShard && al.Shard != "":
+		return fmt.Sprintf("Shard %s - %s", al.Shard, a.chain)
+	case a.dedup == dedupPerShard, a.dedup == dedupPerNetwork:
+		return fmt.Sprintf("Network - %s", a.chain)
+	}
+	return al.Key
+}
+
+// At or above the PagerDuty min-severity, which defaults to all, and not
+// about PagerDuty delivery failing
+func (a *alerter) routes(al alert) bool {
+	return severityRank[al.Severity] >= severityRank[a.minSeverity] && al.channel != a.pager.name
+}
+
+func (a *alerter) escalates(entry *activeAlert, now time.Time) bool {
+	return a.escalation.After > 0 &&
+		now.Sub(entry.FirstSeen) > a.escalation.After.duration()
+}
+
+func (a *alerter) trigger(al alert) error {
+	if al.Severity == "" {
+		al.Severity = a.severity
+	}
+	now := time.Now()
+	// Looked up before taking the lock, it takes the monitor's
+	suppressed := a.suppressed(al)
+	a.inUse.Lock()
+	entry, exists := a.active[al.Key]
+	if !exists {
+		entry = &activeAlert{alert: al, FirstSeen: now}
+		a.active[al.Key] = entry
+	}
+	newlyEscalated := false
+	if !entry.Escalated && a.escalates(entry, now) {
+		entry.Escalated, newlyEscalated = true, true
+		withFields(stdlog, alertFields(al)).Printf("[alerter] Escalating %s to %s after %s in breach",
+			al.Key, a.escalation.Severity, now.Sub(entry.FirstSeen).Round(time.Second),
+		)
+	}
+	escalated := entry.Escalated
+	if escalated {
+		al.Severity = a.escalation.Severity
+	}
+	// Past a higher tier of its check, e.g. from warning to critical, it
+	// goes out again like an escalation
+	raised := exists && severityRank[al.Severity] > severityRank[entry.Severity]
+	changed := !exists || al.Severity != entry.Severity
+	if raised {
+		withFields(stdlog, alertFields(al)).Printf("[alerter] Raising %s from %s to %s", al.Key, entry.Severity, al.Severity)
+	}
+	al.firstSeen = entry.FirstSeen
+	entry.alert = al
+	entry.Breaches++
+	entry.Suppressed = suppressed
+	// Chat channels get it right away, PagerDuty once it persisted for
+	// page-after-checks. Until then it is only posted
+	staged := entry.Breaches < a.pageAfter
+	firstPage := entry.Breaches == a.pageAfter
+	// Tracked as firing during maintenance, it goes out as new once the
+	// window ends with the check still in breach
+	window := a.maintenance.covering(al, now)
+	// Counted against the rate limits as it starts firing, past them it
+	// only goes out in the rollup until it clears. Delivery alerts never are
+	if !exists && window == nil && !suppressed && al.channel == "" && !(a.routes(al) && a.digested(al)) &&
+		a.limiter.enabled() && !a.limiter.allow(al.Check, now) {
+		entry.RolledUp = true
+		a.rollupPending = true
+	}
+	rolled := entry.RolledUp
+	routed := a.routes(al) && window == nil && !staged && !suppressed && !rolled
+	digested := routed && a.digested(al)
+	entry.digesting = digested
+	held := entry.grouping || (routed && firstPage && !digested && a.holdForGroup(entry, now))
+	service := a.serviceFor(al)
+	// Its incident is still open under the same key, a repeat within
+	// retrigger-interval only adds noise to the incident's log. Nothing
+	// repeats while acknowledged, escalation included
+	acked := now.Before(entry.AckedUntil)
+	repeat := exists && entry.Notified && entry.services[service] &&
+		(acked || !newlyEscalated && !raised && now.Sub(entry.LastSent) < a.retrigger)
+	if digested {
+		a.pending = true
+	} else if routed && !held && !repeat {
+		entry.LastSent = now
+		entry.Notified = true
+	}
+	if routed && !digested && !held && !repeat {
+		entry.sentTo(service)
+	}
+	posts := []*messenger{}
+	if window == nil && !acked && !suppressed && !rolled {
+		posts = a.duePosts(entry, al, newlyEscalated || raised)
+	}
+	dedup := a.incidentKey(entry)
+	firstSeen := entry.FirstSeen
+	checkSeverity := a.firingSeverity(al.Shard, al.Check)
+	a.inUse.Unlock()
+	if changed && a.onChange != nil {
+		a.onChange(al.Shard, al.Check, checkSeverity)
+	}
+	for _, m := range posts {
+		a.postAlert(m, al, now)
+	}
+	logger := withFields(stdlog, alertFields(al))
+	if rolled && !exists {
+		logger.Printf("[alerter] Rate limit reached, %s alert goes out in the rollup: %s", al.Severity, al.Key)
+	} else if suppressed && !exists {
+		logger.Printf("[alerter] Not sending %s alert about suppressed nodes %v: %s", al.Severity, al.Nodes, al.Key)
+	} else if window != nil && !exists {
+		logger.Printf("[alerter] Not sending %s alert during maintenance window %d: %s", al.Severity, window.ID, al.Key)
+	} else if staged && !exists && a.routes(al) {
+		logger.Printf("[alerter] Holding PagerDuty until %d checks in a row found %s in breach", a.pageAfter, al.Key)
+	} else if firstPage && exists && routed {
+		logger.Printf("[alerter] Paging after %d checks in a row in breach: %s", a.pageAfter, al.Key)
+	} else if !routed && !exists && al.channel != "" {
+		logger.Printf("[alerter] Not sending %s alert through the failing channel: %s", al.Severity, al.Key)
+	} else if !routed && !exists {
+		logger.Printf("[alerter] Not sending %s alert below PagerDuty min-severity %s: %s",
+			al.Severity, a.minSeverity, al.Key,
+		)
+	}
+	if !routed || digested || held || repeat {
+		return nil
+	}
+	subject, body := a.templates.render(al, now)
+	return a.send(service, dedup, subject, body, al, escalated, firstSeen)
+}
+
+// To the service the alert is routed to, the primary one by default, and
+// to the escalation service once escalated
+func (a *alerter) send(service, dedup, subject, body string, about alert, escalated bool, firstSeen time.Time) error {
+	err := a.notify(service, dedup, subject, body, about, firstSeen)
+	if escalated && a.escalation.EventServiceKey != "" {
+		if escErr := a.notify(a.escalation.EventServiceKey, dedup, subject, body, about, firstSeen); escErr != nil {
+			errlog.Print(escErr)
+		}
+	}
+	return err
+}
+
+// Highest severity of the alerts of the check on the shard, empty when none
+// is firing. Expects the lock to be held
+func (a *alerter) firingSeverity(shard, check string) string {
+	severity := ""
+	for _, entry := range a.active {
+		if entry.Shard == shard && entry.Check == check &&
+			(severity == "" || severityRank[entry.Severity] > severityRank[severity]) {
+			severity = entry.Severity
+		}
+	}
+	return severity
+}
+
+func (a *alerter) firing(key string) bool {
+	a.inUse.Lock()
+	defer a.inUse.Unlock()
+	_, exists := a.active[key]
+	return exists
+}
+
+// Hysteresis, between the trigger and the clear threshold a check keeps
+// its current state so a value hovering at the limit does not flap
+func (a *alerter) breached(key string, overTrigger, pastClear bool) bool {
+	if overTrigger {
+		return true
+	}
+	if pastClear {
+		return false
+	}
+	return a.firing(key)
+}
+
+// No-op unless the alert is currently firing
+func (a *alerter) clear(key string) {
+	a.inUse.Lock()
+	entry, exists := a.active[key]
+	delete(a.active, key)
+	if exists {
+		a.recordResolved(entry, time.Now())
+	}
+	stillFiring := ""
+	if exists {
+		stillFiring = a.firingSeverity(entry.Shard, entry.Check)
+	}
+	// Services whose incident other alerts still firing were sent to
+	shared, anyShared := map[string]bool{}, false
+	for _, other := range a.active {
+		if exists && other.Notified && a.incidentKey(other) == a.incidentKey(entry) {
+			for service := range other.services {
+				shared[service] = true
+			}
+			anyShared = true
+		}
+	}
+	a.inUse.Unlock()
+	if !exists {
+		return
+	}
+	if a.onChange != nil {
+		a.onChange(entry.Shard, entry.Check, stillFiring)
+	}
+	downtime := time.Since(entry.FirstSeen)
+	withFields(stdlog, alertFields(entry.alert)).Printf("[alerter] %s check recovered on shard %s after %s: %s",
+		entry.Check, entry.Shard, downtime.Round(time.Second), key,
+	)
+	message := fmt.Sprintf(recoveryMessage,
+		entry.Check, entry.Shard, key, downtime.Round(time.Second), entry.Chain,
+	)
+	a.postRecovery(entry, message)
+	// Never opened on its own when it only went out in digests, and left
+	// open on each service while other alerts sent to the same incident
+	// there still fire
+	if !a.autoResolve || !entry.Notified {
+		return
+	}
+	dedup := a.incidentKey(entry)
+	for service := range entry.services {
+		if shared[service] {
+			continue
+		}
+		if err := a.notifyRecovery(service, dedup, message, entry.alert); err != nil {
+			errlog.Print(err)
+		} else {
+			stdlog.Printf("[alerter] Queued PagerDuty recovery! %s", key)
+		}
+	}
+	if entry.Escalated && a.escalation.EventServiceKey != "" && !anyShared {
+		if err := a.notifyRecovery(a.escalation.EventServiceKey, dedup, message, entry.alert); err != nil {
+			errlog.Print(err)
+		}
+	}
+}
@@ -43,6 +43,19 @@ Signature: %s
 Signature Bitmap: %s
 
 Time since last processed cross link: %f seconds (%f minutes)
+`
+	crossLinkAgeMessage = `
+Latest cross link for shard %d is too old!
+
+Cross Link Hash: %s
+
+Shard %d Cross Link Block: %d
+
+Shard Height: %d
+
+Blocks behind: %d (limit %d)
+
+Cross link block age: %f seconds (limit %d)
 `
 	blockHeightMessage = `
 %s at block height %d, but shard height %d.
@@ -51,10 +64,215 @@ Shard: %d
 
 Chain: %s
 `
-	p2pMessage = `
+	heightQuorumMessage = `
+%d of %d nodes more than %d blocks off shard height %d.
+
 Shard: %s
 
+Chain: %s
+`
+	heightRegressionMessage = `
+%s went back from block height %d to %d (%d blocks).
+
+Reorg, database rollback or node reset
+
+Shard: %s
+
+Chain: %s
+`
+	p2pMessage = `
+Shard: %d
+
 Avg Connectivity: %d
+`
+	internalP2PMessage = `
+Internal nodes of shard %d are poorly connected!
+
+Avg Connectivity: %d (threshold %d)
+
+Chain: %s
+`
+	heightNodesOffMessage = `
+%d of %d committee nodes are off the shard height!
+
+Limit: %s (%d nodes)
+
+Shard: %s
+
+Chain: %s
+`
+	connectivityNodesOffMessage = `
+%d of %d committee nodes are below the connectivity tolerance!
+
+Limit: %s (%d nodes)
+
+Shard: %s
+
+Chain: %s
+`
+	internalQuorumMessage = `
+%d of %d internal nodes more than %d blocks off shard height %d.
+
+Shard: %s
+
+Chain: %s
+`
+	blockTimeMessage = `
+Average block time on shard %s is %.2f seconds, above the target of %d seconds (+%d%%)!
+
+Samples: %d
+
+Latest Block: %d
+
+Chain: %s
+`
+	balanceMessage = `
+Balance of %s dropped below its minimum!
+
+Balance: %s ONE
+
+Minimum: %f ONE
+
+Shard: %d
+
+Chain: %s
+`
+	recoveryMessage = `
+Recovered: %s check on shard %s
+
+Alert: %s
+
+Down for: %s
+
+Chain: %s
+`
+	clockSkewMessage = `
+Clock of %s is %.0f seconds off the majority of nodes (tolerance %d)!
+
+Shard: %s
+
+Chain: %s
+`
+	viewSpreadMessage = `
+Nodes of shard %s disagree on the consensus view!
+
+View IDs: %d to %d
+
+Spread: %d (tolerance %d)
+
+Nodes reporting: %d
+
+Chain: %s
+`
+	signingMessage = `
+Validator %s is missing block signatures!
+
+BLS Key: %s
+
+Signing rate: %.2f%% (threshold %d%%)
+
+Signed %d of the last %d sampled blocks
+
+Shard: %s
+
+Chain: %s
+`
+	nodeCountMessage = `
+Node count of shard %d changed from %d to %d on reload!
+
+Change: %d%% (limit %d%%)
+
+A truncated or badly generated distribution file leaves nodes unmonitored
+
+Chain: %s
+`
+	malformedRepliesMessage = `
+%d of %d nodes of shard %s returned malformed %s replies!
+
+Nodes are reachable but their replies can't be decoded, likely a bad release
+
+Last error: %s
+
+Chain: %s
+`
+	unreachableShardMessage = `
+No node of shard %s replied to %s!
+
+Nodes tried: %d
+
+Last error: %s
+
+Failures: %s
+
+Chain: %s
+`
+	blockAgeMessage = `
+Latest block of shard %s is too old!
+
+Block: %d
+
+Age: %.0f seconds (limit %d)
+
+Block Timestamp: %s
+
+Chain: %s
+`
+	metadataChangeMessage = `
+Node metadata changed!
+
+Node: %s
+
+Shard: %s
+
+%s
+Chain: %s
+`
+	beaconStallMessage = `
+Beacon shard %s stalled!
+
+Height: %d
+
+No new block for %.0f seconds (limit %d)
+
+Chain: %s
+`
+	beaconLagMessage = `
+Beacon shard %s nodes behind!
+
+%d nodes trail the beacon by more than %d blocks
+
+Beacon Height: %d
+
+Nodes: %v
+
+Chain: %s
+`
+	groupedAlertMessage = `
+%d alerts started firing on %s within %s
+
+%s
+Chain: %s
+`
+	digestMessage = `
+%d alerts firing
+
+%s
+Chain: %s
+`
+	rollupMessage = `
+%d alerts past the rate limit firing
+
+%s
+Chain: %s
+`
+	deliveryFailingMessage = `
+%s alert delivery failing!
+
+%d delivery attempts in a row failed, alerts may not reach you through it
+
+Last Error: %v
+
+Chain: %s
 `
 	beaconSyncMessage = `
 %s beacon at block height %d, but beacon height %d.
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAuditEntries = 100
+	maxAuditEntries     = 1000
+	// Longest line read back, a payload is cut by no channel below this
+	maxAuditLine = 1 << 20
+)
+
+// What an event handed to a delivery queue is about. Destination names the
+// PagerDuty service, never its key
+type auditEvent struct {
+	Channel     string `json:"channel"`
+	Destination string `json:"destination,omitempty"`
+	Action      string `json:"action"`
+	Key         string `json:"key"`
+	Check       string `json:"check,omitempty"`
+	Shard       string `json:"shard,omitempty"`
+	Severity    string `json:"severity,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	Payload     string `json:"payload,omitempty"`
+}
+
+func auditEventOf(al alert, action, summary, payload string) auditEvent {
+	return auditEvent{
+		Action: action, Key: al.Key, Check: al.Check, Shard: al.Shard, Severity: al.Severity,
+		Summary: summary, Payload: payload,
+	}
+}
+
+func (e auditEvent) what() string {
+	return e.Action + " " + e.Key
+}
+
+// One line of the audit log. Every event gets a queued line, then one of
+// delivered, failed or dropped with the same delivery ID
+type auditRecord struct {
+	Time     time.Time `json:"time"`
+	Delivery uint64    `json:"delivery"`
+	auditEvent
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Append-only JSON lines file of every event sent, nil when not set
+type auditLog struct {
+	lock sync.Mutex
+	file *os.File
+}
+
+func openAuditLog(path string) (*auditLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{file: file}, nil
+}
+
+func (l *auditLog) record(id uint64, e auditEvent, status string, attempts int, err error) {
+	if l == nil {
+		return
+	}
+	r := auditRecord{Time: time.Now().UTC(), Delivery: id, auditEvent: e, Status: status, Attempts: attempts}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	line, _ := json.Marshal(r)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		errlog.Printf("[auditLog] Could not write %s of %s to %s, Error: %v", status, e.what(), l.file.Name(), err)
+	}
+}
+
+// The last limit records since the time given, of the key when set,
+// oldest first
+func (l *auditLog) read(limit int, since time.Time, key string) ([]auditRecord, error) {
+	file, err := os.Open(l.file.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	records := []auditRecord{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditLine)
+	for scanner.Scan() {
+		r := auditRecord{}
+		// A line still being written or cut by a crash is skipped
+		if json.Unmarshal(scanner.Bytes(), &r) != nil {
+			continue
+		}
+		if r.Time.Before(since) || key != "" && r.Key != key {
+			continue
+		}
+		if records = append(records, r); len(records) > limit {
+			records = records[1:]
+		}
+	}
+	return records, scanner.Err()
+}
+
+func (l *auditLog) close() {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.file.Close()
+}
+
+// GET the latest records of the audit log, limit defaults to 100 and is at
+// most 1000, since is an RFC 3339 time and key an alert or incident key
+func (m *monitor) auditJSON(w http.ResponseWriter, req *http.Request) {
+	audit := m.alerts.audit
+	if audit == nil {
+		http.Error(w, "audit-log not set under alerting in yaml config", http.StatusNotFound)
+		return
+	}
+	query := req.URL.Query()
+	limit := defaultAuditEntries
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit "+l, http.StatusBadRequest)
+			return
+		}
+		if limit = n; limit > maxAuditEntries {
+			limit = maxAuditEntries
+		}
+	}
+	since := time.Time{}
+	if s := query.Get("since"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			http.Error(w, "invalid since "+s+", expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	records, err := audit.read(limit, since, query.Get("key"))
+	if err != nil {
+		errlog.Printf("[auditJSON] Could not read %s, Error: %v", audit.file.Name(), err)
+		http.Error(w, "could not read the audit log", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(records)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Balances are reported by the RPC in atto, 1 ONE = 10^18 atto
+var attoPerOne = new(big.Float).SetFloat64(1e18)
+
+// MinBalance is assumed as ONE
+type watchedAddress struct {
+	Address    string  `yaml:"address"`
+	Shard      int     `yaml:"shard"`
+	MinBalance float64 `yaml:"min-balance"`
+}
+
+type addressBalance struct {
+	Address    string  `json:"address"`
+	Shard      int     `json:"shard-id"`
+	Balance    string  `json:"balance"`
+	MinBalance float64 `json:"min-balance"`
+	BelowMin   bool    `json:"below-min-balance"`
+	Error      string  `json:"error,omitempty"`
+}
+
+func parseBalance(hexBalance string) (*big.Float, error) {
+	atto, ok := new(big.Int).SetString(strings.TrimPrefix(hexBalance, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid balance in reply: %s", hexBalance)
+	}
+	return new(big.Float).Quo(new(big.Float).SetInt(atto), attoPerOne), nil
+}
+
+// Asks nodes of the address' shard in turn until one of them replies
+func queryBalance(address string, shard int, shardMap map[string]int) (*big.Float, error) {
+	requestFields := getRPCRequest(BalanceRPC)
+	requestFields["params"] = []interface{}{address, "latest"}
+	requestBody, _ := json.Marshal(requestFields)
+
+	type b struct {
+		Result string `json:"result"`
+	}
+
+	err := errors.New("no node known for shard")
+	for n, s := range shardMap {
+		if s != shard {
+			continue
+		}
+		result, _, oops := request(rpcScheme+n, requestBody)
+		if oops != nil {
+			err = oops
+			continue
+		}
+		reply := b{}
+		json.Unmarshal(result, &reply)
+		return parseBalance(reply.Result)
+	}
+	return nil, err
+}
+
+func (m *monitor) balanceMonitor(interval uint64, addresses []watchedAddress,
+	chain string,
+) {
+	for range time.Tick(time.Duration(interval) * time.Second) {
+		stdlog.Print("[balanceMonitor] Starting watched address balance check")
+		shardMap := m.shardMap()
+		balances := []addressBalance{}
+		for _, a := range addresses {
+			report := addressBalance{Address: a.Address, Shard: a.Shard, MinBalance: a.MinBalance}
+			balance, err := queryBalance(a.Address, a.Shard, shardMap)
+			if err != nil {
+				report.Error = err.Error()
+				balances = append(balances, report)
+				stdlog.Printf("[balanceMonitor] Unable to fetch balance of %s, Error: %v", a.Address, err)
+				continue
+			}
+			m.markPolled(strconv.Itoa(a.Shard), balanceCheck)
+			report.Balance = balance.Text('f', 6)
+			incidentKey := fmt.Sprintf("Address %s balance below minimum! - %s", a.Address, chain)
+			if balance.Cmp(big.NewFloat(a.MinBalance)) < 0 {
+				report.BelowMin = true
+				message := fmt.Sprintf(balanceMessage,
+					a.Address, report.Balance, a.MinBalance, a.Shard, chain,
+				)
+				err := m.alerts.trigger(alert{
+					Key: incidentKey, Chain: chain, Shard: strconv.Itoa(a.Shard),
+					Check: balanceCheck, Message: message,
+					Value: report.Balance, Threshold: strconv.FormatFloat(a.MinBalance, 'f', -1, 64),
+				})
+				if err != nil {
+					errlog.Print(err)
+				} else {
+					stdlog.Printf("[balanceMonitor] Alert triggered! %s", incidentKey)
+				}
+			} else {
+				m.alerts.clear(incidentKey)
+			}
+			balances = append(balances, report)
+			stdlog.Printf("[balanceMonitor] Address: %s, Shard: %d, Balance: %s ONE",
+				a.Address, a.Shard, report.Balance,
+			)
+		}
+		m.inUse.Lock()
+		m.balances = balances
+		m.inUse.Unlock()
+	}
+}
@@ -4,13 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
 	"sync/atomic"
 	"time"
 )
 
 func (m *monitor) beaconSyncMonitor(
 	beaconBlock, interval, threshold uint64, poolSize int,
-	pdServiceKey, chain string, shardMap map[string]int,
+	chain string, shardMap map[string]int,
 ) {
 	stdlog.Printf("[beaconSyncMonitor] Starting beacon sync check, Beacon Block: %v", beaconBlock)
 	currentBeaconHeaders := getBeaconHeaders(poolSize, shardMap)
@@ -19,7 +20,9 @@ func (m *monitor) beaconSyncMonitor(
 	for ip, header := range currentBeaconHeaders {
 		if header != nil {
 			if beaconBlock > header.Number && beaconBlock-header.Number >= threshold {
-				go checkBeaconSync(header.Number, beaconBlock, threshold, interval, ip, pdServiceKey, chain)
+				go m.checkBeaconSync(header.Number, beaconBlock, threshold, interval, ip, chain)
+			} else {
+				m.alerts.clear(fmt.Sprintf("%s beacon out of sync! - %s", ip, chain))
 			}
 			if _, exists := shardBeaconMap[shardMap[ip]]; !exists {
 				shardBeaconMap[shardMap[ip]] = map[uint64]bool{}
@@ -30,12 +33,19 @@ func (m *monitor) beaconSyncMonitor(
 		}
 	}
 
-	for shard, blocks := range shardBeaconMap {
+	shards := []int{}
+	for shard := range shardBeaconMap {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	for _, shard := range shards {
+		blocks := shardBeaconMap[shard]
 		uniqueBlocks := []uint64{}
 		for b := range blocks {
 			uniqueBlocks = append(uniqueBlocks, b)
 		}
 		if shard != 0 {
+			m.markPolled(strconv.Itoa(shard), beaconSyncCheck)
 			sort.SliceStable(uniqueBlocks, func(i, j int) bool {
 				return uniqueBlocks[i] > uniqueBlocks[j]
 			})
@@ -60,7 +70,7 @@ func getBeaconHeaders(poolSize int,
 		for n, s := range shardMap {
 			if s != 0 {
 				requestBody, _ := json.Marshal(requestFields)
-				requests <- work{n, LatestHeadersRPC, requestBody}
+				requests <- work{n, LatestHeadersRPC, requestBody, time.Now(), nil, nil}
 			}
 		}
 	}()
@@ -78,7 +88,7 @@ func getBeaconHeaders(poolSize int,
 
 			for r := range requests {
 				result := reply{address: r.address, rpc: r.rpc}
-				result.rpcResult, result.rpcPayload, result.oops = request("http://"+r.address, r.body)
+				result.rpcResult, result.rpcPayload, result.oops = request(rpcScheme+r.address, r.body)
 				data <- result
 			}
 		}()
@@ -105,7 +115,7 @@ func getBeaconHeaders(poolSize int,
 	return ret
 }
 
-func checkBeaconSync(blockNum, beaconHeight, threshold, syncTimer uint64, IP, pdServiceKey, chain string) {
+func (m *monitor) checkBeaconSync(blockNum, beaconHeight, threshold, syncTimer uint64, IP, chain string) {
 	type a struct {
 		Result NodeMetadataReply `json:"result"`
 	}
@@ -119,7 +129,7 @@ func checkBeaconSync(blockNum, beaconHeight, threshold, syncTimer uint64, IP, pd
 
 	requestFields := getRPCRequest(LatestHeadersRPC)
 	requestBody, _ := json.Marshal(requestFields)
-	result, _, err := request("http://"+IP, requestBody)
+	result, _, err := request(rpcScheme+IP, requestBody)
 	// If error, skip
 	if err != nil {
 		stdlog.Printf("[checkBeaconSync] Error getting Beacon header: %s", IP)
@@ -127,19 +137,25 @@ func checkBeaconSync(blockNum, beaconHeight, threshold, syncTimer uint64, IP, pd
 	}
 	headers := h{}
 	json.Unmarshal(result, &headers)
+	incidentKey := fmt.Sprintf("%s beacon out of sync! - %s", IP, chain)
 	if !(headers.Result.Beacon.Number > blockNum) && (beaconHeight-headers.Result.Beacon.Number > threshold) {
 		message := fmt.Sprintf(beaconSyncMessage, IP, headers.Result.Beacon.Number,
 			beaconHeight, headers.Result.AuxShard.ShardID, chain,
 		)
-		incidentKey := fmt.Sprintf("%s beacon out of sync! - %s", IP, chain)
-		err := notify(pdServiceKey, incidentKey, chain, message)
+		err := m.alerts.trigger(alert{
+			Key: incidentKey, Chain: chain, Shard: strconv.FormatUint(uint64(headers.Result.AuxShard.ShardID), 10),
+			Check: beaconSyncCheck, Message: message,
+			Value:     strconv.FormatUint(beaconHeight-headers.Result.Beacon.Number, 10),
+			Threshold: strconv.FormatUint(threshold, 10),
+		})
 		if err != nil {
 			errlog.Print(err)
 		} else {
-		 	stdlog.Printf("[checkBeaconSync] Sent PagerDuty alert! %s", incidentKey)
+		 	stdlog.Printf("[checkBeaconSync] Alert triggered! %s", incidentKey)
 		}
 		stdlog.Printf("[checkBeaconSync] %s beacon not syncing", IP)
 	} else {
+		m.alerts.clear(incidentKey)
 		stdlog.Printf("[checkBeaconSync] %s beacon sync", IP)
 	}
 }
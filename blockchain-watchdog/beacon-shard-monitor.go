@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Stricter checks for the beacon shard, which anchors the crosslinks of
+// all other shards. Stall is assumed as seconds without a new beacon
+// block, lag-tolerance as blocks a beacon node may trail the beacon
+// height, zero disables either. Severity defaults to critical
+type beaconParams struct {
+	checkToggle  `yaml:",inline"`
+	Shard        int     `yaml:"shard"`
+	Stall        seconds `yaml:"stall"`
+	LagTolerance int     `yaml:"lag-tolerance"`
+	Severity     string  `yaml:"severity"`
+}
+
+type beaconStatus struct {
+	ShardID      string      `json:"shard-id"`
+	Health       healthState `json:"health"`
+	Height       uint64      `json:"height"`
+	StallSeconds float64     `json:"seconds-since-last-block"`
+	Stalled      bool        `json:"stalled"`
+	LaggingNodes []string    `json:"lagging-nodes"`
+}
+
+func (m *monitor) beaconMonitor(params beaconParams, chain string, data BlockHeaderContainer, now time.Time) {
+	shard := strconv.Itoa(params.Shard)
+	heights := map[string]uint64{}
+	maxHeight := uint64(0)
+	for _, v := range data.Nodes {
+		if int(v.Payload.ShardID) != params.Shard {
+			continue
+		}
+		heights[v.IP] = v.Payload.BlockNumber
+		if v.Payload.BlockNumber > maxHeight {
+			maxHeight = v.Payload.BlockNumber
+		}
+	}
+	// Reachability already covers a beacon without replies
+	if len(heights) == 0 {
+		return
+	}
+	m.markPolled(shard, beaconCheck)
+
+	m.inUse.Lock()
+	if m.beacon.Height == 0 || maxHeight > m.beacon.Height {
+		m.beacon.Height, m.beaconAdvanced = maxHeight, now
+	}
+	since := now.Sub(m.beaconAdvanced).Seconds()
+	m.inUse.Unlock()
+
+	severity := params.Severity
+	if severity == "" {
+		severity = severityCritical
+	}
+	stallKey := fmt.Sprintf("Beacon shard %s stalled! - %s", shard, chain)
+	stalled := params.Stall > 0 && since > float64(params.Stall)
+	if stalled {
+		message := fmt.Sprintf(beaconStallMessage, shard, maxHeight, since, params.Stall, chain)
+		err := m.alerts.trigger(alert{
+			Key: stallKey, Chain: chain, Shard: shard,
+			Check: beaconCheck, Message: message, Severity: severity,
+			Value: fmt.Sprintf("%.0f", since), Threshold: strconv.Itoa(int(params.Stall)),
+		})
+		if err != nil {
+			errlog.Print(err)
+		} else {
+			stdlog.Printf("[beaconMonitor] Alert triggered! %s", stallKey)
+		}
+	} else {
+		m.alerts.clear(stallKey)
+	}
+
+	lagging := []string{}
+	for ip, h := range heights {
+		if params.LagTolerance > 0 && maxHeight-h > uint64(params.LagTolerance) {
+			lagging = append(lagging, m.nodeName(ip))
+		}
+	}
+	sort.Strings(lagging)
+	lagKey := fmt.Sprintf("Beacon shard %s nodes behind! - %s", shard, chain)
+	if len(lagging) > 0 {
+		message := fmt.Sprintf(beaconLagMessage,
+			shard, len(lagging), params.LagTolerance, maxHeight, lagging, chain,
+		)
+		err := m.alerts.trigger(alert{
+			Key: lagKey, Chain: chain, Shard: shard,
+			Check: beaconCheck, Message: message, Severity: severity,
+			Value: strconv.Itoa(len(lagging)), Threshold: strconv.Itoa(params.LagTolerance),
+		})
+		if err != nil {
+			errlog.Print(err)
+		} else {
+			stdlog.Printf("[beaconMonitor] Alert triggered! %s", lagKey)
+		}
+	} else {
+		m.alerts.clear(lagKey)
+	}
+
+	m.setDegraded(shard, beaconCheck, stalled || len(lagging) > 0)
+	health := healthUp
+	if stalled {
+		health = healthDown
+	} else if len(lagging) > 0 {
+		health = healthDegraded
+	}
+	m.inUse.Lock()
+	m.beacon = beaconStatus{shard, health, maxHeight, since, stalled, lagging}
+	m.inUse.Unlock()
+	stdlog.Printf("[beaconMonitor] Beacon shard %s, Height: %d, Last block %.0fs ago, Lagging nodes: %d",
+		shard, maxHeight, since, len(lagging),
+	)
+}
+
+// Nil until the beacon was polled once. Expects m.inUse to be held
+func (m *monitor) beaconSnapshot() *beaconStatus {
+	if m.beacon.ShardID == "" {
+		return nil
+	}
+	b := m.beacon
+	b.LaggingNodes = append([]string{}, m.beacon.LaggingNodes...)
+	return &b
+}
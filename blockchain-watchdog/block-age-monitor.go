@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxAge is assumed as seconds, zero only reports the age without alerting
+type blockAgeParams struct {
+	checkToggle `yaml:",inline"`
+	MaxAge      seconds `yaml:"max-age"`
+	Critical    seconds `yaml:"critical"`
+	ClearMargin seconds `yaml:"clear-margin"`
+}
+
+// Wall clock age of the newest block of each shard. Unlike the consensus
+// check this doesn't rely on nodes comparing to each other, so it also
+// catches a shard where all nodes are stuck on the same block
+func (m *monitor) blockAgeMonitor(params blockAgeParams, chain string,
+	blockHeaderData any, now time.Time,
+) {
+	ages := map[string]float64{}
+	for _, shard := range summaryShards(blockHeaderData) {
+		summary := blockHeaderData[shard]
+		latest := summary.(any)["latest-block"].(BlockHeader)
+		age := now.Sub(time.Unix(latest.Payload.UnixTime, 0)).Seconds()
+		ages[shard] = age
+		m.markPolled(shard, blockAgeCheck)
+		stdlog.Printf("[blockAgeMonitor] Shard %s, Latest block %d is %.0fs old",
+			shard, latest.Payload.BlockNumber, age,
+		)
+		if params.MaxAge == 0 {
+			continue
+		}
+		incidentKey := fmt.Sprintf("Shard %s latest block too old! - %s", shard, chain)
+		breach := m.alerts.breached(incidentKey,
+			age > float64(params.MaxAge), age+float64(params.ClearMargin) <= float64(params.MaxAge),
+		)
+		m.setDegraded(shard, blockAgeCheck, breach)
+		if breach {
+			message := fmt.Sprintf(blockAgeMessage,
+				shard, latest.Payload.BlockNumber, age, params.MaxAge,
+				latest.Payload.Timestamp, chain,
+			)
+			err := m.alerts.trigger(alert{
+				Key: incidentKey, Chain: chain, Shard: shard,
+				Check: blockAgeCheck, Message: message, Severity: tieredSeverity(age, uint64(params.Critical)),
+				Value: fmt.Sprintf("%.0f", age), Threshold: fmt.Sprintf("%d", params.MaxAge),
+			})
+			if err != nil {
+				errlog.Print(err)
+			} else {
+				stdlog.Printf("[blockAgeMonitor] Alert triggered! %s", incidentKey)
+			}
+		} else {
+			m.alerts.clear(incidentKey)
+		}
+	}
+	m.inUse.Lock()
+	m.blockAges = ages
+	m.inUse.Unlock()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+)
+
+const defaultBlockTimeWindow = 10
+
+// Target is assumed as seconds, a zero target disables the check
+type blockTimeParams struct {
+	checkToggle `yaml:",inline"`
+	Target      int `yaml:"target"`
+	Tolerance   int `yaml:"tolerance-percent"`
+	Window      int `yaml:"window"`
+	ClearMargin int `yaml:"clear-margin-percent"`
+}
+
+type blockTimeSample struct {
+	Height   uint64
+	UnixTime int64
+}
+
+// Rolling window of block intervals per shard, fed by successive block header polls
+type blockTimeTracker struct {
+	window    int
+	last      map[string]blockTimeSample
+	intervals map[string][]float64
+}
+
+func newBlockTimeTracker(window int) *blockTimeTracker {
+	if window <= 0 {
+		window = defaultBlockTimeWindow
+	}
+	return &blockTimeTracker{
+		window:    window,
+		last:      map[string]blockTimeSample{},
+		intervals: map[string][]float64{},
+	}
+}
+
+// Records the latest block of a shard and returns the current rolling average
+// block time with the number of samples it was computed from
+func (t *blockTimeTracker) record(shard string, latest BlockHeader) (float64, int) {
+	current := blockTimeSample{latest.Payload.BlockNumber, latest.Payload.UnixTime}
+	if prev, exists := t.last[shard]; exists && current.Height > prev.Height {
+		interval := float64(current.UnixTime-prev.UnixTime) / float64(current.Height-prev.Height)
+		samples := append(t.intervals[shard], interval)
+		if len(samples) > t.window {
+			samples = samples[len(samples)-t.window:]
+		}
+		t.intervals[shard] = samples
+	}
+	if prev, exists := t.last[shard]; !exists || current.Height > prev.Height {
+		t.last[shard] = current
+	}
+	samples := t.intervals[shard]
+	if len(samples) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, s := range samples {
+		sum += s
+	}
+	return sum / float64(len(samples)), len(samples)
+}
+
+func (m *monitor) blockTimeMonitor(
+	params blockTimeParams, tracker *blockTimeTracker,
+	chain string, blockHeaderData any,
+) {
+	averages := map[string]float64{}
+	for _, shard := range summaryShards(blockHeaderData) {
+		summary := blockHeaderData[shard]
+		latest := summary.(any)["latest-block"].(BlockHeader)
+		avg, samples := tracker.record(shard, latest)
+		averages[shard] = avg
+		if samples == 0 || params.Target == 0 {
+			continue
+		}
+		m.markPolled(shard, blockTimeCheck)
+		limit := float64(params.Target) * (1 + float64(params.Tolerance)/100)
+		clearLimit := float64(params.Target) * (1 + float64(params.Tolerance-params.ClearMargin)/100)
+		incidentKey := fmt.Sprintf("Shard %s block time regression! - %s", shard, chain)
+		breach := m.alerts.breached(incidentKey, avg > limit && m.warmedUp(shard), avg <= clearLimit)
+		m.setDegraded(shard, blockTimeCheck, breach)
+		if breach {
+			message := fmt.Sprintf(blockTimeMessage,
+				shard, avg, params.Target, params.Tolerance, samples,
+				latest.Payload.BlockNumber, chain,
+			)
+			err := m.alerts.trigger(alert{
+				Key: incidentKey, Chain: chain, Shard: shard,
+				Check: blockTimeCheck, Message: message,
+				Value: fmt.Sprintf("%.2f", avg), Threshold: fmt.Sprintf("%.2f", limit),
+			})
+			if err != nil {
+				errlog.Print(err)
+			} else {
+				stdlog.Printf("[blockTimeMonitor] Alert triggered! %s", incidentKey)
+			}
+		} else {
+			m.alerts.clear(incidentKey)
+		}
+		stdlog.Printf("[blockTimeMonitor] Shard %s, Average block time: %.2fs over %d samples",
+			shard, avg, samples,
+		)
+	}
+	m.inUse.Lock()
+	m.blockTimes = averages
+	m.inUse.Unlock()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type chainMismatch struct {
+	Node    string `json:"node"`
+	Shard   uint32 `json:"shard-id"`
+	Network string `json:"network"`
+	ChainID int    `json:"chain-id"`
+}
+
+// Nodes whose reported network isn't target-chain likely point the watchdog
+// at the wrong endpoints. Nodes not reporting a network are left out
+func (m *monitor) chainMonitor(chain string, data MetadataContainer) {
+	mismatches := map[string]chainMismatch{}
+	for _, metadata := range data.Nodes {
+		network := metadata.Payload.NetworkType
+		if network == "" || strings.EqualFold(network, chain) {
+			continue
+		}
+		mismatches[metadata.IP] = chainMismatch{
+			m.nodeName(metadata.IP), metadata.Payload.ShardID,
+			network, metadata.Payload.ChainConfig.ChainID,
+		}
+	}
+	m.inUse.Lock()
+	previous := m.chainMismatches
+	m.chainMismatches = mismatches
+	m.inUse.Unlock()
+	// Logged once when first seen rather than each cycle
+	for ip, c := range mismatches {
+		if _, known := previous[ip]; !known {
+			fields := logFields{Shard: strconv.FormatUint(uint64(c.Shard), 10), Node: ip}
+			withFields(stdlog, fields).Printf("[chainMonitor] WARNING %s on shard %d reports network %s (chain-id %d), not target-chain %s",
+				c.Node, c.Shard, c.Network, c.ChainID, chain,
+			)
+		}
+	}
+	for ip, c := range previous {
+		if _, known := mismatches[ip]; !known {
+			fields := logFields{Shard: strconv.FormatUint(uint64(c.Shard), 10), Node: ip}
+			withFields(stdlog, fields).Printf("[chainMonitor] %s now matches target-chain %s", c.Node, chain)
+		}
+	}
+}
+
+func (m *monitor) chainMismatchSnapshot() []chainMismatch {
+	m.inUse.Lock()
+	snapshot := make([]chainMismatch, 0, len(m.chainMismatches))
+	for _, c := range m.chainMismatches {
+		snapshot = append(snapshot, c)
+	}
+	m.inUse.Unlock()
+	sort.SliceStable(snapshot, func(i, j int) bool { return snapshot[i].Node < snapshot[j].Node })
+	return snapshot
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+)
+
+// Scheme of every RPC call, https once a client certificate is configured
+var rpcScheme = "http://"
+
+// Both files or neither, nil without a client certificate
+func clientTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("client-cert and client-key must be set together")
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{pair}}, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Tolerance is assumed as seconds, zero disables the check. Node clocks are
+// read from the HTTP Date header of RPC replies, which has second resolution
+type clockSkewParams struct {
+	checkToggle `yaml:",inline"`
+	Tolerance   seconds `yaml:"tolerance"`
+}
+
+// Offset of the node clock against the local clock at the midpoint of the request
+func nodeClockOffset(node string, requestBody []byte) (float64, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	res := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(res)
+	req.SetBody(requestBody)
+	req.Header.SetMethodBytes(post)
+	req.Header.SetContentType("application/json")
+	req.SetRequestURI(node)
+	sent := time.Now()
+	if err := client.Do(req, res); err != nil {
+		return 0, err
+	}
+	received := time.Now()
+	date := res.Header.Peek("Date")
+	if len(date) == 0 {
+		return 0, errors.New("no Date header in reply")
+	}
+	nodeTime, err := time.Parse(time.RFC1123, string(date))
+	if err != nil {
+		return 0, fmt.Errorf("bad Date header in reply: %v", err)
+	}
+	midpoint := sent.Add(received.Sub(sent) / 2)
+	return nodeTime.Sub(midpoint).Seconds(), nil
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// Skew is measured against the median offset of all nodes, so a drifting
+// machine running watchdog does not flag the whole network
+func (m *monitor) clockSkewMonitor(interval uint64, params clockSkewParams,
+	poolSize int, chain string,
+) {
+	requestBody, _ := json.Marshal(getRPCRequest(NodeMetadataRPC))
+	for range time.Tick(time.Duration(interval) * time.Second) {
+		stdlog.Print("[clockSkewMonitor] Starting clock skew check")
+		shardMap := m.nodeLevelShardMap(m.shardMap())
+		offsets := map[string]float64{}
+		var lock sync.Mutex
+		var group sync.WaitGroup
+		limiter := make(chan struct{}, poolSize)
+		for n := range shardMap {
+			group.Add(1)
+			limiter <- struct{}{}
+			go func(n string) {
+				defer func() { <-limiter; group.Done() }()
+				offset, err := nodeClockOffset(rpcScheme+n, requestBody)
+				if err != nil {
+					return
+				}
+				lock.Lock()
+				offsets[n] = offset
+				lock.Unlock()
+			}(n)
+		}
+		group.Wait()
+		if len(offsets) == 0 {
+			continue
+		}
+		all := []float64{}
+		for _, o := range offsets {
+			all = append(all, o)
+		}
+		majority := median(all)
+
+		skews := map[string]map[string]float64{}
+		skewed := map[string]bool{}
+		for n, o := range offsets {
+			shard := strconv.Itoa(shardMap[n])
+			skew := o - majority
+			if skews[shard] == nil {
+				skews[shard] = map[string]float64{}
+			}
+			skews[shard][m.nodeName(n)] = skew
+			incidentKey := fmt.Sprintf("%s clock skewed! - %s", n, chain)
+			if math.Abs(skew) <= float64(params.Tolerance) {
+				m.alerts.clear(incidentKey)
+				continue
+			}
+			skewed[shard] = true
+			message := fmt.Sprintf(clockSkewMessage,
+				m.nodeName(n), skew, params.Tolerance, shard, chain,
+			)
+			err := m.alerts.trigger(alert{
+				Key: incidentKey, Chain: chain, Shard: shard,
+				Check: clockSkewCheck, Message: message,
+				Value:     strconv.FormatFloat(skew, 'f', 0, 64),
+				Threshold: strconv.Itoa(int(params.Tolerance)),
+				Nodes:     []string{n},
+			})
+			if err != nil {
+				errlog.Print(err)
+			} else {
+				stdlog.Printf("[clockSkewMonitor] Alert triggered! %s", incidentKey)
+			}
+		}
+		shards := []string{}
+		for shard := range skews {
+			shards = append(shards, shard)
+		}
+		sortShardIDs(shards)
+		for _, shard := range shards {
+			m.markPolled(shard, clockSkewCheck)
+			m.setDegraded(shard, clockSkewCheck, skewed[shard])
+			stdlog.Printf("[clockSkewMonitor] Shard: %s, Nodes measured: %d, Skewed: %v",
+				shard, len(skews[shard]), skewed[shard],
+			)
+		}
+		m.inUse.Lock()
+		m.clockSkews = skews
+		m.inUse.Unlock()
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"gopkg.in/yaml.v2"
+)
+
+const redacted = "<redacted>"
+
+// Copy of the params with every credential masked, set ones only so an
+// empty secret still shows as missing
+func redactedParams(params watchParams) watchParams {
+	for _, secret := range []*string{
+		&params.Auth.PagerDuty.EventServiceKey,
+		&params.Auth.Telegram.BotToken,
+		// Like Slack's, the path of a Discord or Teams webhook is its secret
+		&params.Auth.Discord.WebhookURL,
+		&params.Auth.Teams.WebhookURL,
+		&params.Auth.OpsGenie.APIKey,
+		&params.Auth.Email.Password,
+		&params.Auth.Twilio.AuthToken,
+		&params.InfluxDB.Token,
+		&params.ShardHealthReporting.Escalation.EventServiceKey,
+		&params.HTTPReporter.Public.BasicAuth.Password,
+		&params.HTTPReporter.Admin.BasicAuth.Password,
+	} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	// The path of a Slack webhook is its secret
+	if params.Auth.Slack.WebhookURL != "" {
+		params.Auth.Slack.WebhookURL = redacted
+	}
+	webhooks := map[string]string{}
+	for check := range params.Auth.Slack.CheckWebhooks {
+		webhooks[check] = redacted
+	}
+	if len(webhooks) > 0 {
+		params.Auth.Slack.CheckWebhooks = webhooks
+	}
+	// Like the headers, a webhook url often carries a token. Copied, the
+	// entries share their array with the params in use
+	targets := []webhookURL{}
+	for _, target := range params.Auth.Webhook.URLs {
+		for _, secret := range []*string{&target.URL, &target.SigningSecret} {
+			if *secret != "" {
+				*secret = redacted
+			}
+		}
+		targets = append(targets, target)
+	}
+	if len(targets) > 0 {
+		params.Auth.Webhook.URLs = targets
+	}
+	webhookHeaders := map[string]string{}
+	for k := range params.Auth.Webhook.Headers {
+		webhookHeaders[k] = redacted
+	}
+	if len(webhookHeaders) > 0 {
+		params.Auth.Webhook.Headers = webhookHeaders
+	}
+	// Copied, the rules share their array with the params in use
+	routing := alertRoutes{}
+	for _, rule := range params.Alerting.Routing {
+		for _, secret := range []*string{&rule.EventServiceKey, &rule.SlackWebhook} {
+			if *secret != "" {
+				*secret = redacted
+			}
+		}
+		routing = append(routing, rule)
+	}
+	if len(routing) > 0 {
+		params.Alerting.Routing = routing
+	}
+	// Collectors mostly authenticate with a header, so none is shown
+	headers := map[string]string{}
+	for k := range params.Tracing.OTLP.Headers {
+		headers[k] = redacted
+	}
+	if len(headers) > 0 {
+		params.Tracing.OTLP.Headers = headers
+	}
+	// Proxy credentials are given in the URL, where <> would be escaped
+	if u, err := parseProxy(params.Network.Proxy); err == nil && u.User != nil {
+		if _, set := u.User.Password(); set {
+			u.User = url.UserPassword(u.User.Username(), "redacted")
+			params.Network.Proxy = u.String()
+		}
+	}
+	return params
+}
+
+// yaml.v2 decodes mappings with interface{} keys, which JSON can't encode
+func jsonKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for k, value := range t {
+			converted[fmt.Sprint(k)] = jsonKeys(value)
+		}
+		return converted
+	case []interface{}:
+		for i, value := range t {
+			t[i] = jsonKeys(value)
+		}
+	}
+	return v
+}
+
+// Serves the config the daemon runs with as JSON, keyed by the same names
+// as the yaml config. Needs the admin basic-auth credentials when set
+func configJSON(params watchParams) http.HandlerFunc {
+	raw, _ := yaml.Marshal(redactedParams(params))
+	var tree interface{}
+	yaml.Unmarshal(raw, &tree)
+	tree = jsonKeys(tree)
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tree)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Body of /config for the given params
+func servedConfig(params watchParams) string {
+	w := httptest.NewRecorder()
+	configJSON(params).ServeHTTP(w, httptest.NewRequest("GET", "/config", nil))
+	return w.Body.String()
+}
+
+func TestConfigMasksSecrets(t *testing.T) {
+	p := watchParams{}
+	p.Auth.PagerDuty.EventServiceKey = "pd-key-secret"
+	p.InfluxDB.URL = "http://localhost:8086"
+	p.InfluxDB.Token = "influx-token-secret"
+	p.Auth.Webhook.URLs = []webhookURL{
+		{URL: "https://hooks.example.com/token-in-path", SigningSecret: "hmac-secret"},
+		{URL: "https://other.example.com/?key=query-secret", SignatureHeader: "X-Sig"},
+	}
+	body := servedConfig(p)
+	for _, secret := range []string{
+		"pd-key-secret", "influx-token-secret", "token-in-path", "hmac-secret", "query-secret",
+	} {
+		if strings.Contains(body, secret) {
+			t.Errorf("/config shows %s: %s", secret, body)
+		}
+	}
+	if !strings.Contains(body, "http://localhost:8086") {
+		t.Errorf("/config leaves out the influxdb url: %s", body)
+	}
+	if !strings.Contains(body, "X-Sig") {
+		t.Errorf("/config leaves out the signature header: %s", body)
+	}
+	if p.Auth.Webhook.URLs[0].URL != "https://hooks.example.com/token-in-path" {
+		t.Errorf("masking changed the webhook urls in use to %s", p.Auth.Webhook.URLs[0].URL)
+	}
+}
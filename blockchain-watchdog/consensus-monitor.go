@@ -3,14 +3,18 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 )
 
 func (m *monitor) consensusMonitor(
-	interval, warning, tolerance uint64, poolSize int,
-	pdServiceKey, chain string, shardMap map[string]int,
+	interval, warning, tolerance, margin, quorum, quantile uint64, poolSize int,
+	chain string, blockTime blockTimeParams, signing signingParams,
+	blockAge blockAgeParams, beacon beaconParams, regression regressionParams,
 ) {
+	shardMap := m.shardMap()
 	jobs := make(chan work, len(shardMap))
 	replyChannels := make(map[string](chan reply))
 	syncGroups := make(map[string]*sync.WaitGroup)
@@ -19,68 +23,92 @@ func (m *monitor) consensusMonitor(
 	var bhGroup sync.WaitGroup
 	syncGroups[BlockHeaderRPC] = &bhGroup
 
-	for i := 0; i < poolSize; i++ {
-		go m.worker(jobs, replyChannels, syncGroups)
-	}
+	m.startWorkers("consensus", poolSize, int(interval), jobs, replyChannels, syncGroups)
 
 	requestFields := getRPCRequest(BlockHeaderRPC)
 
-	type s struct {
-		Result BlockHeaderReply `json:"result"`
-	}
-
 	type lastSuccessfulBlock struct {
 		Height uint64
 		TS     time.Time
 	}
 
 	lastShardData := make(map[string]lastSuccessfulBlock)
-	consensusStatus := make(map[string]bool)
+	blockTimes := newBlockTimeTracker(blockTime.Window)
+	signingRates := newSigningTracker(signing.Window)
 
 	for now := range time.Tick(time.Duration(interval) * time.Second) {
 		stdlog.Print("[consensusMonitor] Starting consensus check")
-		for n := range shardMap {
-			requestBody, _ := json.Marshal(requestFields)
-			jobs <- work{n, BlockHeaderRPC, requestBody}
-			syncGroups[BlockHeaderRPC].Add(1)
-		}
+		// Fresh each cycle, a shard without replies is unknown rather than
+		// keeping the status it had before going down
+		consensusStatus := make(map[string]bool)
+		shardMap = m.shardMap()
+		replyChannels[BlockHeaderRPC] = make(chan reply, len(shardMap))
+		requestBody, _ := json.Marshal(requestFields)
+		ctx, cycle := m.tracer.start(m.ctx, "consensus "+BlockHeaderRPC, spanKindInternal,
+			"method", BlockHeaderRPC,
+		)
+		m.queueShards(ctx, "consensus", jobs, shardMap, BlockHeaderRPC, requestBody,
+			int(interval), syncGroups[BlockHeaderRPC],
+		)
 		syncGroups[BlockHeaderRPC].Wait()
 		close(replyChannels[BlockHeaderRPC])
+		// Covers the checks run on the replies, not those in goroutines of
+		// their own
+		_, aggregate := m.tracer.start(ctx, "aggregate "+BlockHeaderRPC, spanKindInternal,
+			"method", BlockHeaderRPC, "replies", strconv.Itoa(len(replyChannels[BlockHeaderRPC])),
+		)
 
-		monitorData := BlockHeaderContainer{}
-		for d := range replyChannels[BlockHeaderRPC] {
-			if d.oops != nil {
-				monitorData.Down = append(m.WorkingBlockHeader.Down,
-					noReply{d.address, d.oops.Error(),
-						string(d.rpcPayload), shardMap[d.address],
-					},
-				)
-			} else {
-				oneReport := s{}
-				json.Unmarshal(d.rpcResult, &oneReport)
-				monitorData.Nodes = append(monitorData.Nodes, BlockHeader{
-					oneReport.Result,
-					d.address,
-				})
-			}
-		}
+		monitorData := m.blockHeaderReplies(replyChannels[BlockHeaderRPC], shardMap)
+		m.checkReachability(chain, shardMap, monitorData)
+		m.checkMalformedReplies(chain, BlockHeaderRPC, shardMap, monitorData.Malformed)
 
 		containerCopy := BlockHeaderContainer{}
 		containerCopy.Nodes = append([]BlockHeader{}, monitorData.Nodes...)
 
-		go checkShardHeight(containerCopy, warning, tolerance, pdServiceKey, chain)
+		if m.enabled(shardHeightCheck) {
+			go m.checkShardHeight(m.nodeLevelHeaders(containerCopy), warning, tolerance, margin, quorum, quantile, chain)
+		}
+		if m.enabled(beaconCheck) {
+			m.beaconMonitor(beacon, chain, containerCopy, now)
+		}
+		if m.enabled(regressionCheck) {
+			m.heightRegressionMonitor(regression, chain, m.nodeLevelHeaders(containerCopy))
+		}
 
 		blockHeaderData := any{}
 		blockHeaderSummary(monitorData.Nodes, true, blockHeaderData)
+		for shard := range blockHeaderData {
+			m.recordSample(shard)
+		}
+		if m.enabled(blockTimeCheck) {
+			m.blockTimeMonitor(blockTime, blockTimes, chain, blockHeaderData)
+		}
+		if m.enabled(signingCheck) {
+			m.signingMonitor(signing, signingRates, chain, blockHeaderData)
+		}
+		if m.enabled(blockAgeCheck) {
+			m.blockAgeMonitor(blockAge, chain, blockHeaderData, now)
+		}
 
 		currentUTCTime := now.UTC()
 
-		for shard, summary := range blockHeaderData {
+		heights := map[string]uint64{}
+		for _, shard := range summaryShards(blockHeaderData) {
+			summary := blockHeaderData[shard]
 			currentBlockHeight := summary.(any)[blockMax].(uint64)
+			heights[shard] = currentBlockHeight
 			currentBlockHeader := summary.(any)["latest-block"].(BlockHeader)
 			if shard == "0" {
-					go m.beaconSyncMonitor(currentBlockHeight, warning, tolerance, poolSize, pdServiceKey, chain, shardMap)
+				go m.beaconSyncMonitor(currentBlockHeight, warning, tolerance, poolSize, chain, shardMap)
+			}
+			// Block headers are still polled for the other checks
+			if !m.enabled(consensusCheck) {
+				continue
 			}
+			m.markPolled(shard, consensusCheck)
+			incidentKey := fmt.Sprintf("Shard %s consensus stuck! - %s",
+				shard, chain,
+			)
 			if lastBlock, exists := lastShardData[shard]; exists {
 				if currentBlockHeight <= lastBlock.Height {
 					timeSinceLastSuccess := currentUTCTime.Sub(lastBlock.TS)
@@ -97,39 +125,135 @@ func (m *monitor) consensusMonitor(
 							int64(timeSinceLastSuccess.Seconds()),
 							timeSinceLastSuccess.Minutes(), chain,
 						)
-						incidentKey := fmt.Sprintf("Shard %s consensus stuck! - %s",
-							shard, chain,
-						)
-						err := notify(pdServiceKey, incidentKey, chain, message)
+						err := m.alerts.trigger(alert{
+							Key: incidentKey, Chain: chain, Shard: shard,
+							Check: consensusCheck, Message: message,
+							Severity:  tieredSeverity(timeSinceLastSuccess.Seconds(), m.critical.Consensus),
+							Value:     strconv.FormatInt(int64(timeSinceLastSuccess.Seconds()), 10),
+							Threshold: strconv.FormatUint(warning, 10),
+						})
 						if err != nil {
 							errlog.Print(err)
 						} else {
-							stdlog.Printf("[consensusMonitor] Sent PagerDuty alert! %s", incidentKey)
+							stdlog.Printf("[consensusMonitor] Alert triggered! %s", incidentKey)
 						}
 						consensusStatus[shard] = false
 						continue
 					}
 				}
 			}
+			m.alerts.clear(incidentKey)
 			lastShardData[shard] = lastSuccessfulBlock{currentBlockHeight,
 				time.Unix(currentBlockHeader.Payload.UnixTime, 0).UTC(),
 			}
 			consensusStatus[shard] = true
 		}
 		stdlog.Printf("[consensusMonitor] Total no reply machines: %d", len(monitorData.Down))
-	  for s, b := range consensusStatus {
-			stdlog.Printf("[consensusMonitor] Shard %s, Consensus: %v", s, b)
+		shards := []string{}
+		for s := range consensusStatus {
+			shards = append(shards, s)
+		}
+		sortShardIDs(shards)
+		for _, s := range shards {
+			stdlog.Printf("[consensusMonitor] Shard %s, Consensus: %v", s, consensusStatus[s])
 		}
 
 		m.inUse.Lock()
 		m.consensusProgress = consensusStatus
 		m.inUse.Unlock()
-		replyChannels[BlockHeaderRPC] = make(chan reply, len(shardMap))
+		m.recordConsensus(heights, consensusStatus)
+		aggregate.end(nil)
+		cycle.end(nil)
 	}
 }
 
-func checkShardHeight(b BlockHeaderContainer, syncTimer, tolerance uint64,
-	pdServiceKey, chain string,
+// Sorts the replies of a cycle into headers, failed calls and malformed
+// replies, a shard failing leaves those of the others as they are
+func (m *monitor) blockHeaderReplies(replies <-chan reply, shardMap map[string]int) BlockHeaderContainer {
+	data := BlockHeaderContainer{}
+	for d := range replies {
+		if d.oops != nil {
+			data.Down = append(data.Down,
+				noReply{d.address, d.oops.Error(),
+					string(d.rpcPayload), shardMap[d.address], d.category,
+				},
+			)
+			continue
+		}
+		oneReport := BlockHeaderReply{}
+		if err := decodeResult(BlockHeaderRPC, d.rpcResult, &oneReport); err != nil {
+			data.Malformed = append(data.Malformed,
+				m.malformedReply(d, shardMap[d.address], err),
+			)
+			continue
+		}
+		data.Nodes = append(data.Nodes, BlockHeader{
+			oneReport,
+			d.address,
+		})
+	}
+	return data
+}
+
+// A shard without any reply is missing from the block header summary,
+// flag it so it shows as degraded instead of vanishing from the status.
+// Malformed replies count as replied, checkMalformedReplies has them
+func (m *monitor) checkReachability(chain string, shardMap map[string]int,
+	data BlockHeaderContainer,
+) {
+	tried := map[int]int{}
+	for _, s := range shardMap {
+		tried[s]++
+	}
+	replied := map[int]bool{}
+	for _, n := range data.Nodes {
+		replied[shardMap[n.IP]] = true
+	}
+	for _, d := range data.Malformed {
+		replied[d.ShardID] = true
+	}
+	lastError, down := map[int]string{}, map[int][]string{}
+	for _, d := range data.Down {
+		lastError[d.ShardID] = d.FailureReason
+		down[d.ShardID] = append(down[d.ShardID], d.IP)
+	}
+	shards := []int{}
+	for shard := range tried {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	for _, shard := range shards {
+		count := tried[shard]
+		s := strconv.Itoa(shard)
+		incidentKey := fmt.Sprintf("Shard %s unreachable! - %s", s, chain)
+		m.setDegraded(s, reachabilityCheck, !replied[shard])
+		if replied[shard] {
+			m.alerts.clear(incidentKey)
+			continue
+		}
+		message := fmt.Sprintf(unreachableShardMessage,
+			s, BlockHeaderRPC, count, lastError[shard], failureSummary(data.Down, shard), chain,
+		)
+		err := m.alerts.trigger(alert{
+			Key: incidentKey, Chain: chain, Shard: s,
+			Check: reachabilityCheck, Message: message,
+			Value: "0", Threshold: strconv.Itoa(count),
+			Nodes: down[shard],
+		})
+		if err != nil {
+			errlog.Print(err)
+		} else {
+			stdlog.Printf("[checkReachability] Alert triggered! %s", incidentKey)
+		}
+	}
+}
+
+// Heights are compared to the quantile percent height of the shard, the
+// median by default, so one stuck or lying node can't move the reference.
+// Nodes off by more than tolerance are node-level alerts, the shard alerts
+// once quorum percent of its nodes are off, zero leaves that out
+func (m *monitor) checkShardHeight(b BlockHeaderContainer, syncTimer, tolerance, margin, quorum, quantile uint64,
+	chain string,
 ) {
 	stdlog.Print("[checkShardHeight] Running shard height check")
 	shardHeightMap := make(map[uint32](map[uint64][]BlockHeader))
@@ -144,35 +268,131 @@ func checkShardHeight(b BlockHeaderContainer, syncTimer, tolerance uint64,
 		}
 		shardHeightMap[shard][block] = append(shardHeightMap[shard][block], v)
 	}
-	for i, s := range shardHeightMap {
+	severity := ""
+	if quorum > 0 {
+		severity = severityWarning
+	}
+	shards := []int{}
+	for i := range shardHeightMap {
+		shards = append(shards, int(i))
+	}
+	sort.Ints(shards)
+	for _, shard := range shards {
+		i, s := uint32(shard), shardHeightMap[uint32(shard)]
 		uniqueHeights := []int{}
-		for h, _ := range s {
+		heights := []uint64{}
+		for h, nodes := range s {
 			uniqueHeights = append(uniqueHeights, int(h))
+			for range nodes {
+				heights = append(heights, h)
+			}
 		}
+		sort.Ints(uniqueHeights)
+		reference := quantileHeight(heights, quantile)
 
-		maxHeight := uint64(0)
-		for _, h := range uniqueHeights {
-			if uint64(h) > maxHeight {
-				maxHeight = uint64(h)
+		outliers := 0
+		// Nodes reporting and nodes off by group
+		groupNodes, groupOff := map[string]int{}, map[string]int{}
+		for _, nodes := range s {
+			for _, v := range nodes {
+				groupNodes[m.nodeGroup(v.IP)]++
 			}
 		}
 		for _, h := range uniqueHeights {
-			if maxHeight - uint64(h) > tolerance {
+			height := uint64(h)
+			if height > reference+tolerance {
+				outliers += len(shardHeightMap[i][height])
+				for _, v := range shardHeightMap[i][height] {
+					groupOff[m.nodeGroup(v.IP)]++
+					fields := logFields{Shard: strconv.FormatUint(uint64(i), 10), Check: shardHeightCheck, Node: v.IP}
+					withFields(stdlog, fields).Printf("[checkShardHeight] WARNING %s on shard %d at height %d, %d ahead of shard height %d",
+						m.nodeName(v.IP), i, height, height-reference, reference,
+					)
+				}
+				continue
+			}
+			if height > reference {
+				height = reference
+			}
+			if reference-height > tolerance {
+				outliers += len(shardHeightMap[i][uint64(h)])
+				for _, v := range shardHeightMap[i][uint64(h)] {
+					groupOff[m.nodeGroup(v.IP)]++
+					go m.checkSync(v.IP, chain,
+						v.Payload.BlockNumber, reference, syncTimer, severity)
+				}
+			} else if reference-height+margin <= tolerance {
 				for _, v := range shardHeightMap[i][uint64(h)] {
-					go checkSync(v.IP, pdServiceKey, chain,
-						v.Payload.BlockNumber, maxHeight, syncTimer)
+					m.alerts.clear(fmt.Sprintf("%s out of sync! - %s", v.IP, chain))
 				}
 			}
 		}
-		stdlog.Printf("[checkShardHeight] Shard %d, Max height: %d," +
-				" Number of unique heights: %d, Unique heights: %v",
-				 i, maxHeight, len(uniqueHeights), uniqueHeights,
+		shard := strconv.FormatUint(uint64(i), 10)
+		if quorum > 0 {
+			m.checkHeightQuorum(shard, chain, outliers, len(heights), quorum, tolerance, reference)
+		}
+		if m.limits.Height.set() {
+			m.checkNodesOff(shardHeightCheck, shard, chain, outliers, m.limits.Height,
+				fmt.Sprintf("Shard %s too many nodes out of sync! - %s", shard, chain), heightNodesOffMessage,
+			)
+		}
+		for group, nodes := range groupNodes {
+			off := groupOff[group]
+			m.updateGroupHealth(shard, group, func(h *groupHealth) {
+				h.Reporting, h.OffHeight = nodes, off
+			})
+		}
+		if m.internal.Quorum > 0 && groupNodes[groupInternal] > 0 {
+			m.checkInternalQuorum(shard, chain, groupOff[groupInternal], groupNodes[groupInternal], tolerance, reference)
+		}
+		m.markPolled(shard, shardHeightCheck)
+		stdlog.Printf("[checkShardHeight] Shard %d, Shard height: %d, Nodes off: %d of %d,"+
+			" Number of unique heights: %d, Unique heights: %v",
+			i, reference, outliers, len(heights), len(uniqueHeights), uniqueHeights,
 		)
 	}
 }
 
-func checkSync(IP, pdServiceKey, chain string,
-	blockNumber, shardHeight, syncTimer uint64,
+// Index is count * quantile / 100 of the sorted heights, so an even count
+// compares to the higher of the middle two, of two nodes the higher one.
+// 100 is the max
+func quantileHeight(heights []uint64, quantile uint64) uint64 {
+	if len(heights) == 0 {
+		return 0
+	}
+	if quantile == 0 {
+		quantile = 50
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	index := len(heights) * int(quantile) / 100
+	if index > len(heights)-1 {
+		index = len(heights) - 1
+	}
+	return heights[index]
+}
+
+func (m *monitor) checkHeightQuorum(shard, chain string, outliers, nodes int, quorum, tolerance, reference uint64) {
+	incidentKey := fmt.Sprintf("Shard %s nodes out of sync! - %s", shard, chain)
+	if outliers*100 < int(quorum)*nodes {
+		m.alerts.clear(incidentKey)
+		return
+	}
+	message := fmt.Sprintf(heightQuorumMessage, outliers, nodes, tolerance, reference, shard, chain)
+	err := m.alerts.trigger(alert{
+		Key: incidentKey, Chain: chain, Shard: shard,
+		Check: shardHeightCheck, Message: message,
+		Value:     strconv.Itoa(outliers * 100 / nodes),
+		Threshold: strconv.FormatUint(quorum, 10),
+	})
+	if err != nil {
+		errlog.Print(err)
+	} else {
+		stdlog.Printf("[checkShardHeight] Alert triggered! %s", incidentKey)
+	}
+}
+
+func (m *monitor) checkSync(IP, chain string,
+	blockNumber, shardHeight, syncTimer uint64, severity string,
 ) {
 	stdlog.Printf("[checkSync] Sleeping %d to check IP %s progress", syncTimer, IP)
 	// Check for progress after checking consensus time
@@ -180,29 +400,41 @@ func checkSync(IP, pdServiceKey, chain string,
 
 	requestFields := getRPCRequest(BlockHeaderRPC)
 	requestBody, _ := json.Marshal(requestFields)
-	result, _, err := request("http://"+IP, requestBody)
+	result, _, err := request(rpcScheme+IP, requestBody)
 
 	type r struct {
 		Result BlockHeaderReply `json:"result"`
 	}
 
+	incidentKey := fmt.Sprintf("%s out of sync! - %s", IP, chain)
 	// If invalid reply, no-op
 	if err == nil {
 		reply := r{}
 		json.Unmarshal(result, &reply)
 		if !(reply.Result.BlockNumber > blockNumber) {
+			if reply.Result.BlockNumber < shardHeight {
+				if tier := tieredSeverity(float64(shardHeight-reply.Result.BlockNumber), m.critical.ShardHeight); tier != "" {
+					severity = tier
+				}
+			}
 			message := fmt.Sprintf(blockHeightMessage,
-				IP, reply.Result.BlockNumber, shardHeight, reply.Result.ShardID, chain,
+				m.nodeName(IP), reply.Result.BlockNumber, shardHeight, reply.Result.ShardID, chain,
 			)
-			incidentKey := fmt.Sprintf("%s out of sync! - %s", IP, chain)
-			err := notify(pdServiceKey, incidentKey, chain, message)
+			err := m.alerts.trigger(alert{
+				Key: incidentKey, Chain: chain, Shard: strconv.FormatUint(uint64(reply.Result.ShardID), 10),
+				Check: shardHeightCheck, Message: message, Severity: severity,
+				Value:     strconv.FormatUint(reply.Result.BlockNumber, 10),
+				Threshold: strconv.FormatUint(shardHeight, 10),
+				Nodes:     []string{IP},
+			})
 			if err != nil {
 				errlog.Print(err)
 			} else {
-				stdlog.Printf("[checkSync] Sent PagerDuty alert! %s", incidentKey)
+				stdlog.Printf("[checkSync] Alert triggered! %s", incidentKey)
 			}
 			stdlog.Printf("[checkSync] IP %s is not syncing...", IP)
 		} else {
+			m.alerts.clear(incidentKey)
 			stdlog.Printf("[checkSync] IP %s is syncing...", IP)
 		}
 	}
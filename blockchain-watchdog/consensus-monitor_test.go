@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// A node of shard replying with a block header at height, with its
+// connection dropped instead while down is set
+func blockHeaderNode(t *testing.T, shard int, height uint64) (string, *int32) {
+	down := new(int32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(down) == 1 {
+			panic(http.ErrAbortHandler)
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"0","result":{"blockHash":"0x%x","blockNumber":%d,"shardID":%d}}`,
+			height, height, shard,
+		)
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://"), down
+}
+
+// Records what the alerter posts, closes stand for clears
+type fakeSender struct {
+	events chan string
+}
+
+func (f *fakeSender) send(al alert, title, body string) error {
+	f.events <- "trigger " + al.Key
+	return nil
+}
+
+func (f *fakeSender) close(al alert, note string) error {
+	f.events <- "clear " + al.Key
+	return nil
+}
+
+// Waits for the next post, failing the test when none comes
+func (f *fakeSender) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case e := <-f.events:
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("no alert posted")
+		return ""
+	}
+}
+
+func (f *fakeSender) none(t *testing.T) {
+	t.Helper()
+	select {
+	case e := <-f.events:
+		t.Fatalf("unexpected %s", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// Alerts are posted to the returned sender only, PagerDuty is never paged
+func testAlerter(t *testing.T, m *monitor) *fakeSender {
+	a := newAlerter(watchParams{}, nil)
+	a.pageAfter = 1 << 30
+	sender := &fakeSender{events: make(chan string, 32)}
+	a.addMessenger("webhook", messageTemplate{}, "", sender)
+	a.onChange = m.alertChanged
+	m.alerts = a
+	t.Cleanup(a.shutdownMessengers)
+	return sender
+}
+
+// Polls the nodes of the committees like a consensus cycle does
+type testCycle struct {
+	m        *monitor
+	jobs     chan work
+	channels map[string](chan reply)
+	groups   map[string]*sync.WaitGroup
+}
+
+func newTestCycle(t *testing.T, committees map[int][]string) *testCycle {
+	m := testMonitor(t, 1)
+	byShard := map[int]committee{}
+	for shard, nodes := range committees {
+		byShard[shard] = committee{members: nodes}
+	}
+	m.setShardMap(byShard)
+	c := &testCycle{
+		m:        m,
+		jobs:     make(chan work, 16),
+		channels: map[string](chan reply){},
+		groups:   map[string]*sync.WaitGroup{BlockHeaderRPC: {}},
+	}
+	m.startWorkers("consensus", 4, 1, c.jobs, c.channels, c.groups)
+	return c
+}
+
+func (c *testCycle) poll() BlockHeaderContainer {
+	shardMap := c.m.shardMap()
+	c.channels[BlockHeaderRPC] = make(chan reply, len(shardMap))
+	c.m.queueShards(c.m.ctx, "consensus", c.jobs, shardMap, BlockHeaderRPC, []byte(`{}`), 1,
+		c.groups[BlockHeaderRPC],
+	)
+	c.groups[BlockHeaderRPC].Wait()
+	close(c.channels[BlockHeaderRPC])
+	data := c.m.blockHeaderReplies(c.channels[BlockHeaderRPC], shardMap)
+	c.m.checkReachability("testnet", shardMap, data)
+	c.m.checkMalformedReplies("testnet", BlockHeaderRPC, shardMap, data.Malformed)
+	return data
+}
+
+func repliedShards(data BlockHeaderContainer) map[uint32]int {
+	shards := map[uint32]int{}
+	for _, n := range data.Nodes {
+		shards[n.Payload.ShardID]++
+	}
+	return shards
+}
+
+func TestFailingShardLeavesOthersReported(t *testing.T) {
+	a0, _ := blockHeaderNode(t, 0, 100)
+	b0, _ := blockHeaderNode(t, 0, 100)
+	a1, down1 := blockHeaderNode(t, 1, 200)
+	a2, _ := blockHeaderNode(t, 2, 300)
+	hanging := fakeNode(t, "")
+	atomic.StoreInt32(down1, 1)
+	c := newTestCycle(t, map[int][]string{0: {a0, b0}, 1: {a1, hanging}, 2: {a2}})
+	sender := testAlerter(t, c.m)
+
+	data := c.poll()
+	if got := repliedShards(data); got[0] != 2 || got[2] != 1 || got[1] != 0 {
+		t.Errorf("headers per shard %v, want 2 of shard 0, 1 of shard 2 and none of shard 1", got)
+	}
+	if len(data.Down) != 2 {
+		t.Errorf("%d failed calls, want both nodes of shard 1", len(data.Down))
+	}
+	for _, d := range data.Down {
+		if d.ShardID != 1 {
+			t.Errorf("%s of shard %d counted as failed", d.IP, d.ShardID)
+		}
+	}
+	if e := sender.next(t); e != "trigger Shard 1 unreachable! - testnet" {
+		t.Errorf("posted %q, want shard 1 unreachable", e)
+	}
+	sender.none(t)
+	c.m.inUse.Lock()
+	defer c.m.inUse.Unlock()
+	for shard, want := range map[string]healthState{"0": healthUp, "1": healthDegraded, "2": healthUp} {
+		if got := c.m.checkStates[shard][reachabilityCheck].state; got != want {
+			t.Errorf("shard %s reachability %s, want %s", shard, got, want)
+		}
+	}
+}
+
+func TestShardFullyDownThenBackUp(t *testing.T) {
+	a0, _ := blockHeaderNode(t, 0, 100)
+	a1, downA := blockHeaderNode(t, 1, 200)
+	b1, downB := blockHeaderNode(t, 1, 200)
+	c := newTestCycle(t, map[int][]string{0: {a0}, 1: {a1, b1}})
+	sender := testAlerter(t, c.m)
+	const key = "Shard 1 unreachable! - testnet"
+
+	c.poll()
+	sender.none(t)
+	atomic.StoreInt32(downA, 1)
+	atomic.StoreInt32(downB, 1)
+	for i := 0; i < 3; i++ {
+		// Still polled every cycle, and posted once however long it stays down
+		if got := repliedShards(c.poll()); got[1] != 0 || got[0] != 1 {
+			t.Fatalf("cycle %d headers per shard %v while shard 1 is down", i, got)
+		}
+		if i == 0 {
+			if e := sender.next(t); e != "trigger "+key {
+				t.Fatalf("posted %q, want %s fired", e, key)
+			}
+		}
+	}
+	sender.none(t)
+	if !c.m.alerts.firing(key) {
+		t.Fatal("alert not firing while shard 1 is down")
+	}
+	atomic.StoreInt32(downA, 0)
+	atomic.StoreInt32(downB, 0)
+	if got := repliedShards(c.poll()); got[1] != 2 {
+		t.Fatalf("%d headers of shard 1 once back up, want 2", got[1])
+	}
+	if e := sender.next(t); e != "clear "+key {
+		t.Fatalf("posted %q, want %s resolved", e, key)
+	}
+	if c.m.alerts.firing(key) {
+		t.Error("alert still firing once shard 1 is back up")
+	}
+}
+
+func TestQuantileHeight(t *testing.T) {
+	for _, tc := range []struct {
+		heights  []uint64
+		quantile uint64
+		want     uint64
+	}{
+		{nil, 50, 0},
+		{[]uint64{7}, 50, 7},
+		{[]uint64{20, 10}, 50, 20},
+		{[]uint64{40, 10, 30, 20}, 0, 30},
+		{[]uint64{40, 10, 30, 20}, 25, 20},
+		{[]uint64{30, 10, 20}, 50, 20},
+		{[]uint64{40, 10, 30, 20}, 100, 40},
+		{[]uint64{40, 10, 30, 20}, 1, 10},
+	} {
+		if got := quantileHeight(append([]uint64{}, tc.heights...), tc.quantile); got != tc.want {
+			t.Errorf("quantile %d of %v = %d, want %d", tc.quantile, tc.heights, got, tc.want)
+		}
+	}
+}
@@ -3,12 +3,78 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 )
 
+// Zero disables the respective limit
+type crossLinkAgeLimit struct {
+	Blocks      int     `yaml:"blocks"`
+	Seconds     seconds `yaml:"seconds"`
+	ClearMargin struct {
+		Blocks  int     `yaml:"blocks"`
+		Seconds seconds `yaml:"seconds"`
+	} `yaml:"clear-margin"`
+}
+
+type crossLinkAge struct {
+	LagBlocks  uint64
+	AgeSeconds float64
+}
+
+// Highest block reported per shard by the latest block header poll
+func (m *monitor) shardHeights() map[int]uint64 {
+	heights := map[int]uint64{}
+	m.inUse.Lock()
+	for _, n := range m.BlockHeaderSnapshot.Nodes {
+		shard := int(n.Payload.ShardID)
+		if n.Payload.BlockNumber > heights[shard] {
+			heights[shard] = n.Payload.BlockNumber
+		}
+	}
+	m.inUse.Unlock()
+	return heights
+}
+
+// Wall time the block was made at, from its header as a node of the shard
+// serves it. The nodes are tried in turn until one answers
+func blockTimeOf(shard, number int, shardMap map[string]int) (time.Time, error) {
+	nodes := []string{}
+	for n, s := range shardMap {
+		if s == shard {
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Strings(nodes)
+	requestFields := getRPCRequest(HeaderByNumberRPC)
+	requestFields["params"] = []interface{}{fmt.Sprintf("0x%x", number)}
+	requestBody, _ := json.Marshal(requestFields)
+	err := fmt.Errorf("no node of shard %d monitored", shard)
+	for _, n := range nodes {
+		var result []byte
+		if result, _, err = request(rpcScheme+n, requestBody); err != nil {
+			continue
+		}
+		header := BlockHeaderReply{}
+		if err = decodeResult(BlockHeaderRPC, result, &header); err != nil {
+			continue
+		}
+		if header.UnixTime <= 0 {
+			err = fmt.Errorf("header of block %d from %s has no unixtime", number, n)
+			continue
+		}
+		return time.Unix(header.UnixTime, 0), nil
+	}
+	return time.Time{}, err
+}
+
 // Only need to query leader on Shard 0
-func (m *monitor) crossLinkMonitor(interval, warning uint64, poolSize int, pdServiceKey, chain string, shardMap map[string]int) {
+func (m *monitor) crossLinkMonitor(interval, warning uint64, poolSize int, chain string,
+	ageLimit crossLinkAgeLimit,
+) {
+	shardMap := m.shardMap()
 	crossLinkRequestFields := getRPCRequest(LastCrossLinkRPC)
 	nodeRequestFields := getRPCRequest(NodeMetadataRPC)
 
@@ -28,29 +94,35 @@ func (m *monitor) crossLinkMonitor(interval, warning uint64, poolSize int, pdSer
 		}
 	}
 
-	for i := 0; i < poolSize; i++ {
-		go m.worker(jobs, replyChannels, syncGroups)
-	}
+	m.startWorkers("cross-link", poolSize, int(interval), jobs, replyChannels, syncGroups)
 
 	type r struct {
 		Result NodeMetadataReply `json:"result"`
 	}
 
+	// TS is when the cross link was first seen, BlockTime when its block
+	// was made, zero until a node of the shard served its header
 	type processedCrossLink struct {
 		BlockNum  int
 		CrossLink CrossLink
 		TS        time.Time
+		BlockTime time.Time
+		// Whether a failed lookup of BlockTime was logged
+		missing bool
 	}
 
 	lastProcessed := make(map[int]processedCrossLink)
 	for now := range time.Tick(time.Duration(interval) * time.Second) {
 		stdlog.Print("[crossLinkMonitor] Starting crosslink check")
+		shardMap = m.shardMap()
+		replyChannels[NodeMetadataRPC] = make(chan reply, len(shardMap))
+		replyChannels[LastCrossLinkRPC] = make(chan reply, len(shardMap))
 		// Send requests to find potential shard 0 leaders
 		for k, v := range shardMap {
 			if v == 0 {
 				requestBody, _ := json.Marshal(nodeRequestFields)
-				jobs <- work{k, NodeMetadataRPC, requestBody}
 				syncGroups[NodeMetadataRPC].Add(1)
+				queueAfter(m.ctx, jobs, work{k, NodeMetadataRPC, requestBody, time.Now(), nil, nil}, 0)
 			}
 		}
 		syncGroups[NodeMetadataRPC].Wait()
@@ -70,8 +142,8 @@ func (m *monitor) crossLinkMonitor(interval, warning uint64, poolSize int, pdSer
 		// Request from all potential leaders
 		for _, l := range leader {
 			requestBody, _ := json.Marshal(crossLinkRequestFields)
-			jobs <- work{l, LastCrossLinkRPC, requestBody}
 			syncGroups[LastCrossLinkRPC].Add(1)
+			queueAfter(m.ctx, jobs, work{l, LastCrossLinkRPC, requestBody, time.Now(), nil, nil}, 0)
 		}
 		syncGroups[LastCrossLinkRPC].Wait()
 		close(replyChannels[LastCrossLinkRPC])
@@ -81,6 +153,8 @@ func (m *monitor) crossLinkMonitor(interval, warning uint64, poolSize int, pdSer
 			if i.oops == nil {
 				json.Unmarshal(i.rpcResult, &crossLinks)
 				for _, result := range crossLinks.CrossLinks {
+					m.markPolled(strconv.Itoa(result.ShardID), crossLinkCheck)
+					incidentKey := fmt.Sprintf("Chain: %s, Shard %d, CrossLinkMonitor", chain, result.ShardID)
 					if entry, exists := lastProcessed[result.ShardID]; exists {
 						elapsedTime := now.Sub(entry.TS)
 						if result.BlockNumber <= entry.BlockNum {
@@ -89,33 +163,90 @@ func (m *monitor) crossLinkMonitor(interval, warning uint64, poolSize int, pdSer
 									result.Hash, result.ShardID, result.BlockNumber, result.ShardID,
 									result.EpochNumber, result.Signature, result.SignatureBitmap,
 									elapsedTime.Seconds(), elapsedTime.Minutes())
-								incidentKey := fmt.Sprintf("Chain: %s, Shard %d, CrossLinkMonitor", chain, result.ShardID)
-								err := notify(pdServiceKey, incidentKey, chain, message)
+								err := m.alerts.trigger(alert{
+									Key: incidentKey, Chain: chain, Shard: strconv.Itoa(result.ShardID),
+									Check: crossLinkCheck, Message: message,
+									Severity:  tieredSeverity(elapsedTime.Seconds(), m.critical.CrossLink),
+									Value:     strconv.FormatInt(int64(elapsedTime.Seconds()), 10),
+									Threshold: strconv.FormatUint(warning, 10),
+								})
 								if err != nil {
 									errlog.Print(err)
 								} else {
-									stdlog.Printf("[crossLinkMonitor] Sent PagerDuty alert! %s", incidentKey)
+									stdlog.Printf("[crossLinkMonitor] Alert triggered! %s", incidentKey)
 								}
 							}
 							continue
 						}
 					}
+					m.alerts.clear(incidentKey)
 					lastProcessed[result.ShardID] = processedCrossLink{
-						result.BlockNumber,
-						result,
-						now,
+						BlockNum:  result.BlockNumber,
+						CrossLink: result,
+						TS:        now,
 					}
 				}
 				break
 			}
 		}
+		heights := m.shardHeights()
+		ages := map[int]crossLinkAge{}
 		for s, c := range lastProcessed {
 			stdlog.Printf("[crossLinkMonitor] Shard: %d, Last Crosslink: %v", s, c)
+			if c.BlockTime.IsZero() {
+				made, err := blockTimeOf(s, c.BlockNum, shardMap)
+				if err != nil && !c.missing {
+					stdlog.Printf("[crossLinkMonitor] WARNING No time of shard %d block %d, its age is counted from"+
+						" when the cross link was seen, Error: %v", s, c.BlockNum, err,
+					)
+				}
+				c.BlockTime, c.missing = made, err != nil
+				lastProcessed[s] = c
+			}
+			// Since the block was made, at least since the cross link was seen
+			made := c.TS
+			if !c.BlockTime.IsZero() {
+				made = c.BlockTime
+			}
+			age := crossLinkAge{AgeSeconds: now.Sub(made).Seconds()}
+			if height := heights[s]; height > uint64(c.BlockNum) {
+				age.LagBlocks = height - uint64(c.BlockNum)
+			}
+			ages[s] = age
+			blocksExceeded := ageLimit.Blocks > 0 && age.LagBlocks > uint64(ageLimit.Blocks)
+			secondsExceeded := ageLimit.Seconds > 0 && age.AgeSeconds > float64(ageLimit.Seconds)
+			blocksCleared := ageLimit.Blocks == 0 ||
+				age.LagBlocks+uint64(ageLimit.ClearMargin.Blocks) <= uint64(ageLimit.Blocks)
+			secondsCleared := ageLimit.Seconds == 0 ||
+				age.AgeSeconds+float64(ageLimit.ClearMargin.Seconds) <= float64(ageLimit.Seconds)
+			incidentKey := fmt.Sprintf("Chain: %s, Shard %d, CrossLinkAgeMonitor", chain, s)
+			breach := m.alerts.breached(incidentKey,
+				blocksExceeded || secondsExceeded, blocksCleared && secondsCleared,
+			)
+			m.setDegraded(strconv.Itoa(s), crossLinkCheck, breach)
+			if breach {
+				message := fmt.Sprintf(crossLinkAgeMessage, s,
+					c.CrossLink.Hash, s, c.BlockNum, heights[s], age.LagBlocks, ageLimit.Blocks,
+					age.AgeSeconds, ageLimit.Seconds,
+				)
+				err := m.alerts.trigger(alert{
+					Key: incidentKey, Chain: chain, Shard: strconv.Itoa(s),
+					Check: crossLinkCheck, Message: message,
+					Value:     fmt.Sprintf("%d blocks, %.0fs", age.LagBlocks, age.AgeSeconds),
+					Threshold: fmt.Sprintf("%d blocks, %ds", ageLimit.Blocks, ageLimit.Seconds),
+				})
+				if err != nil {
+					errlog.Print(err)
+				} else {
+					stdlog.Printf("[crossLinkMonitor] Alert triggered! %s", incidentKey)
+				}
+			} else {
+				m.alerts.clear(incidentKey)
+			}
 		}
-		replyChannels[NodeMetadataRPC] = make(chan reply, len(shardMap))
-		replyChannels[LastCrossLinkRPC] = make(chan reply, len(shardMap))
 		m.inUse.Lock()
 		m.LastCrossLinks.CrossLinks = append([]CrossLink{}, crossLinks.CrossLinks...)
+		m.crossLinkAges = ages
 		m.inUse.Unlock()
 	}
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlockTimeOf(t *testing.T) {
+	configureRPCClient(1, "", "", "", "")
+	var asked struct {
+		Method string        `json:"method"`
+		Params []interface{} `json:"params"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &asked)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":"1","result":` +
+			`{"blockHash":"0xab","blockNumber":4096,"shardID":1,"unixtime":1620122400}}`))
+	}))
+	defer server.Close()
+	node := strings.TrimPrefix(server.URL, "http://")
+	down := fakeNode(t, "null")
+
+	made, err := blockTimeOf(1, 4096, map[string]int{down: 1, node: 1, "10.0.0.1:9500": 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Unix(1620122400, 0); !made.Equal(want) {
+		t.Errorf("block made %s, want %s", made, want)
+	}
+	if asked.Method != HeaderByNumberRPC || len(asked.Params) != 1 || asked.Params[0] != "0x1000" {
+		t.Errorf("asked %s %v, want %s [0x1000]", asked.Method, asked.Params, HeaderByNumberRPC)
+	}
+	if _, err := blockTimeOf(2, 1, map[string]int{node: 1}); err == nil {
+		t.Error("no error for a shard without nodes")
+	}
+}
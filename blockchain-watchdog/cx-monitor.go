@@ -1,15 +1,17 @@
 package main
 
 import (
-  "encoding/json"
-  "fmt"
-  "sync"
-  "time"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
 )
 
-func (m *monitor) cxMonitor(interval, limit uint64, poolSize int,
-  pdServiceKey, chain string, shardMap map[string]int,
+func (m *monitor) cxMonitor(interval, limit, margin uint64, poolSize int,
+	chain string,
 ) {
+	shardMap := m.shardMap()
 	cxRequestFields := getRPCRequest(PendingCXRPC)
 	nodeRequestFields := getRPCRequest(NodeMetadataRPC)
 
@@ -28,9 +30,7 @@ func (m *monitor) cxMonitor(interval, limit uint64, poolSize int,
 		}
 	}
 
-	for i := 0; i < poolSize; i++ {
-		go m.worker(jobs, replyChannels, syncGroups)
-	}
+	m.startWorkers("cx-pending", poolSize, int(interval), jobs, replyChannels, syncGroups)
 
 	type r struct {
 		Result NodeMetadataReply `json:"result"`
@@ -41,12 +41,15 @@ func (m *monitor) cxMonitor(interval, limit uint64, poolSize int,
 	}
 
 	for range time.Tick(time.Duration(interval) * time.Second) {
-    stdlog.Print("[cxMonitor] Starting cross shard transaction check")
+		stdlog.Print("[cxMonitor] Starting cross shard transaction check")
+		shardMap = m.shardMap()
+		replyChannels[NodeMetadataRPC] = make(chan reply, len(shardMap))
+		replyChannels[PendingCXRPC] = make(chan reply, len(shardMap))
 		// Send requests to find potential shard leaders
 		for n := range shardMap {
 			requestBody, _ := json.Marshal(nodeRequestFields)
-			jobs <- work{n, NodeMetadataRPC, requestBody}
 			syncGroups[NodeMetadataRPC].Add(1)
+			queueAfter(m.ctx, jobs, work{n, NodeMetadataRPC, requestBody, time.Now(), nil, nil}, 0)
 		}
 		syncGroups[NodeMetadataRPC].Wait()
 		close(replyChannels[NodeMetadataRPC])
@@ -68,8 +71,8 @@ func (m *monitor) cxMonitor(interval, limit uint64, poolSize int,
 		for _, node := range leaders {
 			for _, n := range node {
 				requestBody, _ := json.Marshal(cxRequestFields)
-				jobs <- work{n, PendingCXRPC, requestBody}
 				syncGroups[PendingCXRPC].Add(1)
+				queueAfter(m.ctx, jobs, work{n, PendingCXRPC, requestBody, time.Now(), nil, nil}, 0)
 			}
 		}
 		syncGroups[PendingCXRPC].Wait()
@@ -90,29 +93,35 @@ func (m *monitor) cxMonitor(interval, limit uint64, poolSize int,
 					}
 				}
 				cxPoolSize[shard] = append(cxPoolSize[shard], report.Result)
-				if report.Result > limit {
+				incidentKey := fmt.Sprintf(
+					"Shard %d cx pool size greater than pending limit! - %s",
+					shard, chain,
+				)
+				if m.alerts.breached(incidentKey, report.Result > limit, report.Result+margin <= limit) {
 					message := fmt.Sprintf(crossShardTransactionMessage,
-            shard, report.Result,
-          )
-					incidentKey := fmt.Sprintf(
-            "Shard %d cx pool size greater than pending limit! - %s",
-            shard, chain,
-          )
-					err := notify(pdServiceKey, incidentKey, chain, message)
+						shard, report.Result,
+					)
+					err := m.alerts.trigger(alert{
+						Key: incidentKey, Chain: chain, Shard: strconv.Itoa(shard),
+						Check: cxPendingCheck, Message: message,
+						Severity:  tieredSeverity(float64(report.Result), m.critical.CxPending),
+						Value:     strconv.FormatUint(report.Result, 10),
+						Threshold: strconv.FormatUint(limit, 10),
+					})
 					if err != nil {
 						errlog.Print(err)
 					} else {
-						stdlog.Printf("[cxMonitor] Sent PagerDuty alert: %s", incidentKey)
+						stdlog.Printf("[cxMonitor] Alert triggered! %s", incidentKey)
 					}
+				} else {
+					m.alerts.clear(incidentKey)
 				}
 			}
 		}
 
-    for i, v := range cxPoolSize {
-      stdlog.Printf("[cxMonitor] Shard: %d, Pending cross shard transaction pool size: %d", i, v)
-    }
-
-		replyChannels[NodeMetadataRPC] = make(chan reply, len(shardMap))
-		replyChannels[PendingCXRPC] = make(chan reply, len(shardMap))
+		for i, v := range cxPoolSize {
+			m.markPolled(strconv.Itoa(i), cxPendingCheck)
+			stdlog.Printf("[cxMonitor] Shard: %d, Pending cross shard transaction pool size: %d", i, v)
+		}
 	}
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cycles the rolling average is taken over
+const cycleWindow = 10
+
+var (
+	cycleDuration = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "watchdog",
+			Name:      "inspection_cycle_seconds",
+			Help:      "Wall time of the last inspection cycle, shard all is the whole cycle",
+		},
+		[]string{"chain", "cycle", "shard"},
+	)
+	cycleAverage = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "watchdog",
+			Name:      "inspection_cycle_avg_seconds",
+			Help:      "Rolling average wall time of the last inspection cycles, shard all is the whole cycle",
+		},
+		[]string{"chain", "cycle", "shard"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cycleDuration, cycleAverage)
+}
+
+type cycleTimes struct {
+	Last    float64 `json:"last-seconds"`
+	Average float64 `json:"avg-seconds"`
+	recent  []float64
+}
+
+func (c *cycleTimes) add(seconds float64) {
+	c.Last = seconds
+	c.recent = append(c.recent, seconds)
+	if len(c.recent) > cycleWindow {
+		c.recent = c.recent[1:]
+	}
+	sum := 0.0
+	for _, s := range c.recent {
+		sum += s
+	}
+	c.Average = sum / float64(len(c.recent))
+}
+
+type cycleReport struct {
+	cycleTimes
+	Interval int                   `json:"interval-seconds"`
+	Overruns int                   `json:"overruns"`
+	Shards   map[string]cycleTimes `json:"shards"`
+}
+
+// Nodes are polled in block-header and node-metadata cycles
+func cycleName(rpc string) string {
+	for check, r := range rpcMethodChecks {
+		if r == rpc {
+			return check
+		}
+	}
+	return rpc
+}
+
+// A shard took until its last reply arrived. A cycle running past its
+// interval means the next one starts late
+func (m *monitor) recordCycle(rpc string, interval int, start time.Time, shardDone map[string]time.Time) {
+	name := cycleName(rpc)
+	total := time.Since(start).Seconds()
+	m.inUse.Lock()
+	if m.cycles == nil {
+		m.cycles = map[string]*cycleReport{}
+	}
+	c, exists := m.cycles[name]
+	if !exists {
+		c = &cycleReport{Shards: map[string]cycleTimes{}}
+		m.cycles[name] = c
+	}
+	c.Interval = interval
+	c.add(total)
+	overrun := total > float64(interval)
+	if overrun {
+		c.Overruns++
+	}
+	cycleDuration.WithLabelValues(m.chain, name, "all").Set(total)
+	cycleAverage.WithLabelValues(m.chain, name, "all").Set(c.Average)
+	shards := map[string]float64{}
+	for shard, done := range shardDone {
+		s := c.Shards[shard]
+		shards[shard] = done.Sub(start).Seconds()
+		s.add(shards[shard])
+		c.Shards[shard] = s
+		cycleDuration.WithLabelValues(m.chain, name, shard).Set(s.Last)
+		cycleAverage.WithLabelValues(m.chain, name, shard).Set(s.Average)
+	}
+	average := c.Average
+	m.inUse.Unlock()
+	m.statsd.recordCycle(name, total, shards)
+	if overrun {
+		stdlog.Printf("[recordCycle] WARNING %s cycle took %.2fs, longer than its %ds interval (avg %.2fs),"+
+			" consider raising num-workers or the interval",
+			name, total, interval, average,
+		)
+	}
+}
+
+// Expects m.inUse to be held
+func (m *monitor) cyclesSnapshot() map[string]cycleReport {
+	snapshot := map[string]cycleReport{}
+	for name, c := range m.cycles {
+		shards := map[string]cycleTimes{}
+		for shard, s := range c.Shards {
+			shards[shard] = s
+		}
+		snapshot[name] = cycleReport{c.cycleTimes, c.Interval, c.Overruns, shards}
+	}
+	return snapshot
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/takama/daemon"
@@ -47,26 +52,52 @@ func (cw *cobraSrvWrapper) stop(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// Exit code reflects the aggregate network health reported by the daemon
 func (cw *cobraSrvWrapper) status(cmd *cobra.Command, args []string) error {
 	r, err := cw.Status()
 	if err != nil {
 		return err
 	}
 	fmt.Println(r)
+	health, err := fetchHealth(cw.HTTPReporter.Port, cw.HTTPReporter.UnixSocket, cw.HTTPReporter.Admin,
+		cw.Network.TargetChain,
+	)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Network health: %s %v\n", health.Status, health.Counts)
+	os.Exit(healthExitCodes[health.Status])
 	return nil
 }
 
 // NOTE Important function because downstream commands assume results of it
 func (cw *cobraSrvWrapper) preRunInit(cmd *cobra.Command, args []string) error {
-	instr, err := newInstructions(monitorNodeYAML)
+	flags, err := logFlags()
 	if err != nil {
 		return err
 	}
-	dm, err := daemon.New(
-		fmt.Sprintf(nameFMT, instr.Network.TargetChain),
-		description,
-		dependencies...,
-	)
+	logs.configure(flags)
+	if err := openRecording(); err != nil {
+		return err
+	}
+	yamlPath, err := resolveConfigPath(monitorNodeYAML)
+	if err != nil {
+		return err
+	}
+	// Resolved so the installed service finds it from any working directory
+	monitorNodeYAML = yamlPath
+	instr, err := newInstructions(yamlPath)
+	if err != nil {
+		return err
+	}
+	// The flags take precedence over the yaml config
+	logs.configure(instr.Logging)
+	logs.configure(flags)
+	name, descr, err := serviceUnit(instr.Network.TargetChain)
+	if err != nil {
+		return err
+	}
+	dm, err := daemon.New(name, descr, dependencies...)
 	if err != nil {
 		return err
 	}
@@ -84,9 +115,57 @@ func (cw *cobraSrvWrapper) start(cmd *cobra.Command, args []string) error {
 }
 
 func (cw *cobraSrvWrapper) doMonitor(cmd *cobra.Command, args []string) error {
+	if err := logs.toFile(cw.Logging); err != nil {
+		return err
+	}
+	audit, err := openAuditLog(cw.Alerting.AuditLog)
+	if err != nil {
+		return err
+	}
 	cw.monitor = &monitor{
 		chain:             cw.Network.TargetChain,
 		consensusProgress: map[string]bool{},
+		alerts:            newAlerter(cw.watchParams, audit),
+		discovery:         cw.DistributionFiles.RPCDiscovery,
+		selection:         cw.DistributionFiles.Selection,
+		countChange:       cw.DistributionFiles.CountChange,
+		rpcPort:           cw.Network.RPCPort,
+		jitter:            cw.InspectSchedule.Jitter,
+		pollIntervals:     pollIntervals(cw.watchParams),
+		checks:            enabledChecks(cw.watchParams),
+		warmUp:            cw.ShardHealthReporting.WarmUp.Samples,
+		critical:          criticalOf(cw.watchParams),
+		suppress:          cw.Alerting.Suppress,
+		limits: nodeLimits{
+			Connectivity: cw.ShardHealthReporting.Connectivity.NodesOff,
+			Height:       cw.ShardHealthReporting.ShardHeight.NodesOff,
+		},
+		internal: internalParams{
+			Connectivity: cw.ShardHealthReporting.Connectivity.Internal.Tolerance,
+			Quorum:       cw.ShardHealthReporting.ShardHeight.Internal.Quorum,
+		},
+	}
+	cw.monitor.ctx, cw.monitor.cancel = context.WithCancel(context.Background())
+	cw.monitor.tracer = newTracer(cw.Tracing.OTLP, cw.Network.TargetChain)
+	cw.monitor.statsd = newStatsdEmitter(cw.StatsD, cw.Network.TargetChain)
+	cw.monitor.influx = newInfluxWriter(cw.InfluxDB, cw.Network.TargetChain)
+	cw.monitor.alerts.onChange = cw.monitor.alertChanged
+	cw.monitor.alerts.suppresses = cw.monitor.suppressedNode
+	if replaying != nil && !replayAlerts {
+		dryRun = true
+	}
+	cw.monitor.alerts.dryRun = dryRun
+	if dryRun {
+		stdlog.Print("[doMonitor] Dry run, alerts are logged instead of sent")
+	}
+	// Snapshots are taken and replayed on the block-header schedule
+	go recording.run(cw.InspectSchedule.BlockHeader.duration())
+	go replaying.run(cw.InspectSchedule.BlockHeader.duration())
+	if cw.Performance.MaxShards > 0 {
+		cw.monitor.shardSlots = make(chan struct{}, cw.Performance.MaxShards)
+	}
+	if err := cw.selfCheck(); err != nil {
+		return err
 	}
 	return cw.monitorNetwork()
 }
@@ -99,49 +178,289 @@ func monitorCmd() *cobra.Command {
 		RunE:              w.doMonitor,
 	}
 	monitorCmd.Flags().StringVar(&monitorNodeYAML, mFlag, "", mDescr)
+	monitorCmd.Flags().BoolVar(&dryRun, "dry-run", false, "log each alert instead of sending it")
+	monitorCmd.Flags().StringVar(&recordPath, "record", "", "append a snapshot of the RPC replies of each block-header interval to the file")
+	monitorCmd.Flags().StringVar(&replayPath, "replay", "", "answer RPC calls from the snapshots of a --record file instead of the nodes")
+	monitorCmd.Flags().BoolVar(&replayAlerts, "replay-alerts", false, "send alerts while replaying instead of logging them")
+	monitorCmd.Flags().StringVar(&logLevel, "log-level", "", "lowest level logged, info, warn or error, overrides logging, level")
+	monitorCmd.Flags().StringVar(&logFormat, "log-format", "", "text or json lines, overrides logging, format")
 	monitorCmd.MarkFlagRequired(mFlag)
 	return monitorCmd
 }
 
+// Config is already loaded and sanity checked by preRunInit
+func (cw *cobraSrvWrapper) validate(cmd *cobra.Command, args []string) error {
+	fmt.Printf("Config OK: %s\n", monitorNodeYAML)
+	fmt.Printf("Target chain: %s\n", cw.Network.TargetChain)
+	for _, s := range sortedShards(cw.superCommittee) {
+		if c := cw.superCommittee[s]; c.loadBalanced {
+			fmt.Printf("Shard %d: load-balanced endpoint %s, node-level checks skipped\n", s, c.members[0])
+			continue
+		}
+		fmt.Printf("Shard %d: %d nodes\n", s, len(cw.superCommittee[s].members))
+	}
+	checks := enabledChecks(cw.watchParams)
+	names := []string{}
+	for c := range checks {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	fmt.Println("Checks:")
+	for _, c := range names {
+		state := "enabled"
+		if !checks[c] {
+			state = "disabled"
+		}
+		fmt.Printf("  %s: %s\n", c, state)
+	}
+	printRPCMethods()
+	return nil
+}
+
+func validateCmd() *cobra.Command {
+	validate := &cobra.Command{
+		Use:               "validate",
+		Short:             "check the yaml config and show what would be watched",
+		PersistentPreRunE: w.preRunInit,
+		RunE:              w.validate,
+	}
+	validate.Flags().StringVar(&monitorNodeYAML, mFlag, "", mDescr)
+	validate.MarkFlagRequired(mFlag)
+	return validate
+}
+
+type listedNode struct {
+	Address string `json:"address"`
+	Label   string `json:"label,omitempty"`
+	Group   string `json:"group,omitempty"`
+}
+
+type listedShard struct {
+	ShardID int          `json:"shard-id"`
+	Source  string       `json:"source"`
+	Count   int          `json:"count"`
+	Members []listedNode `json:"members"`
+}
+
+// Members keep the order of their distribution file
+func listedShards(byShard map[int]committee) []listedShard {
+	shards := []listedShard{}
+	for _, id := range sortedShards(byShard) {
+		c := byShard[id]
+		s := listedShard{id, c.file, len(c.members), []listedNode{}}
+		for _, address := range c.members {
+			s.Members = append(s.Members, listedNode{address, c.labels[address], c.groups[address]})
+		}
+		shards = append(shards, s)
+	}
+	return shards
+}
+
+func listNodesCmd() *cobra.Command {
+	asJSON := false
+	listNodes := &cobra.Command{
+		Use:   "list-nodes <yaml>",
+		Short: "print the nodes of each shard as parsed from the yaml config",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Keeps rpc-discovery logs out of the listing
+			logs.stdout = os.Stderr
+			yamlPath, err := resolveConfigPath(args[0])
+			if err != nil {
+				return err
+			}
+			instr, err := newInstructions(yamlPath)
+			if err != nil {
+				return err
+			}
+			shards := listedShards(instr.superCommittee)
+			if asJSON {
+				out, _ := json.MarshalIndent(shards, "", "  ")
+				fmt.Println(string(out))
+				return nil
+			}
+			for _, s := range shards {
+				fmt.Printf("Shard %d: %d nodes from %s\n", s.ShardID, s.Count, s.Source)
+				for _, n := range s.Members {
+					line := n.Address
+					if n.Label != "" {
+						line += " " + n.Label
+					}
+					if n.Group != "" {
+						line += " [" + n.Group + "]"
+					}
+					fmt.Printf("  %s\n", line)
+				}
+			}
+			return nil
+		},
+	}
+	listNodes.Flags().BoolVar(&asJSON, "json", false, "print the nodes as json")
+	return listNodes
+}
+
 func generateSampleYAML() *cobra.Command {
 	generateSample := &cobra.Command{
 		Use:   "generate-sample",
 		Short: "print sample yaml config file",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			sampleParams := watchParams{}
-			sampleParams.Auth.PagerDuty.EventServiceKey = "YOUR_PAGERDUTY_KEY"
-			sampleParams.Network.TargetChain = "mainnet"
-			sampleParams.Network.RPCPort = 9500
-			sampleParams.InspectSchedule.BlockHeader = 15
-			sampleParams.InspectSchedule.NodeMetadata = 30
-			sampleParams.InspectSchedule.CxPending = 300
-			sampleParams.InspectSchedule.CrossLink = 30
-			sampleParams.Performance.WorkerPoolSize = 32
-			sampleParams.Performance.HTTPTimeout = 1
-			sampleParams.HTTPReporter.Port = 8080
-			sampleParams.ShardHealthReporting.Consensus.Interval = 30
-			sampleParams.ShardHealthReporting.Consensus.Warning = 70
-			sampleParams.ShardHealthReporting.CxPending.Warning = 1000
-			sampleParams.ShardHealthReporting.CrossLink.Warning = 600
-			sampleParams.ShardHealthReporting.ShardHeight.Warning = 1000
-			sampleParams.ShardHealthReporting.Connectivity.Warning = 33
-			sampleParams.DistributionFiles.MachineIPList = []string{
-				"/home/ec2_user/mainnet/shard0.txt",
-				"/home/ec2_user/mainnet/shard1.txt",
-				"/home/ec2_user/mainnet/shard2.txt",
-				"/home/ec2_user/mainnet/shard3.txt",
-			}
-			sampleConfig, err := yaml.Marshal(sampleParams)
+			sample, err := sampleYAML()
 			if err != nil {
 				return err
 			}
-			fmt.Println(string(sampleConfig))
+			fmt.Println(sample)
 			return nil
 		},
 	}
 	return generateSample
 }
 
+// Every option with an example value and its comment, the optional alert
+// channels commented out
+func sampleYAML() (string, error) {
+	sampleParams := watchParams{}
+	sampleParams.Auth.PagerDuty.EventServiceKey = "YOUR_PAGERDUTY_KEY"
+	sampleParams.Auth.Slack.WebhookURL = "https://hooks.slack.com/services/YOUR/SLACK/WEBHOOK"
+	sampleParams.Auth.Slack.CheckWebhooks = map[string]string{
+		shardHeightCheck: "https://hooks.slack.com/services/YOUR/OPS/WEBHOOK",
+	}
+	sampleParams.Auth.Telegram.BotToken = "YOUR_TELEGRAM_BOT_TOKEN"
+	sampleParams.Auth.Telegram.ChatID = "-1001234567890"
+	sampleParams.Auth.Discord.WebhookURL = "https://discord.com/api/webhooks/YOUR/DISCORD/WEBHOOK"
+	sampleParams.Auth.Teams.WebhookURL = "https://example.webhook.office.com/webhookb2/YOUR/TEAMS/WEBHOOK"
+	sampleParams.Auth.OpsGenie.APIKey = "YOUR_OPSGENIE_API_KEY"
+	sampleParams.Auth.OpsGenie.Team = "harmony-ops"
+	sampleParams.Auth.OpsGenie.CheckTeams = map[string]string{shardHeightCheck: "node-operators"}
+	sampleParams.Auth.Webhook.URLs = []webhookURL{{
+		URL: "https://incidents.example.com/hooks/watchdog", SigningSecret: "YOUR_SIGNING_SECRET",
+	}}
+	sampleParams.Auth.Webhook.Headers = map[string]string{"Authorization": "Bearer YOUR_TOKEN"}
+	sampleParams.Auth.Twilio = twilioParams{
+		AccountSID: "YOUR_TWILIO_ACCOUNT_SID", AuthToken: "YOUR_TWILIO_AUTH_TOKEN",
+		From: "+14155550100", To: []string{"+14155550199"},
+	}
+	sampleParams.Auth.Email = emailParams{
+		Host: "smtp.example.com", Port: defaultEmailPort, TLS: emailStartTLS,
+		Username: "watchdog", Password: "YOUR_SMTP_PASSWORD",
+		From: "watchdog@example.com", To: []string{"oncall@example.com"},
+	}
+	sampleParams.Alerting.Routing = alertRoutes{{
+		Shards: []int{0}, Checks: []string{consensusCheck, crossLinkCheck},
+		EventServiceKey: "YOUR_BEACON_PAGERDUTY_KEY", SlackWebhook: "https://hooks.slack.com/services/YOUR/BEACON/WEBHOOK",
+	}}
+	sampleParams.Alerting.Maintenance = []maintenanceWindow{{
+		Shards: []int{3}, Nodes: []string{"10.0.0.12"},
+		Start:  time.Date(2030, 1, 15, 2, 0, 0, 0, time.UTC),
+		End:    time.Date(2030, 1, 15, 4, 0, 0, 0, time.UTC),
+		Reason: "Node upgrade",
+	}}
+	sampleParams.Alerting.Suppress = suppressParams{
+		Nodes: []string{"10.0.0.99"}, Labels: []string{"decommissioned"},
+	}
+	sampleParams.Alerting.RateLimit = rateLimitParams{
+		PerMinute: 20, Checks: map[string]int{shardHeightCheck: 5, reachabilityCheck: 5},
+	}
+	sampleParams.Alerting.NotifyOnRecovery = true
+	autoResolve := true
+	sampleParams.Alerting.AutoResolve = &autoResolve
+	sampleParams.Alerting.Severity = severityError
+	sampleParams.Alerting.DedupStrategy = dedupPerCheck
+	sampleParams.Alerting.GroupWindow = 3
+	sampleParams.Alerting.RetriggerInterval = 300
+	sampleParams.Alerting.PageAfterChecks = 3
+	sampleParams.Alerting.AckPeriod = seconds(defaultAckPeriod / time.Second)
+	sampleParams.Alerting.DeliveryFailures = defaultDeliveryFailures
+	sampleParams.Alerting.AuditLog = "/var/log/watchdog/alerts-audit.jsonl"
+	sampleParams.Alerting.Templates.PagerDuty.Subject = "[{{.Severity}}] {{.Check}} on shard {{.Shard}} - {{.Chain}}"
+	sampleParams.Network.TargetChain = "mainnet"
+	sampleParams.Network.RPCPort = 9500
+	sampleParams.Network.RPCMethods = map[string]string{"block-header": BlockHeaderRPC}
+	sampleParams.InspectSchedule.BlockHeader = 15
+	sampleParams.InspectSchedule.NodeMetadata = 30
+	sampleParams.InspectSchedule.CxPending = 300
+	sampleParams.InspectSchedule.CrossLink = 30
+	sampleParams.InspectSchedule.Jitter = 10
+	sampleParams.Performance.WorkerPoolSize = 32
+	sampleParams.Performance.MaxShards = 2
+	sampleParams.Performance.HTTPTimeout = 1
+	sampleParams.HTTPReporter.Port = 8080
+	sampleParams.HTTPReporter.MaxConnections = defaultMaxConnections
+	sampleParams.HTTPReporter.CORSOrigins = []string{"https://dashboard.example.com"}
+	sampleParams.GRPCReporter.Port = 8081
+	sampleParams.Tracing.OTLP.Endpoint = "http://localhost:4318/v1/traces"
+	sampleParams.Tracing.OTLP.Headers = map[string]string{"x-api-key": "YOUR_COLLECTOR_KEY"}
+	sampleParams.StatsD = statsdParams{Host: "localhost", Port: 8125, Prefix: defaultStatsdPrefix, Tags: true}
+	sampleParams.InfluxDB = influxParams{
+		URL: "http://localhost:8086", Version: 2, Token: "YOUR_INFLUXDB_TOKEN", Org: "harmony", Bucket: "watchdog",
+	}
+	sampleParams.Logging = logParams{
+		Level: levelInfo, Format: logText, File: "/var/log/watchdog/watchdog.log",
+		logRotation: logRotation{MaxSize: 100, Interval: 86400, MaxAge: 30, MaxBackups: 10},
+	}
+	sampleParams.ShardHealthReporting.Consensus.Interval = 30
+	sampleParams.ShardHealthReporting.Consensus.Warning = 70
+	sampleParams.ShardHealthReporting.Consensus.Critical = 300
+	sampleParams.ShardHealthReporting.CxPending.Warning = 1000
+	sampleParams.ShardHealthReporting.CxPending.Critical = 5000
+	sampleParams.ShardHealthReporting.CxPending.ClearMargin = 100
+	sampleParams.ShardHealthReporting.CrossLink.Warning = 600
+	sampleParams.ShardHealthReporting.CrossLink.Critical = 1800
+	sampleParams.ShardHealthReporting.CrossLink.AgeLimit.Blocks = 100
+	sampleParams.ShardHealthReporting.CrossLink.AgeLimit.Seconds = 900
+	sampleParams.ShardHealthReporting.CrossLink.AgeLimit.ClearMargin.Blocks = 10
+	sampleParams.ShardHealthReporting.CrossLink.AgeLimit.ClearMargin.Seconds = 60
+	sampleParams.ShardHealthReporting.ShardHeight.Warning = 1000
+	sampleParams.ShardHealthReporting.ShardHeight.Critical = 5000
+	sampleParams.ShardHealthReporting.ShardHeight.ClearMargin = 100
+	sampleParams.ShardHealthReporting.ShardHeight.Quorum = 67
+	sampleParams.ShardHealthReporting.ShardHeight.Quantile = 50
+	sampleParams.ShardHealthReporting.ShardHeight.NodesOff = nodeLimit{Ratio: 0.33}
+	sampleParams.ShardHealthReporting.ShardHeight.Internal.Quorum = 34
+	sampleParams.ShardHealthReporting.Connectivity.Warning = 33
+	sampleParams.ShardHealthReporting.Connectivity.ClearMargin = 5
+	sampleParams.ShardHealthReporting.Connectivity.NodesOff = nodeLimit{Count: 3}
+	sampleParams.ShardHealthReporting.Connectivity.Internal.Tolerance = 50
+	sampleParams.ShardHealthReporting.BlockTime.Target = 5
+	sampleParams.ShardHealthReporting.BlockTime.Tolerance = 20
+	sampleParams.ShardHealthReporting.BlockTime.Window = 10
+	sampleParams.ShardHealthReporting.BlockTime.ClearMargin = 5
+	sampleParams.ShardHealthReporting.WarmUp.Samples = 5
+	sampleParams.ShardHealthReporting.Beacon.Stall = 30
+	sampleParams.ShardHealthReporting.Beacon.LagTolerance = 5
+	sampleParams.ShardHealthReporting.Beacon.Severity = severityCritical
+	sampleParams.ShardHealthReporting.Regression.Tolerance = 2
+	sampleParams.ShardHealthReporting.BlockAge.MaxAge = 60
+	sampleParams.ShardHealthReporting.BlockAge.Critical = 300
+	sampleParams.ShardHealthReporting.BlockAge.ClearMargin = 10
+	sampleParams.ShardHealthReporting.Metadata.Fields = defaultMetadataFields
+	sampleParams.ShardHealthReporting.Signing.Window = 100
+	sampleParams.ShardHealthReporting.Signing.Threshold = 80
+	sampleParams.ShardHealthReporting.Signing.ClearMargin = 5
+	sampleParams.ShardHealthReporting.ViewSpread.Tolerance = 5
+	sampleParams.ShardHealthReporting.ClockSkew.Tolerance = 5
+	sampleParams.ShardHealthReporting.Escalation.After = 1800
+	sampleParams.ShardHealthReporting.Escalation.Severity = severityCritical
+	sampleParams.BalanceWatch.Interval = 300
+	sampleParams.BalanceWatch.WatchedAddresses = []watchedAddress{
+		{"one1_FAUCET_ADDRESS", 0, 1000},
+	}
+	sampleParams.DistributionFiles.RPCDiscovery.Endpoints = []string{"api.s0.t.hmny.io"}
+	sampleParams.DistributionFiles.MachineIPList = []string{
+		"/home/ec2_user/mainnet/shard0.txt",
+		"/home/ec2_user/mainnet/shard1.txt",
+		"/home/ec2_user/mainnet/shard2.txt",
+		"/home/ec2_user/mainnet/shard3.txt",
+	}
+	sampleParams.DistributionFiles.LoadRetry.Attempts = 3
+	sampleParams.DistributionFiles.LoadRetry.Backoff = defaultLoadBackoff
+	sampleParams.DistributionFiles.CountChange = 20
+	sampleConfig, err := yaml.Marshal(sampleParams)
+	if err != nil {
+		return "", err
+	}
+	return annotateSample(string(sampleConfig)), nil
+}
+
 func serviceCmd() *cobra.Command {
 	daemonCmd := &cobra.Command{
 		Use:               "service",
@@ -156,8 +475,11 @@ func serviceCmd() *cobra.Command {
 		Short: installD,
 		RunE:  w.install,
 	}
-	install.Flags().StringVar(&monitorNodeYAML, mFlag, "", mDescr)
-	install.MarkFlagRequired(mFlag)
+	// Every service command loads the config, preRunInit fails without it,
+	// and needs the name given at install to find the service
+	daemonCmd.PersistentFlags().StringVar(&monitorNodeYAML, mFlag, "", mDescr)
+	daemonCmd.PersistentFlags().StringVar(&serviceName, "name", "", "systemd unit name of the service, defaults to harmony-watchdogd@<target-chain>")
+	daemonCmd.PersistentFlags().StringVar(&serviceDescription, "description", "", "description of the service unit")
 	daemonCmd.AddCommand([]*cobra.Command{install, {
 		Use:   "start",
 		Short: startD,
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestSampleRunsWithoutChannels(t *testing.T) {
+	sample, err := sampleYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := watchParams{}
+	if err := yaml.UnmarshalStrict([]byte(sample), &p); err != nil {
+		t.Fatalf("sample does not parse: %v", err)
+	}
+	if err := p.sanityCheck(); err != nil {
+		t.Errorf("sample fails the sanity check: %v", err)
+	}
+	if p.Auth.PagerDuty.EventServiceKey != "" || p.Auth.Slack.WebhookURL != "" || len(p.Auth.Webhook.URLs) > 0 ||
+		p.Auth.Email.Host != "" || len(p.Alerting.Routing) > 0 || p.ShardHealthReporting.Escalation.EventServiceKey != "" {
+		t.Errorf("sample configures alert channels: %+v", p.Auth)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	deliveryQueueSize   = 100
+	deliveryMaxAttempts = 10
+	deliveryBackoff     = 2 * time.Second
+	deliveryMaxBackoff  = 5 * time.Minute
+	// How long shutdown waits for the queue to drain
+	deliveryDrainTimeout = 10 * time.Second
+)
+
+// Returned by a send that must not be retried
+type permanent struct {
+	error
+}
+
+type delivery struct {
+	id       uint64
+	event    auditEvent
+	send     func() error
+	attempts int
+}
+
+// Delivers the events of one channel in order off the monitor loops,
+// retrying failed sends with exponential backoff. When full the oldest
+// event is dropped. Each event and how it went is written to the audit log
+type deliveryQueue struct {
+	name    string
+	audit   *auditLog
+	lock    sync.Mutex
+	wake    *sync.Cond
+	pending []delivery
+	nextID  uint64
+	closed  bool
+	closing chan struct{}
+	done    chan struct{}
+	// Told the result of every attempt
+	onResult func(err error)
+}
+
+func newDeliveryQueue(name string, audit *auditLog, onResult func(err error)) *deliveryQueue {
+	q := &deliveryQueue{
+		name: name, audit: audit, closing: make(chan struct{}), done: make(chan struct{}), onResult: onResult,
+	}
+	q.wake = sync.NewCond(&q.lock)
+	go q.run()
+	return q
+}
+
+func (q *deliveryQueue) push(event auditEvent, send func() error) error {
+	event.Channel = q.name
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.nextID++
+	if q.closed {
+		err := errors.New(q.name + " delivery queue is shut down, dropped " + event.what())
+		q.audit.record(q.nextID, event, "dropped", 0, err)
+		return err
+	}
+	if len(q.pending) >= deliveryQueueSize {
+		oldest := q.pending[0]
+		stdlog.Printf("[deliveryQueue] WARNING %s queue full, dropping oldest %s after %d attempts",
+			q.name, oldest.event.what(), oldest.attempts,
+		)
+		q.audit.record(oldest.id, oldest.event, "dropped", oldest.attempts, errors.New("queue full"))
+		q.pending = q.pending[1:]
+	}
+	q.pending = append(q.pending, delivery{q.nextID, event, send, 0})
+	q.audit.record(q.nextID, event, "queued", 0, nil)
+	q.wake.Signal()
+	return nil
+}
+
+func (q *deliveryQueue) run() {
+	defer close(q.done)
+	backoff := deliveryBackoff
+	for {
+		q.lock.Lock()
+		for len(q.pending) == 0 && !q.closed {
+			q.wake.Wait()
+		}
+		if len(q.pending) == 0 {
+			q.lock.Unlock()
+			return
+		}
+		d := q.pending[0]
+		q.lock.Unlock()
+
+		err := d.send()
+		d.attempts++
+		q.onResult(err)
+		var p permanent
+		q.lock.Lock()
+		// Once shut down every event left gets a single attempt
+		retry := err != nil && !errors.As(err, &p) && d.attempts < deliveryMaxAttempts && !q.closed
+		// The head may have been dropped by a full queue meanwhile
+		head := len(q.pending) > 0 && q.pending[0].id == d.id
+		if head {
+			if retry {
+				q.pending[0].attempts = d.attempts
+			} else {
+				q.pending = q.pending[1:]
+			}
+		}
+		q.lock.Unlock()
+		switch {
+		case err == nil:
+			q.audit.record(d.id, d.event, "delivered", d.attempts, nil)
+		case !retry && head:
+			q.audit.record(d.id, d.event, "failed", d.attempts, err)
+		}
+
+		switch {
+		case err == nil && d.attempts > 1:
+			stdlog.Printf("[deliveryQueue] %s delivered %s after %d attempts", q.name, d.event.what(), d.attempts)
+		case err != nil && !retry:
+			errlog.Printf("[deliveryQueue] %s giving up on %s after %d attempts: %v", q.name, d.event.what(), d.attempts, err)
+		case err != nil:
+			errlog.Printf("[deliveryQueue] %s attempt %d of %s failed, retrying in %s: %v",
+				q.name, d.attempts, d.event.what(), backoff, err,
+			)
+		}
+
+		if !retry {
+			backoff = deliveryBackoff
+			continue
+		}
+		select {
+		case <-time.After(backoff):
+		case <-q.closing:
+		}
+		if backoff *= 2; backoff > deliveryMaxBackoff {
+			backoff = deliveryMaxBackoff
+		}
+	}
+}
+
+// Takes no more events and tries what is left once without backoff
+func (q *deliveryQueue) shutdown() {
+	q.lock.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.closing)
+		q.wake.Broadcast()
+	}
+	q.lock.Unlock()
+	select {
+	case <-q.done:
+	case <-time.After(deliveryDrainTimeout):
+		errlog.Printf("[deliveryQueue] %s not drained after %s", q.name, deliveryDrainTimeout)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Consecutive failed attempts of a channel before it is alerted on
+const defaultDeliveryFailures = 5
+
+var (
+	alertDeliveries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "watchdog",
+			Name:      "alert_deliveries_total",
+			Help:      "Alert delivery attempts per channel by result, every retry counts",
+		},
+		[]string{"chain", "channel", "result"},
+	)
+	deliveryFailures = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "watchdog",
+			Name:      "alert_delivery_consecutive_failures",
+			Help:      "Delivery attempts of the channel failed since its last success",
+		},
+		[]string{"chain", "channel"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(alertDeliveries, deliveryFailures)
+}
+
+type channelDeliveries struct {
+	Channel     string `json:"channel"`
+	Delivered   int    `json:"delivered"`
+	Failed      int    `json:"failed"`
+	Consecutive int    `json:"consecutive-failures"`
+	LastError   string `json:"last-error,omitempty"`
+	LastFailure string `json:"last-failure,omitempty"`
+}
+
+var channelNames = map[string]string{
+	"pagerduty": "PagerDuty",
+	"slack":     "Slack",
+	"telegram":  "Telegram",
+	"discord":   "Discord",
+	"opsgenie":  "OpsGenie",
+	"webhook":   "Webhook",
+	"email":     "Email",
+	"teams":     "Microsoft Teams",
+	"twilio":    "Twilio SMS",
+}
+
+// Told the result of each attempt by the delivery queue of the channel.
+// Once failing the channel is alerted on through the other channels, and
+// the alert clears with its next successful delivery
+func (a *alerter) delivered(channel string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	alertDeliveries.WithLabelValues(a.chain, channel, result).Inc()
+	a.inUse.Lock()
+	stats := a.deliveries[channel]
+	if stats == nil {
+		stats = &channelDeliveries{Channel: channel}
+		a.deliveries[channel] = stats
+	}
+	before := stats.Consecutive
+	if err != nil {
+		stats.Failed++
+		stats.Consecutive++
+		stats.LastError = err.Error()
+		stats.LastFailure = time.Now().UTC().Format(time.RFC3339)
+	} else {
+		stats.Delivered++
+		stats.Consecutive = 0
+	}
+	after := stats.Consecutive
+	a.inUse.Unlock()
+	deliveryFailures.WithLabelValues(a.chain, channel).Set(float64(after))
+
+	key := fmt.Sprintf("%s delivery failing - %s", channelNames[channel], a.chain)
+	switch {
+	case before < a.deliveryFailures && after >= a.deliveryFailures:
+		a.trigger(alert{
+			Key: key, Chain: a.chain, Check: deliveryCheck,
+			Message: fmt.Sprintf(deliveryFailingMessage,
+				channelNames[channel], after, err, a.chain,
+			),
+			Severity: severityCritical, Value: fmt.Sprintf("%d", after),
+			Threshold: fmt.Sprintf("%d", a.deliveryFailures), channel: channel,
+		})
+	case before >= a.deliveryFailures && after == 0:
+		a.clear(key)
+	}
+}
+
+func (a *alerter) deliveriesSnapshot() []channelDeliveries {
+	a.inUse.Lock()
+	snapshot := make([]channelDeliveries, 0, len(a.deliveries))
+	for _, stats := range a.deliveries {
+		snapshot = append(snapshot, *stats)
+	}
+	a.inUse.Unlock()
+	sort.SliceStable(snapshot, func(i, j int) bool { return snapshot[i].Channel < snapshot[j].Channel })
+	return snapshot
+}
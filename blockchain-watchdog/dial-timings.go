@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Dials kept per address until a call takes them, more are dropped
+const maxPendingDials = 8
+
+// Set by newTracer, nil leaves dials untimed
+var dialTimings *dialLog
+
+type dialTiming struct {
+	start     time.Time
+	resolved  time.Time
+	connected time.Time
+	lookup    bool
+	lookupErr error
+	err       error
+}
+
+// fasthttp dials without a context, so the dials of an address wait here
+// for the call to it that ends next, which is the one that waited for them
+type dialLog struct {
+	lock   sync.Mutex
+	byAddr map[string][]dialTiming
+}
+
+func (d *dialLog) add(addr string, t dialTiming) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if len(d.byAddr[addr]) < maxPendingDials {
+		d.byAddr[addr] = append(d.byAddr[addr], t)
+	}
+}
+
+// Those left over from calls given up before since are dropped
+func (d *dialLog) take(addr string, since time.Time) []dialTiming {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	taken := []dialTiming{}
+	for _, t := range d.byAddr[addr] {
+		if !t.start.Before(since) {
+			taken = append(taken, t)
+		}
+	}
+	delete(d.byAddr, addr)
+	return taken
+}
+
+// With tracing on, a node given by name is looked up here, not by the
+// dialer, so the lookup and the dial get a span each. The addresses
+// found are dialed in turn until one answers
+func timedDial(dial fasthttp.DialFunc, resolve bool, timeout time.Duration) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		if dialTimings == nil {
+			return dial(addr)
+		}
+		t := dialTiming{start: time.Now()}
+		targets := []string{addr}
+		host, port, err := net.SplitHostPort(addr)
+		if resolve && err == nil && net.ParseIP(host) == nil {
+			t.lookup = true
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			cancel()
+			t.resolved = time.Now()
+			if err == nil && len(ips) == 0 {
+				err = &net.DNSError{Err: "no addresses found", Name: host}
+			}
+			if err != nil {
+				t.lookupErr = err
+				dialTimings.add(addr, t)
+				return nil, err
+			}
+			targets = targets[:0]
+			for _, ip := range ips {
+				targets = append(targets, net.JoinHostPort(ip.IP.String(), port))
+			}
+		} else {
+			t.resolved = t.start
+		}
+		var conn net.Conn
+		for _, target := range targets {
+			if conn, err = dial(target); err == nil {
+				break
+			}
+		}
+		t.connected, t.err = time.Now(), err
+		dialTimings.add(addr, t)
+		return conn, err
+	}
+}
+
+// Adds the lookup and dial the call waited for as children of its span,
+// a call on a kept-alive connection has none
+func (t *tracer) traceDials(ctx context.Context, address string, since time.Time, call *span) {
+	if t == nil {
+		return
+	}
+	timings := dialTimings.take(address, since)
+	if len(timings) == 0 {
+		call.set("connection", "reused")
+		return
+	}
+	call.set("connection", "new")
+	for _, d := range timings {
+		if d.lookup {
+			t.record(ctx, "dns", d.start, d.resolved, d.lookupErr, "node", address)
+		}
+		if !d.connected.IsZero() {
+			t.record(ctx, "dial", d.resolved, d.connected, d.err, "node", address)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Interval is assumed as seconds, zero sends every alert right away
+type digestParams struct {
+	Interval seconds `yaml:"interval"`
+}
+
+// Critical alerts are never held back for the digest
+func (a *alerter) digested(al alert) bool {
+	return a.digest > 0 && al.Severity != severityCritical
+}
+
+func (a *alerter) digestLoop() {
+	defer close(a.digestDone)
+	ticker := time.NewTicker(a.digest)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.flushDigest()
+		case <-a.stopDigest:
+			a.flushDigest()
+			return
+		}
+	}
+}
+
+// Sends one summary of all digested alerts still firing if any breached
+// since the last flush, and resolves it once none are left
+func (a *alerter) flushDigest() {
+	a.inUse.Lock()
+	digested := []alert{}
+	severity := severityInfo
+	for _, entry := range a.active {
+		if entry.Notified || !entry.digesting {
+			continue
+		}
+		digested = append(digested, entry.alert)
+		if severityRank[entry.Severity] > severityRank[severity] {
+			severity = entry.Severity
+		}
+	}
+	pending, open := a.pending, a.digestOpen
+	a.pending = false
+	count := len(digested)
+	a.digestOpen = count > 0 && (pending || open)
+	a.inUse.Unlock()
+
+	key := fmt.Sprintf("Alert digest - %s", a.chain)
+	if count == 0 {
+		if open {
+			if err := a.notifyRecovery(a.serviceKey, key, "No digested alerts firing", alert{Key: key, Chain: a.chain}); err != nil {
+				errlog.Print(err)
+			}
+		}
+		return
+	}
+	if !pending {
+		return
+	}
+	summary := fmt.Sprintf("%d alerts firing - %s", count, a.chain)
+	if err := a.notify(a.serviceKey, key, summary, fmt.Sprintf(digestMessage, count, alertLines(digested), a.chain),
+		alert{Key: key, Chain: a.chain, Severity: severity}, time.Now(),
+	); err != nil {
+		errlog.Print(err)
+	} else {
+		stdlog.Printf("[alerter] Queued PagerDuty digest of %d alerts", count)
+	}
+}
+
+// One line per alert grouped by shard, for digests and the rollup
+func alertLines(alerts []alert) string {
+	byShard := map[string][]alert{}
+	for _, al := range alerts {
+		byShard[al.Shard] = append(byShard[al.Shard], al)
+	}
+	shards := []string{}
+	for s := range byShard {
+		shards = append(shards, s)
+	}
+	sort.Strings(shards)
+	var lines strings.Builder
+	for _, s := range shards {
+		fmt.Fprintf(&lines, "Shard %s:\n", s)
+		sort.SliceStable(byShard[s], func(i, j int) bool { return byShard[s][i].Key < byShard[s][j].Key })
+		for _, al := range byShard[s] {
+			fmt.Fprintf(&lines, "  [%s] %s: %s\n", al.Severity, al.Check, al.Key)
+		}
+	}
+	return lines.String()
+}
+
+// Flushes what is pending before the daemon exits
+func (a *alerter) shutdown() {
+	if a.digest > 0 {
+		close(a.stopDigest)
+		<-a.digestDone
+	}
+	if a.limiter.enabled() {
+		close(a.stopRollup)
+		<-a.rollupDone
+	}
+	a.flushGroups()
+	a.pager.shutdown()
+	a.shutdownMessengers()
+	a.audit.close()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Webhook of the Discord channel alerts are posted to, created under the
+// channel's Integrations settings
+type discordParams struct {
+	WebhookURL string `yaml:"webhook-url"`
+}
+
+// Discord rejects messages over 2000 characters
+const discordMaxBody = 1800
+
+type discordSender struct {
+	params discordParams
+	client *http.Client
+}
+
+// Markdown Discord would apply to the title, the body goes in a code block
+var discordEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`, ">", `\>`,
+)
+
+func (d *discordSender) send(al alert, title, body string) error {
+	body = cutMessage(strings.TrimSpace(body), discordMaxBody)
+	payload, _ := json.Marshal(map[string]interface{}{
+		"content": fmt.Sprintf(
+			"**%s**\n```\n%s\n```", discordEscaper.Replace(title), strings.Replace(body, "```", "'''", -1),
+		),
+		// Never ping @everyone or a role named in a node's reply
+		"allowed_mentions": map[string][]string{"parse": {}},
+	})
+	res, err := d.client.Post(d.params.WebhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return chatStatus("discord", res)
+}
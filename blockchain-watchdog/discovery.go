@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// Endpoints are hosts without port, public-rpc under network-config is appended
+type rpcDiscoveryParams struct {
+	Enabled          bool     `yaml:"enabled"`
+	Endpoints        []string `yaml:"endpoints"`
+	RefreshEachEpoch bool     `yaml:"refresh-each-epoch"`
+}
+
+const discoveredCommittee = "rpc-discovery"
+
+// Groups the configured endpoints by the shard each one reports serving,
+// endpoints without a reply are left out until the next refresh
+func discoverCommittees(d rpcDiscoveryParams, rpcPort int) (map[int]committee, error) {
+	requestBody, _ := json.Marshal(getRPCRequest(NodeMetadataRPC))
+
+	type r struct {
+		Result NodeMetadataReply `json:"result"`
+	}
+
+	byShard := map[int]committee{}
+	for _, e := range d.Endpoints {
+		address := e + ":" + strconv.Itoa(rpcPort)
+		result, _, err := request(rpcScheme+address, requestBody)
+		if err != nil {
+			stdlog.Printf("[discoverCommittees] Unable to reach %s, Error: %v", address, err)
+			continue
+		}
+		oneReport := r{}
+		if err := json.Unmarshal(result, &oneReport); err != nil {
+			stdlog.Printf("[discoverCommittees] Bad metadata reply from %s, Error: %v", address, err)
+			continue
+		}
+		shard := int(oneReport.Result.ShardID)
+		c := byShard[shard]
+		c.file = discoveredCommittee
+		c.members = append(c.members, address)
+		byShard[shard] = c
+	}
+	if len(byShard) == 0 {
+		return nil, errors.New("no rpc-discovery endpoint replied with its node metadata")
+	}
+	for _, shard := range sortedShards(byShard) {
+		stdlog.Printf("[discoverCommittees] Shard %d, Discovered nodes: %d", shard, len(byShard[shard].members))
+	}
+	return byShard, nil
+}
+
+func (m *monitor) refreshCommittees() {
+	stdlog.Print("[refreshCommittees] Refreshing committees from rpc-discovery endpoints")
+	byShard, err := discoverCommittees(m.discovery, m.rpcPort)
+	if err == nil {
+		m.keepLoadBalanced(byShard)
+		// A selected shard may just not be discovered this time
+		m.selection.apply(byShard)
+		err = checkDuplicates(byShard)
+	}
+	if err != nil {
+		stdlog.Printf("[refreshCommittees] Keeping current committees, Error: %v", err)
+		return
+	}
+	m.setShardMap(byShard)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Whole seconds, written either as a bare number as before or as a Go
+// duration such as 90s or 2m. Marshals back as the bare number, so a
+// duration with a fraction of a second like 500ms is rejected, not rounded
+type seconds int
+
+func (s *seconds) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var n int
+	if err := unmarshal(&n); err == nil {
+		*s = seconds(n)
+		return nil
+	}
+	var text string
+	if err := unmarshal(&text); err != nil {
+		return err
+	}
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, expected seconds or e.g. 90s, 2m", text)
+	}
+	if d%time.Second != 0 {
+		return fmt.Errorf("duration %q is not a whole number of seconds", text)
+	}
+	*s = seconds(d / time.Second)
+	return nil
+}
+
+func (s seconds) duration() time.Duration {
+	return time.Duration(s) * time.Second
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP server alerts are mailed through. tls is starttls, tls for
+// implicit TLS, usually on port 465, or none. username and password are
+// optional, PLAIN auth is only used over TLS or to localhost
+type emailParams struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	TLS      string   `yaml:"tls"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+const (
+	emailStartTLS = "starttls"
+	emailTLS      = "tls"
+	emailNoTLS    = "none"
+	// Submission port, used unless port is set
+	defaultEmailPort = 587
+)
+
+var emailTLSModes = map[string]bool{emailStartTLS: true, emailTLS: true, emailNoTLS: true}
+
+type emailSender struct {
+	params emailParams
+}
+
+var emailHTML = template.Must(template.New("email").Parse(`<html><body>
+<h3>{{.Title}}</h3>
+<table cellpadding="4">
+<tr><td><b>Chain</b></td><td>{{.Chain}}</td></tr>
+{{if .Shard}}<tr><td><b>Shard</b></td><td>{{.Shard}}</td></tr>
+{{end}}<tr><td><b>Check</b></td><td>{{.Check}}</td></tr>
+<tr><td><b>Severity</b></td><td>{{.Severity}}</td></tr>
+{{if .Value}}<tr><td><b>Value</b></td><td>{{.Value}}</td></tr>
+{{end}}{{if .Threshold}}<tr><td><b>Threshold</b></td><td>{{.Threshold}}</td></tr>
+{{end}}</table>
+{{if .Nodes}}<p><b>Nodes</b></p>
+<ul>{{range .Nodes}}<li>{{.}}</li>{{end}}</ul>
+{{end}}<pre>{{.Body}}</pre>
+</body></html>
+`))
+
+// A plaintext and an HTML part of the same summary, mail clients show
+// the one they support
+func emailMessage(from string, to []string, al alert, title, body string) ([]byte, error) {
+	body = strings.TrimSpace(body)
+	text := body
+	if len(al.Nodes) > 0 {
+		text += "\n\nNodes:\n" + strings.Join(al.Nodes, "\n")
+	}
+	var page bytes.Buffer
+	err := emailHTML.Execute(&page, struct {
+		alert
+		Title, Body string
+	}{al, title, body})
+	if err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	parts := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n",
+		from, strings.Join(to, ", "), mime.QEncoding.Encode("utf-8", title), time.Now().Format(time.RFC1123Z),
+	)
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	for _, p := range []struct{ kind, content string }{
+		{"text/plain", text}, {"text/html", page.String()},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.kind + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		qp.Write([]byte(p.content))
+		qp.Close()
+	}
+	parts.Close()
+	return msg.Bytes(), nil
+}
+
+func (e *emailSender) send(al alert, title, body string) error {
+	msg, err := emailMessage(e.params.From, e.params.To, al, title, body)
+	if err != nil {
+		return permanent{err}
+	}
+	return smtpError(e.deliver(msg))
+}
+
+func (e *emailSender) deliver(msg []byte) error {
+	port := e.params.Port
+	if port == 0 {
+		port = defaultEmailPort
+	}
+	address := net.JoinHostPort(e.params.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: e.params.Host}
+	dialer := &net.Dialer{Timeout: chatTimeout}
+	var conn net.Conn
+	var err error
+	if e.params.TLS == emailTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(chatTimeout))
+	c, err := smtp.NewClient(conn, e.params.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if e.params.TLS == "" || e.params.TLS == emailStartTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if e.params.Username != "" {
+		err := c.Auth(smtp.PlainAuth("", e.params.Username, e.params.Password, e.params.Host))
+		var reply *textproto.Error
+		if err != nil && !errors.As(err, &reply) {
+			// Refused by the client, PLAIN auth over a connection without TLS
+			return permanent{err}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.params.From); err != nil {
+		return err
+	}
+	for _, to := range e.params.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// A 5xx reply, e.g. a rejected recipient or failed auth, fails the same way
+// on every retry
+func smtpError(err error) error {
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return permanent{err}
+	}
+	return err
+}
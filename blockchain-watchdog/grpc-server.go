@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"time"
+
+	"blockchain-watchdog/blockchain-watchdog/statuspb"
+	"google.golang.org/grpc"
+)
+
+// Stubs in statuspb are generated from status.proto with make proto
+type statusServer struct {
+	statuspb.UnimplementedWatchdogServer
+	m *monitor
+}
+
+func (s *statusServer) GetStatus(ctx context.Context, _ *statuspb.StatusRequest) (*statuspb.Status, error) {
+	return s.m.statusProto(), nil
+}
+
+func (s *statusServer) WatchStatus(_ *statuspb.StatusRequest, stream statuspb.Watchdog_WatchStatusServer) error {
+	cycles, done, stop := s.m.watchCycles()
+	defer stop()
+	for {
+		if err := stream.Send(s.m.statusProto()); err != nil {
+			return err
+		}
+		select {
+		case <-cycles:
+		case <-done:
+			return nil
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (m *monitor) statusProto() *statuspb.Status {
+	report := m.statusSnapshot()
+	raw, _ := json.Marshal(report)
+	status := &statuspb.Status{
+		Chain:      m.chain,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Versions:   report.Versions,
+		AvailSeats: int32(report.AvailSeats),
+		UsedSeats:  int32(report.ElectedSeats),
+		Validators: int32(report.Validators),
+		Json:       raw,
+		Health: &statuspb.NetworkHealth{
+			Status:      string(report.Health.Status),
+			ShardCounts: map[string]int32{},
+			Shards:      map[string]string{},
+		},
+	}
+	for state, count := range report.Health.Counts {
+		status.Health.ShardCounts[string(state)] = int32(count)
+	}
+	for shard, state := range report.Health.Shards {
+		status.Health.Shards[shard] = string(state)
+	}
+	for _, s := range report.Shards {
+		status.Shards = append(status.Shards, &statuspb.ShardStatus{
+			ShardId:             s.ShardID,
+			Consensus:           s.Consensus,
+			Block:               s.Block,
+			BlockTimestamp:      s.BlockTimestamp,
+			Epoch:               s.Epoch,
+			LeaderAddress:       s.LeaderAddress,
+			AvgBlockTime:        s.AvgBlockTime,
+			CrosslinkLagBlocks:  s.CrossLinkLag,
+			CrosslinkAgeSeconds: s.CrossLinkAge,
+			Health:              string(s.Health),
+			DegradedChecks:      s.DegradedChecks,
+			Nodes:               s.Nodes,
+		})
+	}
+	return status
+}
+
+// Cycles is woken after each block header cycle, done is closed when
+// the gRPC server shuts down
+func (m *monitor) watchCycles() (cycles chan struct{}, done chan struct{}, stop func()) {
+	cycles = make(chan struct{}, 1)
+	m.inUse.Lock()
+	if m.watchers == nil {
+		m.watchers = map[chan struct{}]bool{}
+	}
+	m.watchers[cycles] = true
+	done = m.grpcDone
+	m.inUse.Unlock()
+	return cycles, done, func() {
+		m.inUse.Lock()
+		delete(m.watchers, cycles)
+		m.inUse.Unlock()
+	}
+}
+
+// A watcher still busy sending the previous cycle just skips this one
+func (m *monitor) publishCycle() {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	for w := range m.watchers {
+		select {
+		case w <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *monitor) startGRPCServer(port int) {
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		errlog.Printf("[startGRPCServer] Could not listen on port %d: %v", port, err)
+		return
+	}
+	server := grpc.NewServer()
+	statuspb.RegisterWatchdogServer(server, &statusServer{m: m})
+	m.inUse.Lock()
+	m.grpcServer = server
+	m.grpcDone = make(chan struct{})
+	m.inUse.Unlock()
+	stdlog.Printf("[startGRPCServer] Serving gRPC on port %d", port)
+	if err := server.Serve(l); err != nil {
+		errlog.Printf("[startGRPCServer] %v", err)
+	}
+}
+
+// Open WatchStatus streams are ended first, GracefulStop would wait on them
+func (m *monitor) stopGRPCServer() {
+	m.inUse.Lock()
+	server, done := m.grpcServer, m.grpcDone
+	m.grpcServer, m.grpcDone = nil, nil
+	m.inUse.Unlock()
+	if server == nil {
+		return
+	}
+	close(done)
+	server.GracefulStop()
+}
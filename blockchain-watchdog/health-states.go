@@ -0,0 +1,111 @@
+package main
+
+import (
+	"time"
+)
+
+// Current state of one check on a shard, Since is when it last changed.
+// Severity is the highest of its alerts firing, if any
+type checkState struct {
+	State    healthState `json:"state"`
+	Since    string      `json:"since"`
+	Severity string      `json:"severity,omitempty"`
+}
+
+type stateEntry struct {
+	state    healthState
+	since    time.Time
+	severity string
+}
+
+var stateRank = map[healthState]int{
+	healthUp:       0,
+	healthDegraded: 1,
+	healthDown:     2,
+}
+
+// A firing consensus alert takes the shard down, any other only degrades it
+func firingState(check string) healthState {
+	if check == consensusCheck {
+		return healthDown
+	}
+	return healthDegraded
+}
+
+// Called by the alerter when an alert starts firing, changes severity or
+// clears, severity is the highest of the alerts of the check on the shard
+// still firing, empty once none is. Alerts not tied to a shard, e.g.
+// alert-delivery, leave the shard states alone
+func (m *monitor) alertChanged(shard, check, severity string) {
+	if shard == "" {
+		return
+	}
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	if m.firingChecks == nil {
+		m.firingChecks = map[string]map[string]string{}
+	}
+	if m.firingChecks[shard] == nil {
+		m.firingChecks[shard] = map[string]string{}
+	}
+	if severity != "" {
+		m.firingChecks[shard][check] = severity
+	} else {
+		delete(m.firingChecks[shard], check)
+	}
+	m.updateCheckState(shard, check, time.Now())
+}
+
+// Moves the check between UP, DEGRADED and DOWN and logs every move.
+// A check is first tracked as UP when it is polled or goes bad. Every
+// result is written to InfluxDB, when configured, moved or not.
+// Expects m.inUse to be held
+func (m *monitor) updateCheckState(shard, check string, now time.Time) {
+	to := healthUp
+	if m.degradedChecks[shard][check] {
+		to = healthDegraded
+	}
+	severity := m.firingChecks[shard][check]
+	if severity != "" {
+		if s := firingState(check); stateRank[s] > stateRank[to] {
+			to = s
+		}
+	}
+	m.influx.recordCheck(shard, check, to, severity, now)
+	if m.checkStates == nil {
+		m.checkStates = map[string]map[string]stateEntry{}
+	}
+	if m.checkStates[shard] == nil {
+		m.checkStates[shard] = map[string]stateEntry{}
+	}
+	from, tracked := m.checkStates[shard][check]
+	if tracked && from.state == to {
+		from.severity = severity
+		m.checkStates[shard][check] = from
+		return
+	}
+	m.checkStates[shard][check] = stateEntry{to, now, severity}
+	logger := withFields(stdlog, logFields{Shard: shard, Check: check})
+	if !tracked {
+		if to == healthUp {
+			return
+		}
+		logger.Printf("[checkState] Shard %s %s check %s -> %s at %s",
+			shard, check, healthUp, to, now.UTC().Format(time.RFC3339),
+		)
+		return
+	}
+	logger.Printf("[checkState] Shard %s %s check %s -> %s at %s after %s",
+		shard, check, from.state, to, now.UTC().Format(time.RFC3339),
+		now.Sub(from.since).Round(time.Second),
+	)
+}
+
+// Expects m.inUse to be held
+func (m *monitor) checkStatesOf(shard string) map[string]checkState {
+	states := map[string]checkState{}
+	for check, entry := range m.checkStates[shard] {
+		states[check] = checkState{entry.state, entry.since.UTC().Format(time.RFC3339), entry.severity}
+	}
+	return states
+}
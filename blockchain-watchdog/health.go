@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type healthState string
+
+const (
+	healthUp       healthState = "UP"
+	healthDegraded healthState = "DEGRADED"
+	healthDown     healthState = "DOWN"
+)
+
+// Check names used in alerts and to mark a shard as degraded
+const (
+	consensusCheck    = "consensus"
+	shardHeightCheck  = "shard-height"
+	beaconSyncCheck   = "beacon-sync"
+	cxPendingCheck    = "cx-pending"
+	connectivityCheck = "connectivity"
+	blockTimeCheck    = "block-time"
+	crossLinkCheck    = "cross-link"
+	balanceCheck      = "balance"
+	reachabilityCheck = "reachability"
+	signingCheck      = "signing"
+	viewSpreadCheck   = "view-spread"
+	clockSkewCheck    = "clock-skew"
+	blockAgeCheck     = "block-age"
+	metadataCheck     = "metadata-changes"
+	beaconCheck       = "beacon"
+	regressionCheck   = "height-regression"
+	deliveryCheck     = "alert-delivery"
+	malformedCheck    = "malformed-replies"
+	nodeCountCheck    = "node-count"
+	rollupCheck       = "alert-rollup"
+)
+
+// Every check an alert can come from
+var alertChecks = map[string]bool{
+	consensusCheck: true, shardHeightCheck: true, beaconSyncCheck: true,
+	cxPendingCheck: true, connectivityCheck: true, blockTimeCheck: true,
+	crossLinkCheck: true, balanceCheck: true, reachabilityCheck: true,
+	signingCheck: true, viewSpreadCheck: true, clockSkewCheck: true,
+	blockAgeCheck: true, metadataCheck: true, beaconCheck: true,
+	regressionCheck: true, deliveryCheck: true, malformedCheck: true,
+	nodeCountCheck: true, rollupCheck: true,
+}
+
+var healthExitCodes = map[healthState]int{
+	healthUp:       0,
+	healthDegraded: 1,
+	healthDown:     2,
+}
+
+// A check is stale once its last successful poll is older than
+// stalePolls times its configured interval
+const stalePolls = 2
+
+type checkPoll struct {
+	LastPoll  string  `json:"last-poll"`
+	Staleness float64 `json:"staleness-seconds"`
+	Stale     bool    `json:"stale"`
+}
+
+type networkHealth struct {
+	Status healthState            `json:"status"`
+	Counts map[healthState]int    `json:"shard-counts"`
+	Shards map[string]healthState `json:"shards"`
+	Beacon *beaconStatus          `json:"beacon,omitempty"`
+}
+
+func (m *monitor) setDegraded(shard, check string, degraded bool) {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	if m.degradedChecks == nil {
+		m.degradedChecks = map[string]map[string]bool{}
+	}
+	if m.degradedChecks[shard] == nil {
+		m.degradedChecks[shard] = map[string]bool{}
+	}
+	if degraded {
+		m.degradedChecks[shard][check] = true
+	} else {
+		delete(m.degradedChecks[shard], check)
+	}
+	m.updateCheckState(shard, check, time.Now())
+}
+
+// Expects m.inUse to be held
+func (m *monitor) degradedChecksOf(shard string) []string {
+	checks := []string{}
+	for c := range m.degradedChecks[shard] {
+		checks = append(checks, c)
+	}
+	sort.Strings(checks)
+	return checks
+}
+
+// Checks under shard-health-reporting are enabled unless set to false
+type checkToggle struct {
+	Enabled *bool `yaml:"enabled,omitempty"`
+}
+
+func (t checkToggle) enabled() bool {
+	return t.Enabled == nil || *t.Enabled
+}
+
+// Checks that can be turned off, a disabled check neither polls nor alerts
+func enabledChecks(params watchParams) map[string]bool {
+	r := params.ShardHealthReporting
+	return map[string]bool{
+		consensusCheck:    r.Consensus.enabled(),
+		shardHeightCheck:  r.ShardHeight.enabled(),
+		cxPendingCheck:    r.CxPending.enabled(),
+		connectivityCheck: r.Connectivity.enabled(),
+		blockTimeCheck:    r.BlockTime.enabled(),
+		crossLinkCheck:    r.CrossLink.enabled(),
+		signingCheck:      r.Signing.enabled(),
+		viewSpreadCheck:   r.ViewSpread.enabled(),
+		clockSkewCheck:    r.ClockSkew.enabled(),
+		blockAgeCheck:     r.BlockAge.enabled(),
+		metadataCheck:     r.Metadata.enabled(),
+		beaconCheck:       r.Beacon.enabled(),
+		regressionCheck:   r.Regression.enabled(),
+	}
+}
+
+// Checks without a toggle are always enabled
+func (m *monitor) enabled(check string) bool {
+	enabled, listed := m.checks[check]
+	return !listed || enabled
+}
+
+// Seconds between polls of each check, as configured
+func pollIntervals(params watchParams) map[string]int {
+	consensus := int(params.ShardHealthReporting.Consensus.Interval)
+	return map[string]int{
+		consensusCheck:    consensus,
+		shardHeightCheck:  consensus,
+		beaconSyncCheck:   consensus,
+		blockTimeCheck:    consensus,
+		signingCheck:      consensus,
+		blockAgeCheck:     consensus,
+		beaconCheck:       consensus,
+		regressionCheck:   consensus,
+		cxPendingCheck:    int(params.InspectSchedule.CxPending),
+		crossLinkCheck:    int(params.InspectSchedule.CrossLink),
+		connectivityCheck: int(params.InspectSchedule.NodeMetadata),
+		viewSpreadCheck:   int(params.InspectSchedule.NodeMetadata),
+		clockSkewCheck:    int(params.InspectSchedule.NodeMetadata),
+		metadataCheck:     int(params.InspectSchedule.NodeMetadata),
+		balanceCheck:      int(params.BalanceWatch.Interval),
+	}
+}
+
+func (m *monitor) markPolled(shard, check string) {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	if m.lastPolls == nil {
+		m.lastPolls = map[string]map[string]time.Time{}
+	}
+	if m.lastPolls[shard] == nil {
+		m.lastPolls[shard] = map[string]time.Time{}
+	}
+	m.lastPolls[shard][check] = time.Now()
+	m.updateCheckState(shard, check, m.lastPolls[shard][check])
+}
+
+// Only checks that polled the shard at least once are reported.
+// Expects m.inUse to be held
+func (m *monitor) pollsOf(shard string, now time.Time) map[string]checkPoll {
+	polls := map[string]checkPoll{}
+	for check, ts := range m.lastPolls[shard] {
+		staleness := now.Sub(ts).Seconds()
+		interval := m.pollIntervals[check]
+		polls[check] = checkPoll{
+			ts.UTC().Format(time.RFC3339),
+			staleness,
+			interval > 0 && staleness > float64(stalePolls*interval),
+		}
+	}
+	return polls
+}
+
+func shardHealth(consensusKnown, consensus bool, degradedChecks []string) healthState {
+	if consensusKnown && !consensus {
+		return healthDown
+	}
+	if len(degradedChecks) > 0 {
+		return healthDegraded
+	}
+	return healthUp
+}
+
+// UP if all shards UP, DEGRADED if any degraded, DOWN if any DOWN or the
+// beacon stalled, as no shard can crosslink without it
+func aggregateHealth(shards map[string]healthState, beacon *beaconStatus) networkHealth {
+	report := networkHealth{
+		Status: healthUp,
+		Counts: map[healthState]int{healthUp: 0, healthDegraded: 0, healthDown: 0},
+		Shards: shards,
+		Beacon: beacon,
+	}
+	for _, s := range shards {
+		report.Counts[s]++
+	}
+	if report.Counts[healthDegraded] > 0 {
+		report.Status = healthDegraded
+	}
+	if report.Counts[healthDown] > 0 || (beacon != nil && beacon.Stalled) {
+		report.Status = healthDown
+	}
+	return report
+}
+
+func (m *monitor) healthJSON(w http.ResponseWriter, req *http.Request) {
+	health := m.statusSnapshot().Health
+	if health.Status == healthDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(health)
+}
+
+// Asks a running daemon on this machine for its aggregate health. With an
+// admin listener port answers /healthz only, so the socket is asked with
+// the admin basic-auth, else the admin listener itself
+func fetchHealth(port int, socket string, admin listenerParams, chain string) (networkHealth, error) {
+	health := networkHealth{}
+	client := http.Client{Timeout: 5 * time.Second}
+	url := "http://127.0.0.1:" + strconv.Itoa(port)
+	switch {
+	case admin.Address != "" && socket == "":
+		host, p, err := net.SplitHostPort(admin.Address)
+		if err != nil {
+			return health, fmt.Errorf("invalid admin address %s: %v", admin.Address, err)
+		}
+		if bindsAllInterfaces(host) {
+			host = "127.0.0.1"
+		}
+		url = "http://" + net.JoinHostPort(host, p)
+		if admin.TLSCert != "" {
+			url = "https://" + net.JoinHostPort(host, p)
+		}
+	case port == 0 || admin.Address != "":
+		// Host is ignored when dialing the socket
+		client.Transport = unixSocketTransport(socket)
+		url = "http://unix"
+	}
+	req, err := http.NewRequest(http.MethodGet, url+"/health-"+chain, nil)
+	if err != nil {
+		return health, err
+	}
+	if admin.Address != "" && admin.BasicAuth.Username != "" {
+		req.SetBasicAuth(admin.BasicAuth.Username, admin.BasicAuth.Password)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return health, err
+	}
+	defer res.Body.Close()
+	if err := json.NewDecoder(res.Body).Decode(&health); err != nil {
+		return health, fmt.Errorf("could not decode health reply: %v", err)
+	}
+	return health, nil
+}
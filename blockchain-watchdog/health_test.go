@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchHealthFromAdmin(t *testing.T) {
+	auth := basicAuthParams{"admin", "secret"}
+	server := httptest.NewServer(requireBasicAuth(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/health-testnet" {
+			http.NotFound(w, req)
+			return
+		}
+		json.NewEncoder(w).Encode(networkHealth{Status: healthDegraded})
+	}), auth))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// Neither port nor socket, the wildcard admin address is asked on loopback
+	health, err := fetchHealth(0, "", listenerParams{Address: ":" + port, BasicAuth: auth}, "testnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Status != healthDegraded {
+		t.Errorf("status %s, want %s", health.Status, healthDegraded)
+	}
+	// Admin takes over from port, which then answers /healthz only
+	if _, err := fetchHealth(1, "", listenerParams{Address: ":" + port}, "testnet"); err == nil {
+		t.Error("admin asked without its basic-auth")
+	}
+}
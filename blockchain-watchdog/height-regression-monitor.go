@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Tolerance is assumed as blocks a node may report below its previous
+// height before it is alerted on, zero alerts on any drop
+type regressionParams struct {
+	checkToggle `yaml:",inline"`
+	Tolerance   int `yaml:"tolerance"`
+}
+
+// A node going back in height was reorged, rolled back its DB or was
+// reset, which lag checks miss while it stays close to the shard. The
+// alert clears once the node reaches its previous height again
+func (m *monitor) heightRegressionMonitor(params regressionParams, chain string, data BlockHeaderContainer) {
+	m.inUse.Lock()
+	if m.lastHeights == nil {
+		m.lastHeights = map[string]uint64{}
+	}
+	previous := map[string]uint64{}
+	for _, v := range data.Nodes {
+		previous[v.IP] = m.lastHeights[v.IP]
+	}
+	m.inUse.Unlock()
+
+	polled := map[string]bool{}
+	highest := map[string]uint64{}
+	for _, v := range data.Nodes {
+		shard := strconv.FormatUint(uint64(v.Payload.ShardID), 10)
+		if !polled[shard] {
+			m.markPolled(shard, regressionCheck)
+			polled[shard] = true
+		}
+		before, after := previous[v.IP], v.Payload.BlockNumber
+		incidentKey := fmt.Sprintf("%s height went backwards! - %s", v.IP, chain)
+		// Kept at the highest seen while regressed, so the alert holds until
+		// the node caught up with where it was
+		highest[v.IP] = after
+		if after < before {
+			highest[v.IP] = before
+		}
+		if before == 0 || after+uint64(params.Tolerance) >= before {
+			if after >= before {
+				m.alerts.clear(incidentKey)
+			}
+			continue
+		}
+		node := m.nodeName(v.IP)
+		message := fmt.Sprintf(heightRegressionMessage, node, before, after, before-after, shard, chain)
+		err := m.alerts.trigger(alert{
+			Key: incidentKey, Chain: chain, Shard: shard,
+			Check: regressionCheck, Message: message,
+			Value: strconv.FormatUint(after, 10), Threshold: strconv.FormatUint(before, 10),
+			Nodes: []string{v.IP},
+		})
+		if err != nil {
+			errlog.Print(err)
+		} else {
+			stdlog.Printf("[heightRegressionMonitor] Alert triggered! %s", incidentKey)
+		}
+		fields := logFields{Shard: shard, Check: regressionCheck, Node: v.IP}
+		withFields(stdlog, fields).Printf("[heightRegressionMonitor] %s on shard %s went from height %d back to %d",
+			node, shard, before, after,
+		)
+	}
+
+	m.inUse.Lock()
+	for ip, h := range highest {
+		m.lastHeights[ip] = h
+	}
+	m.inUse.Unlock()
+}
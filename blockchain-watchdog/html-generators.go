@@ -212,6 +212,7 @@ hr:after {
             <th>Intended ShardID</th>
             <th>RPC Payload</th>
             <th>Failure Reason</th>
+            <th>Failure Category</th>
           </tr>
         </thead>
         <tbody>
@@ -221,6 +222,7 @@ hr:after {
             <td>{{.ShardID}}</td>
             <td>{{.RPCPayload}}</td>
             <td>{{.FailureReason}}</td>
+            <td>{{.Category}}</td>
           </tr>
         {{end}}
         </tbody>
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Check results are written as line protocol to url. Version 2 writes to
+// bucket of org, version 1 to database with an optional retention-policy.
+// Token is sent as Authorization: Token, for 1.x it may be user:password.
+// No url leaves the writer off
+type influxParams struct {
+	URL             string `yaml:"url"`
+	Version         int    `yaml:"version"`
+	Token           string `yaml:"token"`
+	Org             string `yaml:"org"`
+	Bucket          string `yaml:"bucket"`
+	Database        string `yaml:"database"`
+	RetentionPolicy string `yaml:"retention-policy"`
+}
+
+const (
+	// Points waiting to be written, further points are dropped so a slow
+	// or down server never holds up a check
+	influxBuffer        = 16384
+	influxBatch         = 5000
+	influxFlushInterval = 10 * time.Second
+	influxTimeout       = 10 * time.Second
+)
+
+func (p influxParams) check() []string {
+	errList := []string{}
+	if p.URL == "" {
+		return errList
+	}
+	if u, err := url.Parse(p.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errList = append(errList, fmt.Sprintf("Invalid url %s under influxdb in yaml config, expected e.g. http://localhost:8086", p.URL))
+	}
+	switch p.Version {
+	case 0, 2:
+		if p.Org == "" || p.Bucket == "" {
+			errList = append(errList, "Missing org or bucket under influxdb in yaml config")
+		}
+	case 1:
+		if p.Database == "" {
+			errList = append(errList, "Missing database under influxdb in yaml config")
+		}
+	default:
+		errList = append(errList, fmt.Sprintf("Unknown version %d under influxdb in yaml config, expected 1 or 2", p.Version))
+	}
+	return errList
+}
+
+// Where points are posted, with the database or bucket they go to
+func (p influxParams) writeURL() string {
+	base := strings.TrimSuffix(p.URL, "/")
+	q := url.Values{"precision": {"ns"}}
+	if p.Version == 1 {
+		q.Set("db", p.Database)
+		if p.RetentionPolicy != "" {
+			q.Set("rp", p.RetentionPolicy)
+		}
+		return base + "/write?" + q.Encode()
+	}
+	q.Set("org", p.Org)
+	q.Set("bucket", p.Bucket)
+	return base + "/api/v2/write?" + q.Encode()
+}
+
+var (
+	influxNameEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	influxTagEscaper  = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	influxStrEscaper  = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// One line of measurement, tags and fields as key value pairs. Field
+// values are ints, floats, bools or strings, empty strings are left out
+func influxLine(measurement string, tags []string, fields map[string]interface{}, at time.Time) string {
+	var b strings.Builder
+	b.WriteString(influxNameEscaper.Replace(measurement))
+	for i := 0; i+1 < len(tags); i += 2 {
+		if tags[i+1] == "" {
+			continue
+		}
+		b.WriteString("," + influxTagEscaper.Replace(tags[i]) + "=" + influxTagEscaper.Replace(tags[i+1]))
+	}
+	keys := []string{}
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sep := " "
+	for _, k := range keys {
+		var v string
+		switch value := fields[k].(type) {
+		case int:
+			v = strconv.Itoa(value) + "i"
+		case uint64:
+			v = strconv.FormatUint(value, 10) + "i"
+		case float64:
+			v = strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			v = strconv.FormatBool(value)
+		case string:
+			if value == "" {
+				continue
+			}
+			v = `"` + influxStrEscaper.Replace(value) + `"`
+		default:
+			continue
+		}
+		b.WriteString(sep + influxTagEscaper.Replace(k) + "=" + v)
+		sep = ","
+	}
+	b.WriteString(" " + strconv.FormatInt(at.UnixNano(), 10))
+	return b.String()
+}
+
+type influxWriter struct {
+	params  influxParams
+	chain   string
+	target  string
+	points  chan string
+	dropped int
+	failing bool
+	lock    sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	client  *http.Client
+}
+
+// Nil when no url is set, every method of a nil writer is a no-op
+func newInfluxWriter(params influxParams, chain string) *influxWriter {
+	if params.URL == "" {
+		return nil
+	}
+	w := &influxWriter{
+		params: params,
+		chain:  chain,
+		target: params.writeURL(),
+		points: make(chan string, influxBuffer),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		client: &http.Client{Timeout: influxTimeout},
+	}
+	go w.run()
+	stdlog.Printf("[influxdb] Writing check results to %s", strings.SplitN(w.target, "?", 2)[0])
+	return w
+}
+
+func (w *influxWriter) add(line string) {
+	select {
+	case w.points <- line:
+	default:
+		w.lock.Lock()
+		w.dropped++
+		w.lock.Unlock()
+	}
+}
+
+// A check of a shard after it polled, degraded, fired or cleared, level
+// is 0 up, 1 degraded and 2 down
+func (w *influxWriter) recordCheck(shard, check string, state healthState, severity string, at time.Time) {
+	if w == nil {
+		return
+	}
+	w.add(influxLine("check",
+		[]string{"chain", w.chain, "shard", shard, "check", check},
+		map[string]interface{}{"state": string(state), "level": stateRank[state], "severity": severity},
+		at,
+	))
+}
+
+// Every node of the committee after a block header poll, the height and
+// view of those that replied and why the others did not
+func (w *influxWriter) recordNodes(shardMap map[string]int, data BlockHeaderContainer) {
+	if w == nil {
+		return
+	}
+	now := time.Now()
+	replied := map[string]bool{}
+	for _, n := range data.Nodes {
+		shard, known := shardMap[n.IP]
+		if !known || replied[n.IP] {
+			continue
+		}
+		replied[n.IP] = true
+		w.add(influxLine("node",
+			[]string{"chain", w.chain, "shard", strconv.Itoa(shard), "node", n.IP},
+			map[string]interface{}{
+				"up": 1, "height": n.Payload.BlockNumber, "view": n.Payload.ViewID, "epoch": n.Payload.Epoch,
+			},
+			now,
+		))
+	}
+	for _, n := range append(data.Down, data.Malformed...) {
+		shard, known := shardMap[n.IP]
+		if !known || replied[n.IP] {
+			continue
+		}
+		replied[n.IP] = true
+		w.add(influxLine("node",
+			[]string{"chain", w.chain, "shard", strconv.Itoa(shard), "node", n.IP},
+			map[string]interface{}{"up": 0, "reason": n.Category},
+			now,
+		))
+	}
+}
+
+func (w *influxWriter) run() {
+	defer close(w.done)
+	batch := []string{}
+	tick := time.NewTicker(influxFlushInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case p := <-w.points:
+			batch = append(batch, p)
+			if len(batch) < influxBatch {
+				continue
+			}
+		case <-tick.C:
+		case <-w.stop:
+			for len(w.points) > 0 {
+				batch = append(batch, <-w.points)
+			}
+			w.send(batch)
+			return
+		}
+		w.send(batch)
+		batch = []string{}
+	}
+}
+
+// Failures are logged once until a write succeeds again
+func (w *influxWriter) send(batch []string) {
+	w.lock.Lock()
+	dropped := w.dropped
+	w.dropped = 0
+	w.lock.Unlock()
+	if dropped > 0 {
+		stdlog.Printf("[influxdb] Dropped %d points, the write buffer was full", dropped)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	err := w.post(batch)
+	switch {
+	case err != nil && !w.failing:
+		errlog.Printf("[influxdb] Could not write %d points, Error: %v", len(batch), err)
+	case err == nil && w.failing:
+		stdlog.Print("[influxdb] Writing check results again")
+	}
+	w.failing = err != nil
+}
+
+func (w *influxWriter) post(batch []string) error {
+	body := strings.Join(batch, "\n") + "\n"
+	req, err := http.NewRequest(http.MethodPost, w.target, bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.params.Token != "" {
+		req.Header.Set("Authorization", "Token "+w.params.Token)
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		// InfluxDB explains a rejected write in the body
+		reply := make([]byte, 512)
+		n, _ := res.Body.Read(reply)
+		return fmt.Errorf("server replied %s %s", res.Status, strings.TrimSpace(string(reply[:n])))
+	}
+	return nil
+}
+
+// Writes the points still buffered, run on shutdown
+func (w *influxWriter) shutdown() {
+	if w == nil {
+		return
+	}
+	close(w.stop)
+	select {
+	case <-w.done:
+	case <-time.After(influxTimeout):
+		errlog.Print("[influxdb] Gave up writing the last points")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInfluxLineEscaping(t *testing.T) {
+	at := time.Unix(0, 1234)
+	for _, tc := range []struct {
+		measurement string
+		tags        []string
+		fields      map[string]interface{}
+		want        string
+	}{
+		{
+			"check", []string{"chain", "mainnet", "shard", "0"},
+			map[string]interface{}{"level": 2, "up": true},
+			"check,chain=mainnet,shard=0 level=2i,up=true 1234",
+		},
+		{
+			"my check,x", []string{"check", "a b,c=d", "empty", ""},
+			map[string]interface{}{"reason": `says "no" \ again`, "skipped": ""},
+			`my\ check\,x,check=a\ b\,c\=d reason="says \"no\" \\ again" 1234`,
+		},
+		{
+			"node", nil,
+			map[string]interface{}{"height": uint64(7), "ratio": 0.5, "field key": 1},
+			`node field\ key=1i,height=7i,ratio=0.5 1234`,
+		},
+	} {
+		if got := influxLine(tc.measurement, tc.tags, tc.fields, at); got != tc.want {
+			t.Errorf("influxLine(%q) = %s, want %s", tc.measurement, got, tc.want)
+		}
+	}
+}
+
+func TestInfluxWriteURL(t *testing.T) {
+	for _, tc := range []struct {
+		params influxParams
+		want   string
+	}{
+		{
+			influxParams{URL: "http://localhost:8086/", Org: "harmony ops", Bucket: "watchdog"},
+			"http://localhost:8086/api/v2/write?bucket=watchdog&org=harmony+ops&precision=ns",
+		},
+		{
+			influxParams{URL: "http://localhost:8086", Version: 1, Database: "watchdog"},
+			"http://localhost:8086/write?db=watchdog&precision=ns",
+		},
+		{
+			influxParams{URL: "https://influx.example.com", Version: 1, Database: "watchdog", RetentionPolicy: "week"},
+			"https://influx.example.com/write?db=watchdog&precision=ns&rp=week",
+		},
+	} {
+		if got := tc.params.writeURL(); got != tc.want {
+			t.Errorf("writeURL of %+v = %s, want %s", tc.params, got, tc.want)
+		}
+	}
+}
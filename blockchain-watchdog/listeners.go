@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+type basicAuthParams struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Address is host:port, an empty host binds all interfaces. TLS needs
+// both PEM files, basic auth both the username and password
+type listenerParams struct {
+	Address   string          `yaml:"address"`
+	TLSCert   string          `yaml:"tls-cert"`
+	TLSKey    string          `yaml:"tls-key"`
+	BasicAuth basicAuthParams `yaml:"basic-auth"`
+}
+
+func (l listenerParams) check() error {
+	if l.Address == "" {
+		if l.TLSCert != "" || l.TLSKey != "" || l.BasicAuth != (basicAuthParams{}) {
+			return errors.New("missing address")
+		}
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(l.Address); err != nil {
+		return err
+	}
+	if (l.TLSCert == "") != (l.TLSKey == "") {
+		return errors.New("tls-cert and tls-key must be set together")
+	}
+	if l.TLSCert != "" {
+		if _, err := tls.LoadX509KeyPair(l.TLSCert, l.TLSKey); err != nil {
+			return err
+		}
+	}
+	if (l.BasicAuth.Username == "") != (l.BasicAuth.Password == "") {
+		return errors.New("basic-auth needs both username and password")
+	}
+	return nil
+}
+
+// An empty or unspecified host such as 0.0.0.0 or ::
+func bindsAllInterfaces(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "" || (ip != nil && ip.IsUnspecified())
+}
+
+// Whether the address binds port on an interface other binds it on as
+// well. Other being empty stands for all interfaces, like the port under
+// http-reporter. Port zero, picked by the OS, never collides
+func (l listenerParams) sharesPort(port int, other string) bool {
+	host, p, err := net.SplitHostPort(l.Address)
+	if n, _ := strconv.Atoi(p); err != nil || n == 0 || n != port {
+		return false
+	}
+	return host == other || bindsAllInterfaces(host) || bindsAllInterfaces(other)
+}
+
+// Liveness only, answers as long as the daemon serves requests at all
+func (m *monitor) healthzJSON(w http.ResponseWriter, req *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{"status": "alive", "chain": m.chain})
+}
+
+func requireBasicAuth(next http.Handler, auth basicAuthParams) http.Handler {
+	if auth.Username == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, password, ok := req.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(auth.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(auth.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="watchdog"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func serveListener(name string, l listenerParams, handler http.Handler) {
+	server := &http.Server{Addr: l.Address, Handler: requireBasicAuth(handler, l.BasicAuth)}
+	scheme := "http"
+	var err error
+	if l.TLSCert != "" {
+		scheme = "https"
+	}
+	stdlog.Printf("[startReportingHTTPServer] Serving %s endpoints on %s://%s", name, scheme, l.Address)
+	if l.TLSCert != "" {
+		err = server.ListenAndServeTLS(l.TLSCert, l.TLSKey)
+	} else {
+		err = server.ListenAndServe()
+	}
+	errlog.Printf("[startReportingHTTPServer] %s listener on %s: %v", name, l.Address, err)
+}
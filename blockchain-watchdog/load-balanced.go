@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const loadBalancedCommittee = "load-balanced"
+
+// Shards fronted by a single load-balancer endpoint, keyed by shard ID.
+// Replies come from whichever node the balancer picks, so comparing or
+// tracking nodes within such a shard means nothing
+func addLoadBalanced(byShard map[int]committee, endpoints map[int]string, t watchParams) error {
+	for shard, endpoint := range endpoints {
+		if c, exists := byShard[shard]; exists && len(c.members) > 0 {
+			return fmt.Errorf("shard %d under node-distribution, load-balanced also has nodes from %s", shard, c.file)
+		}
+		port := t.Network.RPCPort
+		if p, exists := t.Network.ShardPorts[shard]; exists {
+			port = p
+		}
+		address, err := nodeAddress(endpoint, port)
+		if err != nil {
+			return fmt.Errorf("shard %d under node-distribution, load-balanced: %v", shard, err)
+		}
+		byShard[shard] = committee{loadBalancedCommittee, []string{address}, map[string]string{}, map[string]string{}, true}
+	}
+	return nil
+}
+
+// Load-balanced shards come from the config, so a refresh keeps them
+func (m *monitor) keepLoadBalanced(byShard map[int]committee) {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	for shard, address := range m.balanced {
+		id, _ := strconv.Atoi(shard)
+		byShard[id] = committee{loadBalancedCommittee, []string{address}, map[string]string{}, map[string]string{}, true}
+	}
+}
+
+// Expects m.inUse to be held
+func (m *monitor) loadBalancedOf(shard string) string {
+	return m.balanced[shard]
+}
+
+func (m *monitor) isLoadBalanced(shard string) bool {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	return m.balanced[shard] != ""
+}
+
+// Metadata of nodes that node-level checks can look at, i.e. not behind
+// a load balancer
+func (m *monitor) nodeLevelMetadata(data MetadataContainer) MetadataContainer {
+	kept := MetadataContainer{TS: data.TS, Down: data.Down}
+	for _, n := range data.Nodes {
+		if !m.isLoadBalanced(strconv.FormatUint(uint64(n.Payload.ShardID), 10)) {
+			kept.Nodes = append(kept.Nodes, n)
+		}
+	}
+	return kept
+}
+
+func (m *monitor) nodeLevelHeaders(data BlockHeaderContainer) BlockHeaderContainer {
+	kept := BlockHeaderContainer{TS: data.TS, Down: data.Down}
+	for _, n := range data.Nodes {
+		if !m.isLoadBalanced(strconv.FormatUint(uint64(n.Payload.ShardID), 10)) {
+			kept.Nodes = append(kept.Nodes, n)
+		}
+	}
+	return kept
+}
+
+func (m *monitor) nodeLevelShardMap(shardMap map[string]int) map[string]int {
+	kept := map[string]int{}
+	for address, shard := range shardMap {
+		if !m.isLoadBalanced(strconv.Itoa(shard)) {
+			kept[address] = shard
+		}
+	}
+	return kept
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"time"
+)
+
+const defaultLoadBackoff = 2
+
+// Attempts counts the first try, zero or one tries once. Backoff is assumed
+// as seconds before the first retry and doubles after each further failure
+type loadRetryParams struct {
+	Attempts int     `yaml:"attempts"`
+	Backoff  seconds `yaml:"backoff"`
+}
+
+// Runs load until it succeeds or the attempts run out, the last error is returned
+func (r loadRetryParams) retry(source string, load func() error) error {
+	backoff := r.Backoff.duration()
+	if backoff == 0 {
+		backoff = defaultLoadBackoff * time.Second
+	}
+	for attempt := 1; ; attempt++ {
+		err := load()
+		if err == nil || attempt >= r.Attempts {
+			return err
+		}
+		stdlog.Printf("[loadRetry] Loading %s failed (attempt %d of %d), retrying in %s, Error: %v",
+			source, attempt, r.Attempts, backoff, err,
+		)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	megabyte = 1 << 20
+	// Sorts in the order the backups were made
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+)
+
+// How the log file set under logging is rotated. MaxSize is in megabytes
+// and MaxAge in days, zero keeps backups of any size, age or count
+type logRotation struct {
+	MaxSize    int     `yaml:"max-size"`
+	Interval   seconds `yaml:"interval"`
+	MaxAge     int     `yaml:"max-age"`
+	MaxBackups int     `yaml:"max-backups"`
+}
+
+func (r logRotation) check() []string {
+	errList := []string{}
+	for name, v := range map[string]int{
+		"max-size": r.MaxSize, "interval": int(r.Interval), "max-age": r.MaxAge, "max-backups": r.MaxBackups,
+	} {
+		if v < 0 {
+			errList = append(errList, fmt.Sprintf("Negative %s under logging in yaml config", name))
+		}
+	}
+	sort.Strings(errList)
+	return errList
+}
+
+// Renamed to name-<time>.ext once past max-size or interval and replaced
+// by a new file. Only written with the lock of logs held
+type rotatingFile struct {
+	path     string
+	rotation logRotation
+	file     *os.File
+	size     int64
+	opened   time.Time
+}
+
+func openRotatingFile(path string, rotation logRotation) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, rotation: rotation}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size, f.opened = file, info.Size(), time.Now()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	now := time.Now()
+	maxSize := int64(f.rotation.MaxSize) * megabyte
+	pastSize := maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > maxSize
+	pastInterval := f.rotation.Interval > 0 && now.Sub(f.opened) >= f.rotation.Interval.duration()
+	if pastSize || pastInterval {
+		// Keeps writing to the current file rather than lose the line
+		if err := f.rotate(now); err != nil {
+			fmt.Fprintf(os.Stderr, "[rotatingFile] Could not rotate %s, Error: %v\n", f.path, err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate(now time.Time) error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	renamed := os.Rename(f.path, f.backupName(now))
+	if err := f.open(); err != nil {
+		return err
+	}
+	if renamed != nil {
+		return renamed
+	}
+	f.prune(now)
+	return nil
+}
+
+func (f *rotatingFile) backupName(at time.Time) string {
+	ext := filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-" + at.UTC().Format(backupTimeFormat) + ext
+}
+
+// Removes the backups past max-backups, oldest first, and those made
+// longer than max-age ago. Files not named like a backup are left alone
+func (f *rotatingFile) prune(now time.Time) {
+	if f.rotation.MaxBackups == 0 && f.rotation.MaxAge == 0 {
+		return
+	}
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext) + "-"
+	matches, err := filepath.Glob(base + "*" + ext)
+	if err != nil {
+		return
+	}
+	backups := []string{}
+	made := map[string]time.Time{}
+	for _, m := range matches {
+		at, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(m, base), ext))
+		if err == nil {
+			backups, made[m] = append(backups, m), at
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	maxAge := time.Duration(f.rotation.MaxAge) * 24 * time.Hour
+	for i, b := range backups {
+		pastCount := f.rotation.MaxBackups > 0 && i >= f.rotation.MaxBackups
+		pastAge := maxAge > 0 && now.Sub(made[b]) >= maxAge
+		if pastCount || pastAge {
+			if err := os.Remove(b); err != nil {
+				fmt.Fprintf(os.Stderr, "[rotatingFile] Could not remove %s, Error: %v\n", b, err)
+			}
+		}
+	}
+}
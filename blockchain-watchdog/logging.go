@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	levelInfo  = "info"
+	levelWarn  = "warn"
+	levelError = "error"
+
+	logText = "text"
+	logJSON = "json"
+)
+
+var logLevelRank = map[string]int{levelInfo: 0, levelWarn: 1, levelError: 2}
+
+// Level is the lowest level written, format text or json. With a file
+// every line goes there instead of stdout and stderr
+type logParams struct {
+	Level       string `yaml:"level"`
+	Format      string `yaml:"format"`
+	File        string `yaml:"file"`
+	logRotation `yaml:",inline"`
+}
+
+func (p logParams) check() []string {
+	errList := []string{}
+	if _, ok := logLevelRank[p.Level]; p.Level != "" && !ok {
+		errList = append(errList, fmt.Sprintf("Unknown level %s under logging in yaml config, expected info, warn or error", p.Level))
+	}
+	if p.Format != "" && p.Format != logText && p.Format != logJSON {
+		errList = append(errList, fmt.Sprintf("Unknown format %s under logging in yaml config, expected text or json", p.Format))
+	}
+	return append(errList, p.logRotation.check()...)
+}
+
+// What a line is about, set on lines of one shard, check or node so they
+// can be filtered on once ingested
+type logFields struct {
+	Shard string `json:"shard,omitempty"`
+	Check string `json:"check,omitempty"`
+	Node  string `json:"node,omitempty"`
+}
+
+func alertFields(al alert) logFields {
+	f := logFields{Shard: al.Shard, Check: al.Check}
+	if len(al.Nodes) == 1 {
+		f.Node = al.Nodes[0]
+	}
+	return f
+}
+
+type logRecord struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Component string `json:"component,omitempty"`
+	Message   string `json:"msg"`
+	logFields
+}
+
+// Where stdlog and errlog write to. Info and warn lines go to stdout and
+// errors to stderr, a stdlog line starting with WARNING after its
+// [component] is a warn line
+type logOutput struct {
+	lock   sync.Mutex
+	level  string
+	format string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+var logs = &logOutput{level: levelInfo, format: logText, stdout: os.Stdout, stderr: os.Stderr}
+
+func (o *logOutput) configure(p logParams) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if p.Level != "" {
+		o.level = p.Level
+	}
+	if p.Format != "" {
+		o.format = p.Format
+	}
+}
+
+// Lines logged so far went to stdout and stderr
+func (o *logOutput) toFile(p logParams) error {
+	if p.File == "" {
+		return nil
+	}
+	file, err := openRotatingFile(p.File, p.logRotation)
+	if err != nil {
+		return err
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.stdout, o.stderr = file, file
+	return nil
+}
+
+func (o *logOutput) write(level string, fields logFields, line string) {
+	now := time.Now()
+	line = strings.TrimSuffix(line, "\n")
+	component, message := "", line
+	if strings.HasPrefix(line, "[") {
+		if end := strings.Index(line, "] "); end > 0 {
+			component, message = line[1:end], line[end+2:]
+		}
+	}
+	if level == levelInfo && strings.HasPrefix(message, "WARNING ") {
+		level = levelWarn
+	}
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if logLevelRank[level] < logLevelRank[o.level] {
+		return
+	}
+	out := o.stdout
+	if level == levelError {
+		out = o.stderr
+	}
+	if o.format == logJSON {
+		// Keeps the -> of state changes readable
+		encoder := json.NewEncoder(out)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(logRecord{now.UTC().Format(time.RFC3339Nano), level, component, message, fields})
+		return
+	}
+	io.WriteString(out, now.Format("2006/01/02 15:04:05 ")+line+"\n")
+}
+
+// From --log-level and --log-format, empty when not given
+func logFlags() (logParams, error) {
+	if _, ok := logLevelRank[logLevel]; logLevel != "" && !ok {
+		return logParams{}, fmt.Errorf("unknown --log-level %s, expected info, warn or error", logLevel)
+	}
+	if logFormat != "" && logFormat != logText && logFormat != logJSON {
+		return logParams{}, fmt.Errorf("unknown --log-format %s, expected text or json", logFormat)
+	}
+	return logParams{Level: logLevel, Format: logFormat}, nil
+}
+
+type levelWriter struct {
+	level  string
+	fields logFields
+}
+
+func (w levelWriter) Write(p []byte) (int, error) {
+	logs.write(w.level, w.fields, string(p))
+	return len(p), nil
+}
+
+func newLogger(level string) *log.Logger {
+	return log.New(levelWriter{level: level}, "", 0)
+}
+
+// The logger's lines carry the fields, e.g.
+// withFields(stdlog, alertFields(al)).Printf(...)
+func withFields(l *log.Logger, f logFields) *log.Logger {
+	w, ok := l.Writer().(levelWriter)
+	if !ok {
+		return l
+	}
+	w.fields = f
+	return log.New(w, "", 0)
+}
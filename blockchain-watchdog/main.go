@@ -3,13 +3,15 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 )
 
 var (
-	version string
-	commit  string
-	builtAt string
-	builtBy string
+	version   string
+	commit    string
+	builtAt   string
+	builtBy   string
+	startTime = time.Now()
 )
 
 func main() {
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Between start and end the checks of the shards and nodes listed keep
+// running and reporting, their alerts just aren't sent anywhere. A node is
+// an address as in the machine-ip-list files, with or without the port
+type maintenanceWindow struct {
+	ID     int       `yaml:"-" json:"id"`
+	Shards []int     `yaml:"shards" json:"shards,omitempty"`
+	Nodes  []string  `yaml:"nodes" json:"nodes,omitempty"`
+	Start  time.Time `yaml:"start" json:"start"`
+	End    time.Time `yaml:"end" json:"end"`
+	Reason string    `yaml:"reason" json:"reason,omitempty"`
+}
+
+func (w maintenanceWindow) check() []string {
+	errList := []string{}
+	if len(w.Shards) == 0 && len(w.Nodes) == 0 {
+		errList = append(errList, "missing shards or nodes")
+	}
+	for _, s := range w.Shards {
+		if s < 0 {
+			errList = append(errList, fmt.Sprintf("negative shard %d", s))
+		}
+	}
+	if w.End.IsZero() {
+		errList = append(errList, "missing end")
+	} else if !w.End.After(w.Start) {
+		errList = append(errList, "end is not after start")
+	}
+	return errList
+}
+
+func (w maintenanceWindow) open(now time.Time) bool {
+	return !now.Before(w.Start) && now.Before(w.End)
+}
+
+// By the alert's shard, or by its nodes when all of them are listed
+func (w maintenanceWindow) covers(al alert) bool {
+	for _, s := range w.Shards {
+		if al.Shard == strconv.Itoa(s) {
+			return true
+		}
+	}
+	if len(al.Nodes) == 0 {
+		return false
+	}
+	for _, n := range al.Nodes {
+		if !w.coversNode(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w maintenanceWindow) coversNode(address string) bool {
+	return listsNode(w.Nodes, address)
+}
+
+// Whether the node is in the list by its address, or by its IP alone
+func listsNode(nodes []string, address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	for _, n := range nodes {
+		if n == address || n == host {
+			return true
+		}
+	}
+	return false
+}
+
+// Windows from the yaml config and those added through /maintenance, the
+// latter are kept in memory only. Ended windows are dropped as they're
+// looked up
+type maintenanceSchedule struct {
+	lock    sync.Mutex
+	windows []maintenanceWindow
+	nextID  int
+}
+
+func newMaintenanceSchedule(windows []maintenanceWindow) *maintenanceSchedule {
+	s := &maintenanceSchedule{}
+	for _, w := range windows {
+		s.add(w)
+	}
+	return s
+}
+
+func (s *maintenanceSchedule) add(w maintenanceWindow) maintenanceWindow {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.nextID++
+	w.ID = s.nextID
+	if w.Start.IsZero() {
+		w.Start = time.Now().Truncate(time.Second)
+	}
+	s.windows = append(s.windows, w)
+	return w
+}
+
+func (s *maintenanceSchedule) remove(id int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for i, w := range s.windows {
+		if w.ID == id {
+			s.windows = append(s.windows[:i], s.windows[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Open and upcoming windows, soonest first
+func (s *maintenanceSchedule) list(now time.Time) []maintenanceWindow {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	kept := s.windows[:0]
+	for _, w := range s.windows {
+		if now.Before(w.End) {
+			kept = append(kept, w)
+		}
+	}
+	s.windows = kept
+	windows := append([]maintenanceWindow{}, kept...)
+	sort.SliceStable(windows, func(i, j int) bool { return windows[i].Start.Before(windows[j].Start) })
+	return windows
+}
+
+// The open window covering the alert, nil when there is none
+func (s *maintenanceSchedule) covering(al alert, now time.Time) *maintenanceWindow {
+	for _, w := range s.list(now) {
+		if w.open(now) && w.covers(al) {
+			return &w
+		}
+	}
+	return nil
+}
+
+// Changing the windows needs the admin basic-auth, without it they can
+// only be listed
+func (m *monitor) maintenanceHandler(auth basicAuthParams) http.Handler {
+	if auth.Username == "" {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == http.MethodPost || req.Method == http.MethodDelete {
+				http.Error(w, "changing maintenance windows needs http-reporter.admin.basic-auth", http.StatusForbidden)
+				return
+			}
+			m.maintenanceJSON(w, req)
+		})
+	}
+	return requireBasicAuth(http.HandlerFunc(m.maintenanceJSON), auth)
+}
+
+// GET lists the windows, POST adds one from a JSON window, start defaults
+// to now, DELETE removes the one of the id query param
+func (m *monitor) maintenanceJSON(w http.ResponseWriter, req *http.Request) {
+	schedule := m.alerts.maintenance
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		window := maintenanceWindow{}
+		if err := json.NewDecoder(req.Body).Decode(&window); err != nil {
+			http.Error(w, "invalid maintenance window: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errList := window.check(); len(errList) > 0 {
+			http.Error(w, "invalid maintenance window: "+errList[0], http.StatusBadRequest)
+			return
+		}
+		window = schedule.add(window)
+		stdlog.Printf("[maintenanceJSON] Added maintenance window %d of shards %v, nodes %v from %s to %s: %s",
+			window.ID, window.Shards, window.Nodes, window.Start.UTC().Format(time.RFC3339),
+			window.End.UTC().Format(time.RFC3339), window.Reason,
+		)
+	case http.MethodDelete:
+		id, err := strconv.Atoi(req.URL.Query().Get("id"))
+		if err != nil {
+			http.Error(w, "maintenance window id not chosen in query param", http.StatusBadRequest)
+			return
+		}
+		if !schedule.remove(id) {
+			http.Error(w, "Unknown maintenance window "+strconv.Itoa(id), http.StatusNotFound)
+			return
+		}
+		stdlog.Printf("[maintenanceJSON] Removed maintenance window %d", id)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule.list(time.Now()))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaintenanceChangesNeedAdminAuth(t *testing.T) {
+	m := &monitor{alerts: newAlerter(watchParams{}, nil)}
+	window := `{"shards":[1],"end":"2099-01-01T00:00:00Z","reason":"upgrade"}`
+	for _, tc := range []struct {
+		auth   basicAuthParams
+		method string
+		user   string
+		want   int
+	}{
+		{method: http.MethodGet, want: http.StatusOK},
+		{method: http.MethodPost, want: http.StatusForbidden},
+		{method: http.MethodDelete, want: http.StatusForbidden},
+		{auth: basicAuthParams{"admin", "secret"}, method: http.MethodPost, want: http.StatusUnauthorized},
+		{auth: basicAuthParams{"admin", "secret"}, method: http.MethodPost, user: "admin", want: http.StatusOK},
+	} {
+		req := httptest.NewRequest(tc.method, "/maintenance-testnet?id=1", strings.NewReader(window))
+		if tc.user != "" {
+			req.SetBasicAuth(tc.user, tc.auth.Password)
+		}
+		w := httptest.NewRecorder()
+		m.maintenanceHandler(tc.auth).ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s with auth %q as %q answered %d, want %d", tc.method, tc.auth.Username, tc.user, w.Code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Bytes of a malformed reply kept in the logs
+const malformedSampleSize = 256
+
+// The node answered over HTTP but not with the result the call expects,
+// wrong JSON, no result or one missing fields. Unlike a failed call the node
+// is reachable, so a shard full of them points at a bad release instead
+type malformedReplyError struct {
+	reason string
+}
+
+func (e *malformedReplyError) Error() string {
+	return "malformed reply: " + e.reason
+}
+
+// Fields a reply can't do without, a node returning a different schema
+// decodes without error into zero values otherwise
+var requiredFields = map[string][]string{
+	BlockHeaderRPC:  {"blockHash", "blockNumber", "shardID"},
+	NodeMetadataRPC: {"blskey", "version", "shard-id"},
+}
+
+// Decodes the result of the JSON-RPC reply payload of rpc into result
+func decodeResult(rpc string, payload []byte, result interface{}) error {
+	reply := struct {
+		Result json.RawMessage `json:"result"`
+	}{}
+	if err := json.Unmarshal(payload, &reply); err != nil {
+		return &malformedReplyError{err.Error()}
+	}
+	if len(reply.Result) == 0 || string(reply.Result) == "null" {
+		return &malformedReplyError{"no result"}
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(reply.Result, &fields); err != nil {
+		return &malformedReplyError{"result is not an object"}
+	}
+	for _, f := range requiredFields[rpc] {
+		if _, exists := fields[f]; !exists {
+			return &malformedReplyError{"result has no " + f}
+		}
+	}
+	if err := json.Unmarshal(reply.Result, result); err != nil {
+		return &malformedReplyError{err.Error()}
+	}
+	return nil
+}
+
+// Start of payload for the logs, whole runes only
+func payloadSample(payload []byte) string {
+	if len(payload) <= malformedSampleSize {
+		return string(payload)
+	}
+	cut := malformedSampleSize
+	for cut > 0 && !utf8.RuneStart(payload[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... (%d bytes)", payload[:cut], len(payload))
+}
+
+// Counts the reply as a failure of its own category and logs a sample of it
+func (m *monitor) malformedReply(d reply, shard int, err error) noReply {
+	m.countRPCFailure(d.address, d.rpc, failureMalformed)
+	stdlog.Printf("[malformedReply] %s of %s, %v, Reply: %s",
+		d.rpc, d.address, err, payloadSample(d.rpcResult),
+	)
+	return noReply{d.address, err.Error(), string(d.rpcPayload), shard, failureMalformed}
+}
+
+// A super-majority of the shard's nodes with malformed replies alerts, a few
+// of them are node level problems
+func (m *monitor) checkMalformedReplies(chain, rpc string, shardMap map[string]int,
+	malformed []noReply,
+) {
+	tried := map[int]int{}
+	for _, s := range shardMap {
+		tried[s]++
+	}
+	count := map[int]int{}
+	lastError, nodes := map[int]string{}, map[int][]string{}
+	for _, d := range malformed {
+		count[d.ShardID]++
+		lastError[d.ShardID] = d.FailureReason
+		nodes[d.ShardID] = append(nodes[d.ShardID], d.IP)
+	}
+	shards := []int{}
+	for shard := range tried {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	for _, shard := range shards {
+		s := strconv.Itoa(shard)
+		incidentKey := fmt.Sprintf("Shard %s malformed %s replies! - %s", s, rpc, chain)
+		breach := count[shard]*3 > tried[shard]*2
+		if rpc == BlockHeaderRPC {
+			m.setDegraded(s, malformedCheck, breach)
+		}
+		if !breach {
+			m.alerts.clear(incidentKey)
+			continue
+		}
+		message := fmt.Sprintf(malformedRepliesMessage,
+			count[shard], tried[shard], s, rpc, lastError[shard], chain,
+		)
+		err := m.alerts.trigger(alert{
+			Key: incidentKey, Chain: chain, Shard: s,
+			Check: malformedCheck, Message: message,
+			Value: strconv.Itoa(count[shard]), Threshold: strconv.Itoa(tried[shard]*2/3 + 1),
+			Nodes: nodes[shard],
+		})
+		if err != nil {
+			errlog.Print(err)
+		} else {
+			stdlog.Printf("[checkMalformedReplies] Alert triggered! %s", incidentKey)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// A node answering every call with body as it is
+func rawNode(t *testing.T, body string) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestDecodeResultMalformed(t *testing.T) {
+	for name, payload := range map[string]string{
+		"not json":          `<html>502 Bad Gateway</html>`,
+		"truncated":         `{"jsonrpc":"2.0","id":"0","result":{"blockHash":"0xab`,
+		"no result":         `{"jsonrpc":"2.0","id":"0"}`,
+		"null result":       `{"jsonrpc":"2.0","id":"0","result":null}`,
+		"result not object": `{"jsonrpc":"2.0","id":"0","result":"0x1"}`,
+		"missing field":     `{"jsonrpc":"2.0","id":"0","result":{"blockHash":"0xab","shardID":0}}`,
+		"wrong type":        `{"jsonrpc":"2.0","id":"0","result":{"blockHash":"0xab","blockNumber":"ten","shardID":0}}`,
+	} {
+		err := decodeResult(BlockHeaderRPC, []byte(payload), &BlockHeaderReply{})
+		var malformed *malformedReplyError
+		if !errors.As(err, &malformed) {
+			t.Errorf("%s: error %v, want a malformed reply", name, err)
+		}
+	}
+	reply := BlockHeaderReply{}
+	err := decodeResult(BlockHeaderRPC,
+		[]byte(`{"jsonrpc":"2.0","id":"0","result":{"blockHash":"0xab","blockNumber":10,"shardID":1}}`), &reply,
+	)
+	if err != nil || reply.BlockNumber != 10 || reply.ShardID != 1 {
+		t.Errorf("well formed reply decoded to %+v, %v", reply, err)
+	}
+}
+
+func TestPayloadSampleTruncated(t *testing.T) {
+	payload := strings.Repeat("é", malformedSampleSize)
+	sample := payloadSample([]byte(payload))
+	if !strings.HasSuffix(sample, fmt.Sprintf("... (%d bytes)", len(payload))) {
+		t.Errorf("sample %q does not give the payload size", sample)
+	}
+	kept := strings.SplitN(sample, "...", 2)[0]
+	if len(kept) > malformedSampleSize || !utf8.ValidString(kept) || !strings.HasPrefix(payload, kept) {
+		t.Errorf("sample %q is not a whole-rune prefix of the payload", sample)
+	}
+}
+
+func TestMalformedRepliesAlertShard(t *testing.T) {
+	good0, _ := blockHeaderNode(t, 0, 100)
+	good0b, _ := blockHeaderNode(t, 0, 100)
+	bad0 := rawNode(t, `not json`)
+	bad1 := rawNode(t, `{"jsonrpc":"2.0","id":"0","result":{"hash":"0xab"}}`)
+	bad1b := rawNode(t, `{"jsonrpc":"2.0","id":"0","result":{"blockHash":"0xab`)
+	bad1c := rawNode(t, `{"jsonrpc":"2.0","id":"0","result":{"blockHash":"0xab","blockNumber":1,"shardID":1}}`+"garbage")
+	c := newTestCycle(t, map[int][]string{0: {good0, good0b, bad0}, 1: {bad1, bad1b, bad1c}})
+	sender := testAlerter(t, c.m)
+
+	data := c.poll()
+	if len(data.Down) != 0 {
+		t.Errorf("%d nodes counted as unreachable, want none", len(data.Down))
+	}
+	malformed := map[string]bool{}
+	for _, d := range data.Malformed {
+		if d.Category != failureMalformed {
+			t.Errorf("%s recorded as %s, want %s", d.IP, d.Category, failureMalformed)
+		}
+		malformed[d.IP] = true
+	}
+	for _, n := range []string{bad0, bad1, bad1b, bad1c} {
+		if !malformed[n] {
+			t.Errorf("%s not recorded as malformed", n)
+		}
+	}
+	if got := repliedShards(data); got[0] != 2 {
+		t.Errorf("%d headers of shard 0, want the 2 well formed", got[0])
+	}
+	// One of three is a node problem, all three a bad release
+	want := fmt.Sprintf("trigger Shard 1 malformed %s replies! - testnet", BlockHeaderRPC)
+	if e := sender.next(t); e != want {
+		t.Errorf("posted %q, want %q", e, want)
+	}
+	sender.none(t)
+}
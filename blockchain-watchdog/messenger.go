@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// Title of the message posted when an alert clears, before its key
+const recoveredPrefix = "Recovered: "
+
+// Delivers one message about the alert to a chat channel, the title is a
+// single line
+type chatSender interface {
+	send(al alert, title, body string) error
+}
+
+// A sender whose channel keeps alerts of its own, such as OpsGenie, closes
+// them on recovery instead of posting a recovery message
+type alertCloser interface {
+	close(al alert, note string) error
+}
+
+// A chat channel such as Slack that alerts are posted to as messages.
+// Chats have no incidents to update, so an alert is posted when it starts
+// firing and again once escalated, not on every check that finds it still
+// in breach. Digests and group-window only apply to PagerDuty
+type messenger struct {
+	name        string
+	templates   *alertTemplates
+	minSeverity string
+	queue       *deliveryQueue
+	sender      chatSender
+}
+
+func (a *alerter) addMessenger(name string, t messageTemplate, minSeverity string, sender chatSender) {
+	m := &messenger{
+		name:        name,
+		minSeverity: minSeverity,
+		queue:       newDeliveryQueue(name, a.audit, func(err error) { a.delivered(name, err) }),
+		sender:      sender,
+	}
+	// Already validated by sanityCheck
+	m.templates, _ = parseTemplates(name, t)
+	a.messengers = append(a.messengers, m)
+}
+
+// At or above the channel's min-severity and not about it failing
+func (m *messenger) routes(al alert) bool {
+	return severityRank[al.Severity] >= severityRank[m.minSeverity] && al.channel != m.name
+}
+
+// Messengers the alert is due to be posted to, marks it posted. Expects
+// the lock to be held
+func (a *alerter) duePosts(entry *activeAlert, al alert, escalated bool) []*messenger {
+	due := []*messenger{}
+	for _, m := range a.messengers {
+		if !m.routes(al) || entry.posted[m] && !escalated {
+			continue
+		}
+		if entry.posted == nil {
+			entry.posted = map[*messenger]bool{}
+		}
+		entry.posted[m] = true
+		due = append(due, m)
+	}
+	return due
+}
+
+func (a *alerter) postAlert(m *messenger, al alert, now time.Time) {
+	subject, body := m.templates.render(al, now)
+	a.postMessage(m, al, fmt.Sprintf("[%s] %s", al.Severity, subject), body, "post")
+}
+
+// Only to the messengers the alert was posted to, with notify-on-recovery.
+// Alerts kept by the channel are closed either way, an open one would
+// swallow the next breach of the check as a duplicate
+func (a *alerter) postRecovery(entry *activeAlert, message string) {
+	for _, m := range a.messengers {
+		if !entry.posted[m] {
+			continue
+		}
+		if closer, ok := m.sender.(alertCloser); ok {
+			a.closeAlert(m, closer, entry.alert, message)
+		} else if a.notifyOnRecovery {
+			a.postMessage(m, entry.alert, recoveredPrefix+entry.Key, message, "recovery")
+		}
+	}
+}
+
+func (a *alerter) postMessage(m *messenger, al alert, title, body, action string) {
+	if a.dryRun {
+		stdlog.Printf("[dryRun] Would post %s to %s\n%s", title, channelNames[m.name], body)
+		return
+	}
+	err := m.queue.push(auditEventOf(al, action, title, body), func() error { return m.sender.send(al, title, body) })
+	if err != nil {
+		errlog.Print(err)
+	}
+}
+
+func (a *alerter) closeAlert(m *messenger, closer alertCloser, al alert, note string) {
+	if a.dryRun {
+		stdlog.Printf("[dryRun] Would close %s on %s", al.Key, channelNames[m.name])
+		return
+	}
+	err := m.queue.push(auditEventOf(al, "close", "", note), func() error { return closer.close(al, note) })
+	if err != nil {
+		errlog.Print(err)
+	}
+}
+
+// Cuts text to at most max bytes, ... included, on a character boundary
+// for channels that reject long messages
+func cutMessage(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	cut := max - len("...")
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "..."
+}
+
+func (a *alerter) shutdownMessengers() {
+	for _, m := range a.messengers {
+		m.queue.shutdown()
+	}
+}
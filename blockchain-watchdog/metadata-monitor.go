@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fields are the node metadata JSON names compared between polls, none
+// means defaultMetadataFields. Alert also raises an alert per changed node
+type metadataParams struct {
+	checkToggle `yaml:",inline"`
+	Fields      []string `yaml:"fields"`
+	Alert       bool     `yaml:"alert"`
+}
+
+// Consensus and p2p-connectivity change every poll, so they are left out
+var defaultMetadataFields = []string{
+	"role", "is-leader", "is-archival", "version", "shard-id", "blskey",
+}
+
+type metadataChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+// Top level fields of the reply by JSON name
+func metadataFields(reply NodeMetadataReply) map[string]string {
+	v := reflect.ValueOf(reply)
+	fields := map[string]string{}
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		fields[name] = fmt.Sprint(v.Field(i).Interface())
+	}
+	return fields
+}
+
+func metadataDiff(before, after NodeMetadataReply, fields []string) []metadataChange {
+	from, to := metadataFields(before), metadataFields(after)
+	changes := []metadataChange{}
+	for _, f := range fields {
+		if from[f] != to[f] {
+			changes = append(changes, metadataChange{f, from[f], to[f]})
+		}
+	}
+	return changes
+}
+
+// Nodes that stop replying keep their last metadata, so a node coming back
+// changed is still reported
+func (m *monitor) metadataMonitor(params metadataParams, chain string, data MetadataContainer) {
+	fields := params.Fields
+	if len(fields) == 0 {
+		fields = defaultMetadataFields
+	}
+	m.inUse.Lock()
+	previous := m.nodeMetadata
+	current := map[string]NodeMetadataReply{}
+	for ip, reply := range previous {
+		current[ip] = reply
+	}
+	for _, metadata := range data.Nodes {
+		current[metadata.IP] = metadata.Payload
+	}
+	m.nodeMetadata = current
+	m.inUse.Unlock()
+
+	polled := map[string]bool{}
+	for _, metadata := range data.Nodes {
+		shard := strconv.FormatUint(uint64(metadata.Payload.ShardID), 10)
+		if !polled[shard] {
+			m.markPolled(shard, metadataCheck)
+			polled[shard] = true
+		}
+		node := m.nodeName(metadata.IP)
+		incidentKey := fmt.Sprintf("Node %s metadata changed! - %s", node, chain)
+		before, known := previous[metadata.IP]
+		if !known {
+			continue
+		}
+		changes := metadataDiff(before, metadata.Payload, fields)
+		if len(changes) == 0 {
+			m.alerts.clear(incidentKey)
+			continue
+		}
+		var lines strings.Builder
+		logger := withFields(stdlog, logFields{Shard: shard, Check: metadataCheck, Node: metadata.IP})
+		for _, c := range changes {
+			logger.Printf("[metadataMonitor] %s on shard %s: %s %s -> %s",
+				node, shard, c.Field, c.From, c.To,
+			)
+			fmt.Fprintf(&lines, "%s: %s -> %s\n", c.Field, c.From, c.To)
+		}
+		if !params.Alert {
+			continue
+		}
+		message := fmt.Sprintf(metadataChangeMessage, node, shard, lines.String(), chain)
+		err := m.alerts.trigger(alert{
+			Key: incidentKey, Chain: chain, Shard: shard,
+			Check: metadataCheck, Message: message,
+			Value: strconv.Itoa(len(changes)),
+			Nodes: []string{metadata.IP},
+		})
+		if err != nil {
+			errlog.Print(err)
+		} else {
+			stdlog.Printf("[metadataMonitor] Alert triggered! %s", incidentKey)
+		}
+	}
+}
+
+type shardDetail struct {
+	shardStatus
+	Metadata map[string]NodeMetadataReply `json:"node-metadata"`
+	// Set when the shard is polled through a single load-balanced endpoint
+	Endpoint string `json:"load-balanced-endpoint,omitempty"`
+	// Nodes that failed the latest poll by node name, why and of which RPC
+	Failures map[string][]nodeFailure `json:"node-failures"`
+	// Internal and external nodes of the shard by group
+	Groups map[string]groupHealth `json:"node-groups"`
+}
+
+type nodeFailure struct {
+	RPC      string `json:"rpc"`
+	Category string `json:"category"`
+	Error    string `json:"error"`
+}
+
+// Serves /status-<chain>/<shard>, the shard entry of /status together with
+// the last metadata and the failed polls of each node on the shard by node name
+func (m *monitor) shardStatusJSON(w http.ResponseWriter, req *http.Request) {
+	shard := strings.TrimPrefix(req.URL.Path, "/status-"+m.chain+"/")
+	for _, s := range m.statusSnapshot().Shards {
+		if s.ShardID != shard {
+			continue
+		}
+		detail := shardDetail{s, map[string]NodeMetadataReply{}, "", map[string][]nodeFailure{}, nil}
+		m.inUse.Lock()
+		detail.Endpoint = m.loadBalancedOf(shard)
+		detail.Groups = m.shardGroupsOf(shard)
+		down := map[string][]noReply{
+			BlockHeaderRPC: append(append([]noReply{}, m.BlockHeaderSnapshot.Down...),
+				m.BlockHeaderSnapshot.Malformed...),
+			NodeMetadataRPC: append(append([]noReply{}, m.MetadataSnapshot.Down...),
+				m.MetadataSnapshot.Malformed...),
+		}
+		for _, rpc := range []string{BlockHeaderRPC, NodeMetadataRPC} {
+			for _, d := range down[rpc] {
+				if strconv.Itoa(d.ShardID) == shard {
+					name := m.nodeNameOf(d.IP)
+					detail.Failures[name] = append(detail.Failures[name],
+						nodeFailure{rpc, d.Category, d.FailureReason},
+					)
+				}
+			}
+		}
+		for ip, reply := range m.nodeMetadata {
+			if strconv.FormatUint(uint64(reply.ShardID), 10) == shard {
+				detail.Metadata[m.nodeNameOf(ip)] = reply
+			}
+		}
+		m.inUse.Unlock()
+		json.NewEncoder(w).Encode(detail)
+		return
+	}
+	http.Error(w, "Unknown shard "+shard, http.StatusNotFound)
+}
+
+// Unknown fields in the config, sorted
+func unknownMetadataFields(fields []string) []string {
+	known := metadataFields(NodeMetadataReply{})
+	unknown := []string{}
+	for _, f := range fields {
+		if _, exists := known[f]; !exists {
+			unknown = append(unknown, f)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
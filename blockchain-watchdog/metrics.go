@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Seconds, covers fast local replies up to calls hitting a long http-timeout
+var rpcLatencyBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Per shard metrics carry both chain and shard, shard values come from the
+// known committee only so series count is bounded by the configured nodes
+var (
+	rpcDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "watchdog",
+			Name:      "rpc_duration_seconds",
+			Help:      "Duration of RPC calls to monitored nodes, failed calls included",
+			Buckets:   rpcLatencyBuckets,
+		},
+		[]string{"chain", "shard", "method"},
+	)
+	nodeUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "watchdog",
+			Name:      "node_up",
+			Help:      "1 if the node replied to the latest block header poll, 0 otherwise",
+		},
+		[]string{"chain", "shard", "node"},
+	)
+	shardHeight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "watchdog",
+			Name:      "shard_height",
+			Help:      "Highest block number reported by the shard's nodes in the latest consensus check",
+		},
+		[]string{"chain", "shard"},
+	)
+	consensusUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "watchdog",
+			Name:      "consensus_up",
+			Help:      "1 if the shard made progress within the consensus warning, 0 if stuck",
+		},
+		[]string{"chain", "shard"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(rpcDuration, nodeUp, shardHeight, consensusUp)
+}
+
+func (m *monitor) observeRPC(address, rpc string, start time.Time) {
+	rpcDuration.WithLabelValues(m.chain, m.shardLabel(address), rpc).Observe(time.Since(start).Seconds())
+}
+
+// Shard of the node as a metric label, unknown once it left the committee
+func (m *monitor) shardLabel(address string) string {
+	m.inUse.Lock()
+	shard, known := m.nodes[address]
+	m.inUse.Unlock()
+	if !known {
+		return "unknown"
+	}
+	return strconv.Itoa(shard)
+}
+
+// Series of nodes that left the committee are dropped
+func (m *monitor) recordNodeUp(shardMap map[string]int, data BlockHeaderContainer) {
+	up := map[string]float64{}
+	for n := range shardMap {
+		up[n] = 0
+	}
+	for _, n := range data.Nodes {
+		if _, known := shardMap[n.IP]; known {
+			up[n.IP] = 1
+		}
+	}
+	reachable := map[int]int{}
+	for n, v := range up {
+		nodeUp.WithLabelValues(m.chain, strconv.Itoa(shardMap[n]), n).Set(v)
+		reachable[shardMap[n]] += int(v)
+	}
+	m.statsd.recordReachable(reachable)
+	m.inUse.Lock()
+	previous := m.upSeries
+	m.upSeries = shardMap
+	m.inUse.Unlock()
+	for n, shard := range previous {
+		if s, still := shardMap[n]; !still || s != shard {
+			nodeUp.DeleteLabelValues(m.chain, strconv.Itoa(shard), n)
+		}
+	}
+}
+
+// Series of shards without replies in the latest consensus check are dropped
+// rather than kept at their last value
+func (m *monitor) recordConsensus(heights map[string]uint64, progress map[string]bool) {
+	for shard, height := range heights {
+		shardHeight.WithLabelValues(m.chain, shard).Set(float64(height))
+	}
+	for shard, ok := range progress {
+		v := 0.0
+		if ok {
+			v = 1
+		}
+		consensusUp.WithLabelValues(m.chain, shard).Set(v)
+	}
+	m.statsd.recordConsensus(heights, progress)
+	m.inUse.Lock()
+	previous := m.consensusSeries
+	m.consensusSeries = map[string]bool{}
+	for shard := range heights {
+		m.consensusSeries[shard] = true
+	}
+	m.inUse.Unlock()
+	for shard := range previous {
+		if _, still := heights[shard]; !still {
+			shardHeight.DeleteLabelValues(m.chain, shard)
+		}
+		if _, still := progress[shard]; !still {
+			consensusUp.DeleteLabelValues(m.chain, shard)
+		}
+	}
+}
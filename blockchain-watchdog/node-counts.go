@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var shardNodes = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: "watchdog",
+		Name:      "shard_nodes",
+		Help:      "Monitored nodes of the shard as loaded from node-distribution",
+	},
+	[]string{"chain", "shard"},
+)
+
+func init() {
+	prometheus.MustRegister(shardNodes)
+}
+
+// Reloads node-distribution on SIGHUP, the current committees stay when
+// the reload fails. With rpc-discovery the endpoints are asked again
+func (service *Service) reloadCommittees() {
+	if service.DistributionFiles.RPCDiscovery.Enabled {
+		service.refreshCommittees()
+		return
+	}
+	stdlog.Print("[reloadCommittees] Reloading node-distribution")
+	byShard, err := loadCommittees(service.watchParams)
+	if err != nil {
+		errlog.Printf("[reloadCommittees] Keeping current committees, Error: %v", err)
+		return
+	}
+	service.setShardMap(byShard)
+	for _, shard := range sortedShards(byShard) {
+		stdlog.Printf("[reloadCommittees] Shard %d, Nodes: %d", shard, len(byShard[shard].members))
+	}
+}
+
+// Previous is nil on the first load. A shard that was dropped counts as
+// zero nodes, a shard new to the committees is never a change
+func (m *monitor) recordNodeCounts(previous, counts map[int]int) {
+	for shard, count := range counts {
+		shardNodes.WithLabelValues(m.chain, strconv.Itoa(shard)).Set(float64(count))
+	}
+	for shard := range previous {
+		if _, still := counts[shard]; !still {
+			shardNodes.DeleteLabelValues(m.chain, strconv.Itoa(shard))
+		}
+	}
+	if previous == nil || m.countChange == 0 {
+		return
+	}
+	shards := []int{}
+	for shard := range previous {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	for _, shard := range shards {
+		m.checkNodeCount(shard, previous[shard], counts[shard])
+	}
+}
+
+// Fires once per reload, the next reload that changes the count by no more
+// than count-change clears it
+func (m *monitor) checkNodeCount(shard, before, after int) {
+	incidentKey := fmt.Sprintf("Shard %d node count changed - %s", shard, m.chain)
+	if before == 0 {
+		m.alerts.clear(incidentKey)
+		return
+	}
+	delta := after - before
+	if delta < 0 {
+		delta = -delta
+	}
+	change := delta * 100 / before
+	if delta*100 <= m.countChange*before {
+		m.alerts.clear(incidentKey)
+		return
+	}
+	err := m.alerts.trigger(alert{
+		Key: incidentKey, Chain: m.chain, Shard: strconv.Itoa(shard),
+		Check: nodeCountCheck, Severity: severityInfo,
+		Message: fmt.Sprintf(nodeCountMessage, shard, before, after, change, m.countChange, m.chain),
+		Value:   strconv.Itoa(change), Threshold: strconv.Itoa(m.countChange),
+	})
+	if err != nil {
+		errlog.Print(err)
+	} else {
+		stdlog.Printf("[checkNodeCount] Alert triggered! %s", incidentKey)
+	}
+}
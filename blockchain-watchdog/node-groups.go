@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Nodes are tagged with group=internal or group=external in their
+// distribution file, untagged nodes are external
+const (
+	groupInternal = "internal"
+	groupExternal = "external"
+	groupPrefix   = "group="
+)
+
+// The group of a group= word of a distribution file line
+func parseGroup(word string) (string, error) {
+	group := strings.TrimPrefix(word, groupPrefix)
+	if group != groupInternal && group != groupExternal {
+		return "", fmt.Errorf("unknown node group %q, expected %s or %s",
+			group, groupInternal, groupExternal,
+		)
+	}
+	return group, nil
+}
+
+// Stricter thresholds for the internal nodes of a shard on their own, next
+// to the shard wide ones, zero leaves either out
+type internalParams struct {
+	Connectivity int
+	Quorum       int
+}
+
+// Per shard and group, as of the latest connectivity and shard height checks
+type groupHealth struct {
+	Nodes        int `json:"nodes"`
+	Connectivity int `json:"avg-connectivity"`
+	Reporting    int `json:"reporting-height"`
+	OffHeight    int `json:"off-height"`
+}
+
+// Expects the lock to be held
+func (m *monitor) nodeGroupOf(address string) string {
+	if group, exists := m.groups[address]; exists {
+		return group
+	}
+	return groupExternal
+}
+
+func (m *monitor) nodeGroup(address string) string {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	return m.nodeGroupOf(address)
+}
+
+func (m *monitor) updateGroupHealth(shard, group string, update func(*groupHealth)) {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	if m.groupHealth == nil {
+		m.groupHealth = map[string]map[string]groupHealth{}
+	}
+	if m.groupHealth[shard] == nil {
+		m.groupHealth[shard] = map[string]groupHealth{}
+	}
+	health := m.groupHealth[shard][group]
+	update(&health)
+	m.groupHealth[shard][group] = health
+}
+
+// Groups with at least one member on the shard, expects the lock to be held
+func (m *monitor) shardGroupsOf(shard string) map[string]groupHealth {
+	groups := map[string]groupHealth{}
+	for address, s := range m.nodes {
+		if strconv.Itoa(s) != shard {
+			continue
+		}
+		group := m.nodeGroupOf(address)
+		health, counted := groups[group]
+		if !counted {
+			health = m.groupHealth[shard][group]
+		}
+		health.Nodes++
+		groups[group] = health
+	}
+	return groups
+}
+
+func (m *monitor) checkInternalConnectivity(shard int, avg, margin int, chain string) {
+	tolerance := m.internal.Connectivity
+	incidentKey := fmt.Sprintf("Shard %d internal connectivity lower than threshold - %s", shard, chain)
+	overTrigger := avg != 0 && avg < tolerance
+	pastClear := avg == 0 || avg >= tolerance+margin
+	if !m.alerts.breached(incidentKey, overTrigger, pastClear) {
+		m.alerts.clear(incidentKey)
+		return
+	}
+	message := fmt.Sprintf(internalP2PMessage, shard, avg, tolerance, chain)
+	err := m.alerts.trigger(alert{
+		Key: incidentKey, Chain: chain, Shard: strconv.Itoa(shard),
+		Check: connectivityCheck, Message: message,
+		Value: strconv.Itoa(avg), Threshold: strconv.Itoa(tolerance),
+	})
+	if err != nil {
+		errlog.Print(err)
+	} else {
+		stdlog.Printf("[p2pMonitor] Alert triggered! %s", incidentKey)
+	}
+}
+
+func (m *monitor) checkInternalQuorum(shard, chain string, outliers, nodes int, tolerance, reference uint64) {
+	quorum := m.internal.Quorum
+	incidentKey := fmt.Sprintf("Shard %s internal nodes out of sync! - %s", shard, chain)
+	if outliers*100 < quorum*nodes {
+		m.alerts.clear(incidentKey)
+		return
+	}
+	message := fmt.Sprintf(internalQuorumMessage, outliers, nodes, tolerance, reference, shard, chain)
+	err := m.alerts.trigger(alert{
+		Key: incidentKey, Chain: chain, Shard: shard,
+		Check: shardHeightCheck, Message: message,
+		Value:     strconv.Itoa(outliers * 100 / nodes),
+		Threshold: strconv.Itoa(quorum),
+	})
+	if err != nil {
+		errlog.Print(err)
+	} else {
+		stdlog.Printf("[checkShardHeight] Alert triggered! %s", incidentKey)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// A number of nodes, either absolute such as 3 or as a fraction of the
+// committee size of the shard such as 0.33, so one config fits shards of
+// any size. Zero disables the limit
+type nodeLimit struct {
+	Count int
+	Ratio float64
+}
+
+// yaml.v2 truncates a fraction decoded into an int, so numbers are read as
+// floats and whole ones taken as counts
+func (l *nodeLimit) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var ratio float64
+	if err := unmarshal(&ratio); err != nil {
+		return err
+	}
+	if ratio == math.Trunc(ratio) {
+		*l = nodeLimit{Count: int(ratio)}
+		return nil
+	}
+	if ratio <= 0 || ratio >= 1 {
+		return fmt.Errorf("node limit %v is neither a whole number of nodes nor a fraction between 0 and 1", ratio)
+	}
+	*l = nodeLimit{Ratio: ratio}
+	return nil
+}
+
+func (l nodeLimit) MarshalYAML() (interface{}, error) {
+	if l.Ratio != 0 {
+		return l.Ratio, nil
+	}
+	return l.Count, nil
+}
+
+func (l nodeLimit) set() bool {
+	return l.Count > 0 || l.Ratio > 0
+}
+
+// The limit for a committee of size nodes, a ratio rounds up so it never
+// comes out as zero nodes
+func (l nodeLimit) of(size int) int {
+	if l.Ratio == 0 {
+		return l.Count
+	}
+	return int(math.Ceil(l.Ratio * float64(size)))
+}
+
+func (l nodeLimit) String() string {
+	if l.Ratio != 0 {
+		return strconv.FormatFloat(l.Ratio, 'f', -1, 64) + " of the committee"
+	}
+	return strconv.Itoa(l.Count)
+}
+
+// Nodes-off limits of the connectivity and shard height checks
+type nodeLimits struct {
+	Connectivity nodeLimit
+	Height       nodeLimit
+}
+
+// Members of the shard as loaded from node-distribution
+func (m *monitor) committeeSize(shard int) int {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	size := 0
+	for _, s := range m.nodes {
+		if s == shard {
+			size++
+		}
+	}
+	return size
+}
+
+func (m *monitor) checkNodesOff(check, shard, chain string, off int, limit nodeLimit, key, message string) {
+	id, _ := strconv.Atoi(shard)
+	size := m.committeeSize(id)
+	threshold := limit.of(size)
+	if off < threshold {
+		m.alerts.clear(key)
+		return
+	}
+	err := m.alerts.trigger(alert{
+		Key: key, Chain: chain, Shard: shard,
+		Check: check, Message: fmt.Sprintf(message, off, size, limit, threshold, shard, chain),
+		Value: strconv.Itoa(off), Threshold: strconv.Itoa(threshold),
+	})
+	if err != nil {
+		errlog.Print(err)
+	} else {
+		stdlog.Printf("[checkNodesOff] Alert triggered! %s", key)
+	}
+}
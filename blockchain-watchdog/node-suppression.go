@@ -0,0 +1,41 @@
+package main
+
+import "strings"
+
+// Nodes whose alerts are never sent, e.g. decommissioned or chronically
+// flaky community nodes. Their failures still show on the report and the
+// status pages. labels match a word of a node's label in its distribution
+// file, nodes are addresses with or without the port
+type suppressParams struct {
+	Nodes  []string `yaml:"nodes"`
+	Labels []string `yaml:"labels"`
+}
+
+func (m *monitor) suppressedNode(address string) bool {
+	if listsNode(m.suppress.Nodes, address) {
+		return true
+	}
+	m.inUse.Lock()
+	label := m.labels[address]
+	m.inUse.Unlock()
+	for _, word := range strings.Fields(label) {
+		if containsString(m.suppress.Labels, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// Alerts about nodes only, all of them suppressed. Shard-wide alerts such
+// as consensus still go out
+func (a *alerter) suppressed(al alert) bool {
+	if a.suppresses == nil || len(al.Nodes) == 0 {
+		return false
+	}
+	for _, n := range al.Nodes {
+		if !a.suppresses(n) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// API key of an OpsGenie API integration and the team alerts are assigned
+// to, the alerts of a check listed in check-teams go to that team instead.
+// api-url is only needed for the EU instance, https://api.eu.opsgenie.com
+type opsGenieParams struct {
+	APIKey     string            `yaml:"api-key"`
+	Team       string            `yaml:"team"`
+	CheckTeams map[string]string `yaml:"check-teams"`
+	APIURL     string            `yaml:"api-url"`
+}
+
+const (
+	opsGenieAPI = "https://api.opsgenie.com"
+	// Limits of the alert API on the message and description
+	opsGenieMaxMessage     = 130
+	opsGenieMaxDescription = 15000
+)
+
+var opsGeniePriorities = map[string]string{
+	severityInfo:     "P5",
+	severityWarning:  "P3",
+	severityError:    "P2",
+	severityCritical: "P1",
+}
+
+// OpsGenie keeps an alert open until closed, the watchdog alert key is its
+// alias so a check firing again while open is counted as a duplicate
+type opsGenieSender struct {
+	params opsGenieParams
+	client *http.Client
+}
+
+func (o *opsGenieSender) team(check string) string {
+	if t, exists := o.params.CheckTeams[check]; exists {
+		return t
+	}
+	return o.params.Team
+}
+
+func (o *opsGenieSender) post(path string, payload map[string]interface{}) error {
+	api := o.params.APIURL
+	if api == "" {
+		api = opsGenieAPI
+	}
+	data, _ := json.Marshal(payload)
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(api, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return permanent{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "GenieKey "+o.params.APIKey)
+	res, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return chatStatus("opsgenie", res)
+}
+
+func (o *opsGenieSender) send(al alert, title, body string) error {
+	payload := map[string]interface{}{
+		"message":     cutMessage(title, opsGenieMaxMessage),
+		"alias":       al.Key,
+		"description": cutMessage(strings.TrimSpace(body), opsGenieMaxDescription),
+		"priority":    opsGeniePriorities[al.Severity],
+		"source":      al.Chain,
+		"tags":        []string{al.Chain, al.Check},
+	}
+	if al.Shard != "" {
+		payload["entity"] = "shard " + al.Shard
+	}
+	if team := o.team(al.Check); team != "" {
+		payload["responders"] = []map[string]string{{"type": "team", "name": team}}
+	}
+	return o.post("/v2/alerts", payload)
+}
+
+func (o *opsGenieSender) close(al alert, note string) error {
+	return o.post(
+		"/v2/alerts/"+url.PathEscape(al.Key)+"/close?identifierType=alias",
+		map[string]interface{}{"source": al.Chain, "note": cutMessage(strings.TrimSpace(note), opsGenieMaxDescription)},
+	)
+}
@@ -2,11 +2,15 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 )
 
-func (m *monitor) p2pMonitor(tolerance int, pdServiceKey, chain string, data MetadataContainer) {
+func (m *monitor) p2pMonitor(tolerance, margin int, chain string, data MetadataContainer) {
 	stdlog.Print("[p2pMonitor] Running p2p connectivity check")
 	percent := map[int][]int{}
+	byGroup := map[int]map[string][]int{}
+	// Nodes below tolerance, those without known peers are left out
+	below := map[int]int{}
 	for _, metadata := range data.Nodes {
 		shard := int(metadata.Payload.ShardID)
 		connection := 0
@@ -16,8 +20,17 @@ func (m *monitor) p2pMonitor(tolerance int, pdServiceKey, chain string, data Met
 			connection = int(connected / known * 100)
 		}
 		percent[shard] = append(percent[shard], connection)
+		if connection != 0 && connection < tolerance {
+			below[shard]++
+		}
+		if byGroup[shard] == nil {
+			byGroup[shard] = map[string][]int{}
+		}
+		group := m.nodeGroup(metadata.IP)
+		byGroup[shard][group] = append(byGroup[shard][group], connection)
 	}
-	for shard, values := range(percent) {
+	for shard, values := range percent {
+		m.markPolled(strconv.Itoa(shard), connectivityCheck)
 		avg := 0
 		if len(values) > 0 {
 			sum := 0
@@ -25,17 +38,50 @@ func (m *monitor) p2pMonitor(tolerance int, pdServiceKey, chain string, data Met
 				sum = sum + v
 			}
 			avg = int(float64(sum) / float64(len(values)))
-			if avg != 0 && avg < tolerance {
+			incidentKey := fmt.Sprintf("Shard %d connectivity lower than threshold - %s", shard, chain)
+			overTrigger := avg != 0 && avg < tolerance
+			pastClear := avg == 0 || avg >= tolerance+margin
+			if m.alerts.breached(incidentKey, overTrigger, pastClear) {
 				message := fmt.Sprintf(p2pMessage, shard, avg)
-				incidentKey := fmt.Sprintf("Shard %d connectivity lower than threshold - %s", shard, chain)
-				err := notify(pdServiceKey, incidentKey, chain, message)
+				err := m.alerts.trigger(alert{
+					Key: incidentKey, Chain: chain, Shard: strconv.Itoa(shard),
+					Check: connectivityCheck, Message: message,
+					Value: strconv.Itoa(avg), Threshold: strconv.Itoa(tolerance),
+				})
 				if err != nil {
 					errlog.Print(err)
 				} else {
-					stdlog.Printf("[p2pMonitor] Send PagerDuty alert! %s", incidentKey)
+					stdlog.Printf("[p2pMonitor] Alert triggered! %s", incidentKey)
 				}
+			} else {
+				m.alerts.clear(incidentKey)
 			}
 		}
 		stdlog.Printf("[p2pMonitor] Shard: %d, Avg Connectivity: %d%%", shard, avg)
+		if m.limits.Connectivity.set() {
+			m.checkNodesOff(connectivityCheck, strconv.Itoa(shard), chain, below[shard], m.limits.Connectivity,
+				fmt.Sprintf("Shard %d nodes poorly connected - %s", shard, chain), connectivityNodesOffMessage,
+			)
+		}
+		for group, values := range byGroup[shard] {
+			groupAvg := average(values)
+			m.updateGroupHealth(strconv.Itoa(shard), group, func(h *groupHealth) {
+				h.Connectivity = groupAvg
+			})
+			if group == groupInternal && m.internal.Connectivity > 0 {
+				m.checkInternalConnectivity(shard, groupAvg, margin, chain)
+			}
+		}
+	}
+}
+
+func average(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, v := range values {
+		sum = sum + v
 	}
+	return int(float64(sum) / float64(len(values)))
 }
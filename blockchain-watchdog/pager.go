@@ -1,20 +1,97 @@
 package main
 
 import (
+	"fmt"
+	"time"
+
 	pd "github.com/PagerDuty/go-pagerduty"
 )
 
-func notify(serviceKey, incidentKey, chain, msg string) error {
-	_, err := pd.ManageEvent(pd.V2Event{
+// Queued for delivery, detected is when the breach was first seen and
+// stays the event timestamp however late it is delivered
+func (a *alerter) notify(serviceKey, incidentKey, summary, msg string, about alert, detected time.Time) error {
+	if a.dryRun {
+		stdlog.Printf("[dryRun] Would trigger %s on the %s PagerDuty service, Severity: %s, Detected: %s, Summary: %s\n%s",
+			incidentKey, a.serviceName(serviceKey), about.Severity, detected.UTC().Format(time.RFC3339), summary, msg,
+		)
+		return nil
+	}
+	e := pd.V2Event{
 		RoutingKey: serviceKey,
 		Action:     "trigger",
 		DedupKey:   incidentKey,
 		Payload: &pd.V2Payload{
-			Summary:  incidentKey,
-			Source:   chain,
-			Severity: "critical",
-			Details:  msg,
+			Summary:   summary,
+			Source:    about.Chain,
+			Severity:  about.Severity,
+			Timestamp: detected.UTC().Format(time.RFC3339),
+			Details:   msg,
 		},
-	})
+	}
+	return a.pager.push(a.pagerEvent(serviceKey, incidentKey, "trigger", about, summary, msg), func() error { return sendEvent(e) })
+}
+
+// PagerDuty has no message-only event, a recovery resolves the incident
+func (a *alerter) notifyRecovery(serviceKey, incidentKey, msg string, about alert) error {
+	stdlog.Printf("[notifyRecovery] Resolving %s: %s", incidentKey, msg)
+	if a.dryRun {
+		stdlog.Printf("[dryRun] Would resolve %s on the %s PagerDuty service", incidentKey, a.serviceName(serviceKey))
+		return nil
+	}
+	e := pd.V2Event{
+		RoutingKey: serviceKey,
+		Action:     "resolve",
+		DedupKey:   incidentKey,
+	}
+	return a.pager.push(a.pagerEvent(serviceKey, incidentKey, "resolve", about, "", msg), func() error { return sendEvent(e) })
+}
+
+// Stops PagerDuty escalating the incident, it still resolves on recovery
+func (a *alerter) notifyAcknowledge(serviceKey, incidentKey string, about alert) error {
+	if a.dryRun {
+		stdlog.Printf("[dryRun] Would acknowledge %s on the %s PagerDuty service", incidentKey, a.serviceName(serviceKey))
+		return nil
+	}
+	e := pd.V2Event{
+		RoutingKey: serviceKey,
+		Action:     "acknowledge",
+		DedupKey:   incidentKey,
+	}
+	return a.pager.push(a.pagerEvent(serviceKey, incidentKey, "acknowledge", about, "", ""), func() error { return sendEvent(e) })
+}
+
+// Keyed by the incident rather than the alert, which differ for grouped
+// alerts, digests and the rollup
+func (a *alerter) pagerEvent(serviceKey, incidentKey, action string, about alert, summary, msg string) auditEvent {
+	e := auditEventOf(about, action, summary, msg)
+	e.Destination, e.Key = a.serviceName(serviceKey), incidentKey
+	return e
+}
+
+func (a *alerter) serviceName(serviceKey string) string {
+	switch serviceKey {
+	case a.serviceKey:
+		return "primary"
+	case a.escalation.EventServiceKey:
+		return "escalation"
+	}
+	return "routed"
+}
+
+func sendEvent(e pd.V2Event) error {
+	_, err := pd.ManageEvent(e)
+	if err != nil && !transientPagerError(err) {
+		return permanent{err}
+	}
 	return err
 }
+
+// The client only reports the status code in the message. An event
+// rejected with a 4xx other than 429 fails the same way on every retry
+func transientPagerError(err error) bool {
+	var code int
+	if _, scanErr := fmt.Sscanf(err.Error(), "HTTP Status Code: %d", &code); scanErr != nil {
+		return true
+	}
+	return code == 429 || code < 400 || code >= 500
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// New alerts per minute across all checks and per check, by its name as
+// in /alerts. Zero leaves either unlimited. Past a limit new alerts only
+// go out in a single rollup, e.g. when a whole data center goes offline
+type rateLimitParams struct {
+	PerMinute int            `yaml:"per-minute"`
+	Checks    map[string]int `yaml:"checks"`
+}
+
+const (
+	rateWindow = time.Minute
+	// The rollup goes out at most this often while alerts are added to it
+	rollupInterval = 15 * time.Second
+)
+
+// Sent times of the new alerts within the last minute, expects the alerter
+// lock to be held
+type rateLimiter struct {
+	params  rateLimitParams
+	sent    []time.Time
+	byCheck map[string][]time.Time
+}
+
+func newRateLimiter(params rateLimitParams) *rateLimiter {
+	return &rateLimiter{params: params, byCheck: map[string][]time.Time{}}
+}
+
+func (r *rateLimiter) enabled() bool {
+	return r.params.PerMinute > 0 || len(r.params.Checks) > 0
+}
+
+// Whether a new alert of the check fits within the limits, it is counted
+// when it does
+func (r *rateLimiter) allow(check string, now time.Time) bool {
+	r.sent = sentSince(r.sent, now.Add(-rateWindow))
+	r.byCheck[check] = sentSince(r.byCheck[check], now.Add(-rateWindow))
+	if r.params.PerMinute > 0 && len(r.sent) >= r.params.PerMinute {
+		return false
+	}
+	if limit := r.params.Checks[check]; limit > 0 && len(r.byCheck[check]) >= limit {
+		return false
+	}
+	r.sent = append(r.sent, now)
+	r.byCheck[check] = append(r.byCheck[check], now)
+	return true
+}
+
+func sentSince(times []time.Time, since time.Time) []time.Time {
+	for len(times) > 0 && !times[0].After(since) {
+		times = times[1:]
+	}
+	return times
+}
+
+func (a *alerter) rollupLoop() {
+	defer close(a.rollupDone)
+	ticker := time.NewTicker(rollupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.flushRollup()
+		case <-a.stopRollup:
+			a.flushRollup()
+			return
+		}
+	}
+}
+
+// Sends one alert listing every alert past a rate limit still firing if
+// any was added since the last flush, and resolves it once none are left
+func (a *alerter) flushRollup() {
+	now := time.Now()
+	a.inUse.Lock()
+	rolled := []alert{}
+	severity := severityInfo
+	for _, entry := range a.active {
+		if !entry.RolledUp {
+			continue
+		}
+		rolled = append(rolled, entry.alert)
+		if severityRank[entry.Severity] > severityRank[severity] {
+			severity = entry.Severity
+		}
+	}
+	pending, open := a.rollupPending, a.rollup != nil
+	a.rollupPending = false
+	rollup := a.rollup
+	key := fmt.Sprintf("Alert rollup - %s", a.chain)
+	al := alert{Key: key, Chain: a.chain, Check: rollupCheck, Severity: severity}
+	if len(rolled) > 0 && pending {
+		al.Message = fmt.Sprintf(rollupMessage, len(rolled), alertLines(rolled), a.chain)
+		if rollup == nil {
+			rollup = &activeAlert{alert: al, FirstSeen: now}
+		}
+		al.firstSeen = rollup.FirstSeen
+		rollup.alert = al
+		a.rollup = rollup
+	} else if len(rolled) == 0 {
+		a.rollup = nil
+	}
+	posts := []*messenger{}
+	if len(rolled) > 0 && pending {
+		// Every update goes out again, like an escalation
+		posts = a.duePosts(rollup, al, true)
+	}
+	a.inUse.Unlock()
+
+	if len(rolled) == 0 {
+		if open {
+			a.postRecovery(rollup, "No alerts past the rate limit firing")
+			if a.autoResolve && a.routes(rollup.alert) {
+				if err := a.notifyRecovery(a.serviceKey, key, "No alerts past the rate limit firing", rollup.alert); err != nil {
+					errlog.Print(err)
+				}
+			}
+		}
+		return
+	}
+	if !pending {
+		return
+	}
+	for _, m := range posts {
+		a.postAlert(m, al, now)
+	}
+	if !a.routes(al) {
+		return
+	}
+	summary := fmt.Sprintf("%d alerts past the rate limit firing - %s", len(rolled), a.chain)
+	if err := a.notify(a.serviceKey, key, summary, al.Message, al, rollup.FirstSeen); err != nil {
+		errlog.Print(err)
+	} else {
+		stdlog.Printf("[alerter] Queued PagerDuty rollup of %d alerts", len(rolled))
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	recordPath   string
+	replayPath   string
+	replayAlerts bool
+	// Set by --record and --replay, every RPC call goes through them
+	recording *recorder
+	replaying *replayer
+)
+
+// The RPC replies of one block-header interval, a recording holds one
+// snapshot per line
+type snapshot struct {
+	Time    time.Time       `json:"time"`
+	Replies []recordedReply `json:"replies"`
+}
+
+// Reply is the raw reply body, failed calls keep their error and its
+// failure category instead
+type recordedReply struct {
+	Node     string          `json:"node"`
+	Method   string          `json:"method"`
+	Params   json.RawMessage `json:"params,omitempty"`
+	Reply    string          `json:"reply,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Category string          `json:"category,omitempty"`
+}
+
+// Replies are told apart by node, method and params, never by request ID
+func replyKey(node string, requestBody []byte) (string, recordedReply) {
+	call := struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}{}
+	json.Unmarshal(requestBody, &call)
+	params := bytes.Buffer{}
+	if len(call.Params) > 0 {
+		json.Compact(&params, call.Params)
+	}
+	node = strings.TrimPrefix(node, rpcScheme)
+	r := recordedReply{Node: node, Method: call.Method}
+	if params.Len() > 0 {
+		r.Params = params.Bytes()
+	}
+	return node + " " + call.Method + " " + params.String(), r
+}
+
+type recorder struct {
+	lock    sync.Mutex
+	file    *os.File
+	current map[string]recordedReply
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newRecorder(path string) (*recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &recorder{
+		file:    file,
+		current: map[string]recordedReply{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// The latest reply of a call within the interval wins
+func (r *recorder) record(node string, requestBody, result []byte, err error) {
+	if r == nil {
+		return
+	}
+	key, reply := replyKey(node, requestBody)
+	if err != nil {
+		reply.Error, reply.Category = err.Error(), classifyRPCError(err)
+	} else {
+		reply.Reply = string(result)
+	}
+	r.lock.Lock()
+	r.current[key] = reply
+	r.lock.Unlock()
+}
+
+func (r *recorder) run(interval time.Duration) {
+	if r == nil {
+		return
+	}
+	defer close(r.done)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			r.flush()
+		case <-r.stop:
+			r.flush()
+			return
+		}
+	}
+}
+
+func (r *recorder) flush() {
+	r.lock.Lock()
+	current := r.current
+	r.current = map[string]recordedReply{}
+	r.lock.Unlock()
+	if len(current) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(current))
+	for k := range current {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	s := snapshot{Time: time.Now().UTC(), Replies: make([]recordedReply, 0, len(keys))}
+	for _, k := range keys {
+		s.Replies = append(s.Replies, current[k])
+	}
+	line, _ := json.Marshal(s)
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		errlog.Printf("[recorder] Could not write snapshot to %s, Error: %v", r.file.Name(), err)
+	}
+}
+
+// Writes out the replies of the running interval, run on shutdown
+func (r *recorder) close() {
+	if r == nil {
+		return
+	}
+	close(r.stop)
+	<-r.done
+	r.file.Close()
+	stdlog.Printf("[recorder] Recording saved to %s", r.file.Name())
+}
+
+type replayer struct {
+	lock      sync.Mutex
+	snapshots []snapshot
+	replies   []map[string]recordedReply
+	index     int
+}
+
+func loadReplay(path string) (*replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r := &replayer{}
+	scanner := bufio.NewScanner(file)
+	// Snapshots of large committees are long lines
+	scanner.Buffer(make([]byte, 1024*1024), 256*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		s := snapshot{}
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		replies := map[string]recordedReply{}
+		for _, reply := range s.Replies {
+			replies[reply.Node+" "+reply.Method+" "+string(reply.Params)] = reply
+		}
+		r.snapshots = append(r.snapshots, s)
+		r.replies = append(r.replies, replies)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(r.snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshot recorded in %s", path)
+	}
+	return r, nil
+}
+
+// Moves on to the next snapshot every interval and holds the last one
+func (r *replayer) run(interval time.Duration) {
+	if r == nil {
+		return
+	}
+	r.logPosition()
+	for range time.Tick(interval) {
+		r.lock.Lock()
+		last := r.index == len(r.snapshots)-1
+		if !last {
+			r.index++
+		}
+		r.lock.Unlock()
+		if last {
+			stdlog.Print("[replay] Reached the last snapshot, replaying it until stopped")
+			return
+		}
+		r.logPosition()
+	}
+}
+
+func (r *replayer) logPosition() {
+	r.lock.Lock()
+	index, s := r.index, r.snapshots[r.index]
+	r.lock.Unlock()
+	stdlog.Printf("[replay] Snapshot %d of %d, recorded at %s",
+		index+1, len(r.snapshots), s.Time.Format(time.RFC3339),
+	)
+}
+
+// Replayed failures classify as they did when recorded
+type replayedError struct {
+	message  string
+	category string
+}
+
+func (e *replayedError) Error() string {
+	return e.message
+}
+
+// Calls polled less often than block-header are answered from the latest
+// snapshot up to the current one that has them
+func (r *replayer) reply(node string, requestBody []byte) ([]byte, []byte, error) {
+	key, _ := replyKey(node, requestBody)
+	r.lock.Lock()
+	index := r.index
+	recorded, exists := recordedReply{}, false
+	for i := index; i >= 0 && !exists; i-- {
+		recorded, exists = r.replies[i][key]
+	}
+	r.lock.Unlock()
+	if !exists {
+		return nil, requestBody, &replayedError{
+			fmt.Sprintf("no reply recorded in snapshot %d", index+1), failureOther,
+		}
+	}
+	if recorded.Error != "" {
+		return nil, requestBody, &replayedError{recorded.Error, recorded.Category}
+	}
+	return []byte(recorded.Reply), nil, nil
+}
+
+// Opened before the config is loaded, so rpc-discovery replays as well
+func openRecording() error {
+	if recordPath != "" && replayPath != "" {
+		return errors.New("--record and --replay can't be combined")
+	}
+	var err error
+	if recordPath != "" {
+		recording, err = newRecorder(recordPath)
+	}
+	if replayPath != "" {
+		replaying, err = loadReplay(replayPath)
+	}
+	return err
+}
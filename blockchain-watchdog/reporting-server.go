@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"net"
 	"net/http"
+	_ "net/http/pprof"
 	"reflect"
 	"sort"
 	"strconv"
@@ -15,7 +17,9 @@ import (
 	"time"
 
 	"github.com/ahmetb/go-linq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/valyala/fasthttp"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -33,6 +37,8 @@ var (
 	headerInformationCSVHeader = []string{"IP"}
 	post                       = []byte("POST")
 	client                     fasthttp.Client
+	// Deadline of every RPC call, http-timeout under performance
+	rpcTimeout time.Duration
 )
 
 func identity(x interface{}) interface{} {
@@ -154,6 +160,48 @@ func summaryMaps(metas []NodeMetadata, headers []BlockHeader) summary {
 }
 
 func request(node string, requestBody []byte) ([]byte, []byte, error) {
+	return requestContext(context.Background(), node, requestBody)
+}
+
+type response struct {
+	result  []byte
+	payload []byte
+	err     error
+}
+
+// Gives up at the earlier of the ctx deadline and http-timeout, or as soon
+// as ctx is cancelled. A request given up on keeps its goroutine until the
+// client read timeout, which is http-timeout as well
+func requestContext(ctx context.Context, node string, requestBody []byte) ([]byte, []byte, error) {
+	if replaying != nil {
+		return replaying.reply(node, requestBody)
+	}
+	ctx, cancel := context.WithTimeout(ctx, rpcTimeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	// Buffered so the request can finish with nobody waiting for it
+	done := make(chan response, 1)
+	go func() {
+		// A misbehaving node must not take the worker, and with it the cycle, down
+		defer func() {
+			if r := recover(); r != nil {
+				done <- response{nil, requestBody, fmt.Errorf("request panicked: %v", r)}
+			}
+		}()
+		result, payload, err := requestBefore(node, requestBody, deadline)
+		done <- response{result, payload, err}
+	}()
+	r := response{nil, requestBody, nil}
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		r.err = fmt.Errorf("request to %s given up: %w", node, ctx.Err())
+	}
+	recording.record(node, requestBody, r.result, r.err)
+	return r.result, r.payload, r.err
+}
+
+func requestBefore(node string, requestBody []byte, deadline time.Time) ([]byte, []byte, error) {
 	const contentType = "application/json"
 	req := fasthttp.AcquireRequest()
 	req.SetBody(requestBody)
@@ -161,17 +209,20 @@ func request(node string, requestBody []byte) ([]byte, []byte, error) {
 	req.Header.SetContentType(contentType)
 	req.SetRequestURIBytes([]byte(node))
 	res := fasthttp.AcquireResponse()
-	if err := client.Do(req, res); err != nil {
+	if err := client.DoDeadline(req, res, deadline); err != nil {
 		return nil, requestBody, err
 	}
 	c := res.StatusCode()
 	if c != 200 {
-		return nil, requestBody, fmt.Errorf("http status code not 200, received: %d", c)
+		return nil, requestBody, httpStatusError(c)
 	}
 	fasthttp.ReleaseRequest(req)
 	body := res.Body()
 	if len(body) == 0 {
-		return nil, requestBody, fmt.Errorf("empty reply received")
+		return nil, requestBody, errEmptyReply
+	}
+	if err := replyError(body); err != nil {
+		return nil, requestBody, err
 	}
 	result := make([]byte, len(body))
 	copy(result, body)
@@ -360,12 +411,14 @@ func (m *monitor) metadataCopy(newData MetadataContainer) {
 	m.MetadataSnapshot.TS = newData.TS
 	m.MetadataSnapshot.Nodes = append([]NodeMetadata{}, newData.Nodes...)
 	m.MetadataSnapshot.Down = append([]noReply{}, newData.Down...)
+	m.MetadataSnapshot.Malformed = append([]noReply{}, newData.Malformed...)
 }
 
 func (m *monitor) blockHeaderCopy(newData BlockHeaderContainer) {
 	m.BlockHeaderSnapshot.TS = newData.TS
 	m.BlockHeaderSnapshot.Nodes = append([]BlockHeader{}, newData.Nodes...)
 	m.BlockHeaderSnapshot.Down = append([]noReply{}, newData.Down...)
+	m.BlockHeaderSnapshot.Malformed = append([]noReply{}, newData.Malformed...)
 }
 
 type noReply struct {
@@ -373,18 +426,23 @@ type noReply struct {
 	FailureReason string
 	RPCPayload    string
 	ShardID       int
+	Category      string
 }
 
 type MetadataContainer struct {
 	TS    time.Time
 	Nodes []NodeMetadata
 	Down  []noReply
+	// Replied but not with a usable result
+	Malformed []noReply
 }
 
 type BlockHeaderContainer struct {
 	TS    time.Time
 	Nodes []BlockHeader
 	Down  []noReply
+	// Replied but not with a usable result
+	Malformed []noReply
 }
 
 type monitor struct {
@@ -399,12 +457,74 @@ type monitor struct {
 	SummarySnapshot     map[string]map[string]interface{}
 	NoReplySnapshot     []noReply
 	consensusProgress   map[string]bool
+	blockTimes          map[string]float64
+	blockAges           map[string]float64
+	signingRates        map[string][]validatorSigning
+	views               map[string]shardViews
+	clockSkews          map[string]map[string]float64
+	pools               map[string]*workerPool
+	upSeries            map[string]int
+	consensusSeries     map[string]bool
+	crossLinkAges       map[int]crossLinkAge
+	balances            []addressBalance
+	chainMismatches     map[string]chainMismatch
+	nodeMetadata        map[string]NodeMetadataReply
+	samples             map[string]int
+	warmUp              int
+	shardSlots          chan struct{}
+	beacon              beaconStatus
+	balanced            map[string]string
+	activeShards        []int
+	cycles              map[string]*cycleReport
+	lastHeights         map[string]uint64
+	beaconAdvanced      time.Time
+	degradedChecks      map[string]map[string]bool
+	firingChecks        map[string]map[string]string
+	checkStates         map[string]map[string]stateEntry
+	alerts              *alerter
+	nodes               map[string]int
+	labels              map[string]string
+	groups              map[string]string
+	internal            internalParams
+	limits              nodeLimits
+	critical            criticalThresholds
+	suppress            suppressParams
+	groupHealth         map[string]map[string]groupHealth
+	discovery           rpcDiscoveryParams
+	selection           shardSelection
+	rpcPort             int
+	jitter              int
+	lastPolls           map[string]map[string]time.Time
+	pollIntervals       map[string]int
+	checks              map[string]bool
+	reportingSocket     net.Listener
+	grpcServer          *grpc.Server
+	grpcDone            chan struct{}
+	watchers            map[chan struct{}]bool
+	// Cancelled on shutdown, see stopWorkers
+	ctx     context.Context
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+	// Nil unless tracing is configured
+	tracer *tracer
+	// Nil unless a statsd host is configured
+	statsd *statsdEmitter
+	// Nil unless an influxdb url is configured
+	influx *influxWriter
+	// Committee size per shard as of the latest load, see count-change
+	nodeCounts  map[int]int
+	countChange int
 }
 
+// Done is optional, called once the reply is sent
 type work struct {
 	address string
 	rpc     string
 	body    []byte
+	queued  time.Time
+	done    func()
+	// Span context of the inspection, m.ctx when nil
+	ctx context.Context
 }
 
 type reply struct {
@@ -413,6 +533,68 @@ type reply struct {
 	rpcPayload []byte
 	rpcResult  []byte
 	oops       error
+	// Failure category of oops
+	category string
+}
+
+// Member address to shard ID of all monitored nodes
+func (m *monitor) setShardMap(superCommittee map[int]committee) {
+	shardMap := map[string]int{}
+	labels := map[string]string{}
+	groups := map[string]string{}
+	balanced := map[string]string{}
+	counts := map[int]int{}
+	for k, v := range superCommittee {
+		counts[k] = len(v.members)
+		for _, member := range v.members {
+			shardMap[member] = k
+		}
+		if v.loadBalanced && len(v.members) > 0 {
+			balanced[strconv.Itoa(k)] = v.members[0]
+		}
+		for member, label := range v.labels {
+			labels[member] = label
+		}
+		for member, group := range v.groups {
+			groups[member] = group
+		}
+	}
+	m.inUse.Lock()
+	m.nodes = shardMap
+	m.labels = labels
+	m.groups = groups
+	m.balanced = balanced
+	m.activeShards = sortedShards(superCommittee)
+	previous := m.nodeCounts
+	m.nodeCounts = counts
+	m.inUse.Unlock()
+	m.recordNodeCounts(previous, counts)
+}
+
+// Label of the node as given in its distribution file, else its address.
+// Expects the lock to be held
+func (m *monitor) nodeNameOf(address string) string {
+	if label, exists := m.labels[address]; exists {
+		return label + " (" + address + ")"
+	}
+	return address
+}
+
+func (m *monitor) nodeName(address string) string {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	return m.nodeNameOf(address)
+}
+
+// Copy of the monitored nodes, taken once per inspection cycle
+func (m *monitor) shardMap() map[string]int {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	shardMap := make(map[string]int, len(m.nodes))
+	for k, v := range m.nodes {
+		shardMap[k] = v
+	}
+	return shardMap
 }
 
 func getBeaconChainNode(shardMap map[string]int) string {
@@ -426,15 +608,43 @@ func getBeaconChainNode(shardMap map[string]int) string {
 	return beaconChainNode
 }
 
-func (m *monitor) worker(
+func (m *monitor) worker(pool *workerPool,
 	jobs chan work, channels map[string](chan reply), groups map[string]*sync.WaitGroup,
 ) {
-	for j := range jobs {
+	defer m.workers.Done()
+	for {
+		var j work
+		select {
+		case <-m.ctx.Done():
+			return
+		case j = <-jobs:
+		}
+		pool.picked(j.queued, len(jobs))
+		pool.busy(1)
 		result := reply{address: j.address, rpc: j.rpc}
-		result.rpcResult, result.rpcPayload, result.oops = request(
-			"http://"+j.address, j.body)
+		ctx := j.ctx
+		if ctx == nil {
+			ctx = m.ctx
+		}
+		ctx, s := m.tracer.start(ctx, j.rpc, spanKindClient,
+			"shard", m.shardLabel(j.address), "node", j.address, "method", j.rpc,
+		)
+		start := time.Now()
+		result.rpcResult, result.rpcPayload, result.oops = requestContext(ctx,
+			rpcScheme+j.address, j.body)
+		m.observeRPC(j.address, j.rpc, start)
+		m.tracer.traceDials(ctx, j.address, start, s)
+		if result.oops != nil {
+			result.category = classifyRPCError(result.oops)
+			m.countRPCFailure(j.address, j.rpc, result.category)
+		}
+		s.end(result.oops)
+		pool.busy(-1)
 		channels[j.rpc] <- result
 		groups[j.rpc].Done()
+		if j.done != nil {
+			j.done()
+		}
 	}
 }
 
@@ -444,7 +654,7 @@ func (m *monitor) stakingCommitteeUpdate(beaconChainNode string) {
 
 	committeeRequestFields["id"] = "0"
 	requestBody, _ := json.Marshal(committeeRequestFields)
-	result, _, oops := request("http://"+beaconChainNode, requestBody)
+	result, _, oops := request(rpcScheme+beaconChainNode, requestBody)
 
 	type s struct {
 		Result SuperCommitteeReply `json:"result"`
@@ -456,77 +666,116 @@ func (m *monitor) stakingCommitteeUpdate(beaconChainNode string) {
 	}
 	committeeReply := s{}
 	json.Unmarshal(result, &committeeReply)
+	m.inUse.Lock()
 	m.SuperCommittee = committeeReply.Result
+	m.inUse.Unlock()
 	stdlog.Print("[stakingCommitteeUpdate] Updated super committees")
 }
 
 func (m *monitor) manager(
-	jobs chan work, interval, tolerance int, shardMap map[string]int,
-	rpc, pdServiceKey, chain string, group *sync.WaitGroup,
+	jobs chan work, interval, tolerance, margin int,
+	views viewSpreadParams, metadataChanges metadataParams,
+	rpc, chain string, group *sync.WaitGroup,
 	channels map[string](chan reply),
 ) {
 	requestFields := getRPCRequest(rpc)
 
 	prevEpoch := uint64(0)
 	for now := range time.Tick(time.Duration(interval) * time.Second) {
-		for n := range shardMap {
-			requestBody, _ := json.Marshal(requestFields)
-			jobs <- work{n, rpc, requestBody}
-			group.Add(1)
-		}
+		start := time.Now()
+		shardMap := m.shardMap()
+		// The reply channel is shared with the workers of the other manager,
+		// so it is never swapped out. Replies are read while jobs are still
+		// being queued, which keeps a committee grown past the channel size
+		// and a cycle of nothing but timeouts from stalling the pool
+		requestBody, _ := json.Marshal(requestFields)
+		ctx, cycle := m.tracer.start(m.ctx, "inspection "+rpc, spanKindInternal, "method", rpc)
+		m.queueShards(ctx, "inspection", jobs, shardMap, rpc, requestBody, interval, group)
 		switch rpc {
 		case NodeMetadataRPC:
 			m.WorkingMetadata.TS = now
 		case BlockHeaderRPC:
 			m.WorkingBlockHeader.TS = now
 		}
+		replies := make([]reply, 0, len(shardMap))
+		shardDone := map[string]time.Time{}
+		for range shardMap {
+			r := <-channels[rpc]
+			replies = append(replies, r)
+			shardDone[strconv.Itoa(shardMap[r.address])] = time.Now()
+		}
 		group.Wait()
-		close(channels[rpc])
+		m.recordCycle(rpc, interval, start, shardDone)
+		// Parsing the replies and handing them to the checks
+		_, aggregate := m.tracer.start(ctx, "aggregate "+rpc, spanKindInternal,
+			"method", rpc, "replies", strconv.Itoa(len(replies)),
+		)
 
 		first := true
 		switch rpc {
 		case NodeMetadataRPC:
-			for d := range channels[rpc] {
+			for _, d := range replies {
 				if first {
 					m.WorkingMetadata.Down = []noReply{}
+					m.WorkingMetadata.Malformed = []noReply{}
 					m.WorkingMetadata.Nodes = []NodeMetadata{}
 					first = false
 				}
 				if d.oops != nil {
 					m.WorkingMetadata.Down = append(m.WorkingMetadata.Down,
-						noReply{d.address, d.oops.Error(), string(d.rpcPayload), shardMap[d.address]})
-				} else {
-					m.bytesToNodeMetadata(d.rpc, d.address, d.rpcResult)
+						noReply{d.address, d.oops.Error(), string(d.rpcPayload), shardMap[d.address], d.category})
+				} else if err := m.bytesToNodeMetadata(d.rpc, d.address, d.rpcResult); err != nil {
+					m.WorkingMetadata.Malformed = append(m.WorkingMetadata.Malformed,
+						m.malformedReply(d, shardMap[d.address], err))
 				}
 			}
+			m.checkMalformedReplies(chain, rpc, shardMap, m.WorkingMetadata.Malformed)
 
 			containerCopy := MetadataContainer{}
 			containerCopy.Nodes = append([]NodeMetadata{}, m.WorkingMetadata.Nodes...)
 
-			go m.p2pMonitor(tolerance, pdServiceKey, chain, containerCopy)
+			nodeLevel := m.nodeLevelMetadata(containerCopy)
+			if m.enabled(connectivityCheck) {
+				go m.p2pMonitor(tolerance, margin, chain, nodeLevel)
+			}
+			if m.enabled(viewSpreadCheck) {
+				go m.viewMonitor(views, chain, nodeLevel)
+			}
+			go m.chainMonitor(chain, containerCopy)
+			if m.enabled(metadataCheck) {
+				go m.metadataMonitor(metadataChanges, chain, nodeLevel)
+			}
 
 			m.inUse.Lock()
 			m.metadataCopy(m.WorkingMetadata)
 			m.inUse.Unlock()
 		case BlockHeaderRPC:
-			for d := range channels[rpc] {
+			for _, d := range replies {
 				if first {
 					m.WorkingBlockHeader.Down = []noReply{}
+					m.WorkingBlockHeader.Malformed = []noReply{}
 					m.WorkingBlockHeader.Nodes = []BlockHeader{}
 					first = false
 				}
 				if d.oops != nil {
 					m.WorkingBlockHeader.Down = append(m.WorkingBlockHeader.Down,
-						noReply{d.address, d.oops.Error(), string(d.rpcPayload), shardMap[d.address]})
-				} else {
-					m.bytesToNodeMetadata(d.rpc, d.address, d.rpcResult)
+						noReply{d.address, d.oops.Error(), string(d.rpcPayload), shardMap[d.address], d.category})
+				} else if err := m.bytesToNodeMetadata(d.rpc, d.address, d.rpcResult); err != nil {
+					// Alerted on by the consensus check, which polls block headers as well
+					m.WorkingBlockHeader.Malformed = append(m.WorkingBlockHeader.Malformed,
+						m.malformedReply(d, shardMap[d.address], err))
 				}
 			}
+			m.recordNodeUp(shardMap, m.WorkingBlockHeader)
+			m.influx.recordNodes(shardMap, m.WorkingBlockHeader)
 			m.inUse.Lock()
 			if len(m.WorkingBlockHeader.Nodes) > 0 {
 				for _, n := range m.WorkingBlockHeader.Nodes {
 					if n.Payload.ShardID == 0 {
 						if n.Payload.Epoch > prevEpoch {
+							if prevEpoch != 0 && m.discovery.RefreshEachEpoch {
+								go m.refreshCommittees()
+							}
 							prevEpoch = n.Payload.Epoch
 							go m.stakingCommitteeUpdate(getBeaconChainNode(shardMap))
 						}
@@ -536,20 +785,18 @@ func (m *monitor) manager(
 			}
 			m.blockHeaderCopy(m.WorkingBlockHeader)
 			m.inUse.Unlock()
+			m.publishCycle()
 		}
-		channels[rpc] = make(chan reply, len(shardMap))
+		aggregate.end(nil)
+		cycle.end(nil)
 	}
 }
 
 func (m *monitor) update(
 	params watchParams, superCommittee map[int]committee, rpcs []string,
 ) {
-	shardMap := map[string]int{}
-	for k, v := range superCommittee {
-		for _, member := range v.members {
-			shardMap[member] = k
-		}
-	}
+	m.setShardMap(superCommittee)
+	shardMap := m.shardMap()
 
 	jobs := make(chan work, len(shardMap))
 	replyChannels := make(map[string](chan reply))
@@ -566,27 +813,35 @@ func (m *monitor) update(
 		}
 	}
 
-	for i := 0; i < params.Performance.WorkerPoolSize; i++ {
-		go m.worker(jobs, replyChannels, syncGroups)
+	// Shared by the block header and node metadata managers
+	interval := int(params.InspectSchedule.BlockHeader)
+	if int(params.InspectSchedule.NodeMetadata) < interval {
+		interval = int(params.InspectSchedule.NodeMetadata)
 	}
+	m.startWorkers("inspection", params.Performance.WorkerPoolSize, interval,
+		jobs, replyChannels, syncGroups,
+	)
 
 	for _, rpc := range rpcs {
 		switch rpc {
 		case NodeMetadataRPC:
 			go m.manager(
-				jobs, params.InspectSchedule.NodeMetadata,
+				jobs, int(params.InspectSchedule.NodeMetadata),
 				params.ShardHealthReporting.Connectivity.Warning,
-				shardMap, rpc,
-				params.Auth.PagerDuty.EventServiceKey,
+				params.ShardHealthReporting.Connectivity.ClearMargin,
+				params.ShardHealthReporting.ViewSpread,
+				params.ShardHealthReporting.Metadata,
+				rpc,
 				params.Network.TargetChain,
 				syncGroups[rpc], replyChannels,
 			)
 		case BlockHeaderRPC:
 			// TODO: Refactor manager
 			go m.manager(
-				jobs, params.InspectSchedule.BlockHeader, 0,
-				shardMap, rpc,
-				"", "",
+				jobs, int(params.InspectSchedule.BlockHeader), 0, 0,
+				viewSpreadParams{}, metadataParams{},
+				rpc,
+				"",
 				syncGroups[rpc], replyChannels,
 			)
 			go m.stakingCommitteeUpdate(getBeaconChainNode(shardMap))
@@ -594,54 +849,77 @@ func (m *monitor) update(
 				uint64(params.ShardHealthReporting.Consensus.Interval),
 				uint64(params.ShardHealthReporting.Consensus.Warning),
 				uint64(params.ShardHealthReporting.ShardHeight.Warning),
+				uint64(params.ShardHealthReporting.ShardHeight.ClearMargin),
+				uint64(params.ShardHealthReporting.ShardHeight.Quorum),
+				uint64(params.ShardHealthReporting.ShardHeight.Quantile),
 				params.Performance.WorkerPoolSize,
-				params.Auth.PagerDuty.EventServiceKey,
 				params.Network.TargetChain,
-				shardMap,
-			)
-			go m.cxMonitor(
-				uint64(params.InspectSchedule.CxPending),
-				uint64(params.ShardHealthReporting.CxPending.Warning),
-				params.Performance.WorkerPoolSize,
-				params.Auth.PagerDuty.EventServiceKey,
-				params.Network.TargetChain,
-				shardMap,
-			)
-			go m.crossLinkMonitor(
-				uint64(params.InspectSchedule.CrossLink),
-				uint64(params.ShardHealthReporting.CrossLink.Warning),
-				params.Performance.WorkerPoolSize,
-				params.Auth.PagerDuty.EventServiceKey,
-				params.Network.TargetChain,
-				shardMap,
+				params.ShardHealthReporting.BlockTime,
+				params.ShardHealthReporting.Signing,
+				params.ShardHealthReporting.BlockAge,
+				params.ShardHealthReporting.Beacon,
+				params.ShardHealthReporting.Regression,
 			)
+			if m.enabled(cxPendingCheck) {
+				go m.cxMonitor(
+					uint64(params.InspectSchedule.CxPending),
+					uint64(params.ShardHealthReporting.CxPending.Warning),
+					uint64(params.ShardHealthReporting.CxPending.ClearMargin),
+					params.Performance.WorkerPoolSize,
+					params.Network.TargetChain,
+				)
+			}
+			if m.enabled(crossLinkCheck) {
+				go m.crossLinkMonitor(
+					uint64(params.InspectSchedule.CrossLink),
+					uint64(params.ShardHealthReporting.CrossLink.Warning),
+					params.Performance.WorkerPoolSize,
+					params.Network.TargetChain,
+					params.ShardHealthReporting.CrossLink.AgeLimit,
+				)
+			}
+			if params.ShardHealthReporting.ClockSkew.Tolerance > 0 && m.enabled(clockSkewCheck) {
+				go m.clockSkewMonitor(
+					uint64(params.InspectSchedule.NodeMetadata),
+					params.ShardHealthReporting.ClockSkew,
+					params.Performance.WorkerPoolSize,
+					params.Network.TargetChain,
+				)
+			}
+			if len(params.BalanceWatch.WatchedAddresses) > 0 {
+				go m.balanceMonitor(
+					uint64(params.BalanceWatch.Interval),
+					params.BalanceWatch.WatchedAddresses,
+					params.Network.TargetChain,
+				)
+			}
 		}
 	}
 }
 
-func (m *monitor) bytesToNodeMetadata(rpc, addr string, payload []byte) {
-	type r struct {
-		Result NodeMetadataReply `json:"result"`
-	}
-	type s struct {
-		Result BlockHeaderReply `json:"result"`
-	}
+// A malformed payload is left out and returns its malformedReplyError
+func (m *monitor) bytesToNodeMetadata(rpc, addr string, payload []byte) error {
 	switch rpc {
 	case NodeMetadataRPC:
-		oneReport := r{}
-		json.Unmarshal(payload, &oneReport)
+		oneReport := NodeMetadataReply{}
+		if err := decodeResult(rpc, payload, &oneReport); err != nil {
+			return err
+		}
 		m.WorkingMetadata.Nodes = append(m.WorkingMetadata.Nodes, NodeMetadata{
-			oneReport.Result,
+			oneReport,
 			addr,
 		})
 	case BlockHeaderRPC:
-		oneReport := s{}
-		json.Unmarshal(payload, &oneReport)
+		oneReport := BlockHeaderReply{}
+		if err := decodeResult(rpc, payload, &oneReport); err != nil {
+			return err
+		}
 		m.WorkingBlockHeader.Nodes = append(m.WorkingBlockHeader.Nodes, BlockHeader{
-			oneReport.Result,
+			oneReport,
 			addr,
 		})
 	}
+	return nil
 }
 
 type networkReport struct {
@@ -690,35 +968,129 @@ func (m *monitor) networkSnapshot() networkReport {
 }
 
 type statusReport struct {
-	Shards       []shardStatus `json:"shard-status"`
-	Versions     []string      `json:"commit-version"`
-	AvailSeats   int           `json:"avail-seats"`
-	ElectedSeats int           `json:"used-seats"`
-	Validators   int           `json:"validators"`
+	Shards          []shardStatus    `json:"shard-status"`
+	Versions        []string         `json:"commit-version"`
+	AvailSeats      int              `json:"avail-seats"`
+	ElectedSeats    int              `json:"used-seats"`
+	Validators      int              `json:"validators"`
+	Balances        []addressBalance `json:"balances"`
+	Health          networkHealth    `json:"network-health"`
+	WorkerPools     []workerPool     `json:"worker-pools"`
+	ChainMismatches []chainMismatch  `json:"chain-mismatches"`
+	EnabledChecks   map[string]bool  `json:"enabled-checks"`
+	// Keyed by block-header and node-metadata
+	Cycles map[string]cycleReport `json:"inspection-cycles"`
+	// Per alert channel, retries included
+	Deliveries []channelDeliveries `json:"alert-deliveries"`
+	// Shards left after include-shards and exclude-shards
+	ActiveShards []int `json:"active-shards"`
 }
 
 type shardStatus struct {
-	ShardID        string `json:"shard-id"`
-	Consensus      bool   `json:"consensus-status"`
-	Block          uint64 `json:"current-block-number"`
-	BlockTimestamp string `json:"block-timestamp"`
-	Epoch          uint64 `json:"current-epoch"`
-	LeaderAddress  string `json:"leader-address"`
+	ShardID        string                `json:"shard-id"`
+	Consensus      bool                  `json:"consensus-status"`
+	Block          uint64                `json:"current-block-number"`
+	BlockTimestamp string                `json:"block-timestamp"`
+	Epoch          uint64                `json:"current-epoch"`
+	LeaderAddress  string                `json:"leader-address"`
+	AvgBlockTime   float64               `json:"avg-block-time"`
+	CrossLinkLag   uint64                `json:"crosslink-lag-blocks"`
+	CrossLinkAge   float64               `json:"crosslink-age-seconds"`
+	Health         healthState           `json:"health"`
+	DegradedChecks []string              `json:"degraded-checks"`
+	Nodes          []string              `json:"nodes"`
+	Polls          map[string]checkPoll  `json:"polls"`
+	Signing        []validatorSigning    `json:"validator-signing"`
+	Views          shardViews            `json:"consensus-views"`
+	ClockSkews     map[string]float64    `json:"clock-skew-seconds"`
+	Checks         map[string]checkState `json:"check-states"`
+	BlockAge       float64               `json:"block-age-seconds"`
+	NodeCount      int                   `json:"node-count"`
 }
 
 func (m *monitor) statusSnapshot() statusReport {
 	cnsProgressCpy := map[string]bool{}
+	blockTimesCpy := map[string]float64{}
 	m.inUse.Lock()
 	sum := summaryMaps(m.MetadataSnapshot.Nodes, m.BlockHeaderSnapshot.Nodes)
 	for key, value := range m.consensusProgress {
 		cnsProgressCpy[key] = value
 	}
+	for key, value := range m.blockTimes {
+		blockTimesCpy[key] = value
+	}
+	blockAgesCpy := map[string]float64{}
+	for key, value := range m.blockAges {
+		blockAgesCpy[key] = value
+	}
+	viewsCpy := map[string]shardViews{}
+	for key, value := range m.views {
+		value.Nodes = append([]nodeView{}, value.Nodes...)
+		viewsCpy[key] = value
+	}
+	clockSkewsCpy := map[string]map[string]float64{}
+	for key, value := range m.clockSkews {
+		clockSkewsCpy[key] = map[string]float64{}
+		for node, skew := range value {
+			clockSkewsCpy[key][node] = skew
+		}
+	}
+	signingCpy := map[string][]validatorSigning{}
+	for key, value := range m.signingRates {
+		signingCpy[key] = append([]validatorSigning{}, value...)
+	}
+	crossLinkAgesCpy := map[string]crossLinkAge{}
+	for key, value := range m.crossLinkAges {
+		crossLinkAgesCpy[strconv.Itoa(key)] = value
+	}
+	balancesCpy := append([]addressBalance{}, m.balances...)
+	enabledCpy := map[string]bool{}
+	for check, enabled := range m.checks {
+		enabledCpy[check] = enabled
+	}
+	nodesCpy := map[string][]string{}
+	for address, shard := range m.nodes {
+		key := strconv.Itoa(shard)
+		nodesCpy[key] = append(nodesCpy[key], m.nodeNameOf(address))
+	}
+	degradedCpy := map[string][]string{}
+	pollsCpy := map[string]map[string]checkPoll{}
+	checksCpy := map[string]map[string]checkState{}
+	now := time.Now()
+	shards := map[string]bool{}
+	for key := range sum[headerSumry] {
+		shards[key] = true
+	}
+	for key := range nodesCpy {
+		shards[key] = true
+	}
+	beaconCpy := m.beaconSnapshot()
+	cyclesCpy := m.cyclesSnapshot()
+	activeCpy := append([]int{}, m.activeShards...)
+	for key := range shards {
+		degradedCpy[key] = m.degradedChecksOf(key)
+		pollsCpy[key] = m.pollsOf(key, now)
+		checksCpy[key] = m.checkStatesOf(key)
+		// A silently stuck check degrades the shard even while green
+		for check, poll := range pollsCpy[key] {
+			if poll.Stale {
+				degradedCpy[key] = append(degradedCpy[key], check+" (stale)")
+			}
+		}
+		sort.Strings(degradedCpy[key])
+	}
 	m.inUse.Unlock()
+	for _, names := range nodesCpy {
+		sort.Strings(names)
+	}
 
 	status := []shardStatus{}
+	states := map[string]healthState{}
 
 	for i, shard := range sum[headerSumry] {
 		sample := shard.(any)["latest-block"].(BlockHeader)
+		consensus, known := cnsProgressCpy[i]
+		states[i] = shardHealth(known, consensus, degradedCpy[i])
 		status = append(status, shardStatus{
 			i,
 			cnsProgressCpy[i],
@@ -726,13 +1098,47 @@ func (m *monitor) statusSnapshot() statusReport {
 			sample.Payload.Timestamp,
 			shard.(any)["epoch-max"].(uint64),
 			sample.Payload.Leader,
+			blockTimesCpy[i],
+			crossLinkAgesCpy[i].LagBlocks,
+			crossLinkAgesCpy[i].AgeSeconds,
+			states[i],
+			degradedCpy[i],
+			nodesCpy[i],
+			pollsCpy[i],
+			signingCpy[i],
+			viewsCpy[i],
+			clockSkewsCpy[i],
+			checksCpy[i],
+			blockAgesCpy[i],
+			len(nodesCpy[i]),
 		})
 	}
 
+	// Shards without any block header reply, kept as degraded rather than
+	// dropped so one failing shard is visible next to the healthy ones
+	for i := range nodesCpy {
+		if _, reported := sum[headerSumry][i]; reported || len(degradedCpy[i]) == 0 {
+			continue
+		}
+		states[i] = healthDegraded
+		status = append(status, shardStatus{
+			ShardID:        i,
+			Health:         states[i],
+			DegradedChecks: degradedCpy[i],
+			Nodes:          nodesCpy[i],
+			Polls:          pollsCpy[i],
+			Checks:         checksCpy[i],
+			NodeCount:      len(nodesCpy[i]),
+		})
+	}
+
+	sort.SliceStable(status, func(i, j int) bool { return shardIDLess(status[i].ShardID, status[j].ShardID) })
+
 	versions := []string{}
 	for k := range sum[metaSumry] {
 		versions = append(versions, k)
 	}
+	sort.Strings(versions)
 
 	addresses := []string{}
 	usedSeats := 0
@@ -751,6 +1157,14 @@ func (m *monitor) statusSnapshot() statusReport {
 		m.SuperCommittee.CurrentCommittee.ExternalCount,
 		usedSeats,
 		linq.From(addresses).Distinct().Count(),
+		balancesCpy,
+		aggregateHealth(states, beaconCpy),
+		m.poolsSnapshot(),
+		m.chainMismatchSnapshot(),
+		enabledCpy,
+		cyclesCpy,
+		m.alerts.deliveriesSnapshot(),
+		activeCpy,
 	}
 }
 
@@ -762,17 +1176,212 @@ func (m *monitor) statusJSON(w http.ResponseWriter, req *http.Request) {
 	json.NewEncoder(w).Encode(m.statusSnapshot())
 }
 
-func (m *monitor) startReportingHTTPServer(instrs *instruction) {
+type versionReport struct {
+	Version     string `json:"version"`
+	Commit      string `json:"commit"`
+	BuiltAt     string `json:"built-at"`
+	BuiltBy     string `json:"built-by"`
+	Build       string `json:"watchdog-build-version"`
+	TargetChain string `json:"target-chain"`
+	StartTime   string `json:"start-time"`
+}
+
+func (m *monitor) versionJSON(w http.ResponseWriter, req *http.Request) {
+	json.NewEncoder(w).Encode(versionReport{
+		version, commit, builtAt, builtBy, buildVersion, m.chain,
+		startTime.UTC().Format(time.RFC3339),
+	})
+}
+
+// RPC calls go through proxy if set and authenticate with the client
+// certificate if set, both are validated by sanityCheck
+func configureRPCClient(httpTimeout seconds, proxy, userAgent, clientCert, clientKey string) {
+	dial := func(addr string) (net.Conn, error) {
+		return fasthttp.DialTimeout(addr, httpTimeout.duration())
+	}
+	if proxy != "" {
+		dial, _ = proxyDialer(proxy)
+	}
+	if userAgent == "" {
+		userAgent = defaultUserAgent()
+	}
+	tlsConfig, _ := clientTLSConfig(clientCert, clientKey)
+	if tlsConfig != nil {
+		rpcScheme = "https://"
+	}
+	rpcTimeout = httpTimeout.duration()
 	client = fasthttp.Client{
-		Dial: func(addr string) (net.Conn, error) {
-			return fasthttp.DialTimeout(addr, time.Second*time.Duration(instrs.Performance.HTTPTimeout))
-		},
+		Name:            userAgent,
+		Dial:            timedDial(dial, proxy == "", httpTimeout.duration()),
 		MaxConnsPerHost: 2048,
+		TLSConfig:       tlsConfig,
+		ReadTimeout:     rpcTimeout,
+		WriteTimeout:    rpcTimeout,
 	}
+}
+
+func (m *monitor) startReportingHTTPServer(instrs *instruction) {
+	configureRPCClient(instrs.Performance.HTTPTimeout, instrs.Network.Proxy, instrs.Performance.UserAgent,
+		instrs.Network.ClientCert, instrs.Network.ClientKey,
+	)
 	go m.update(instrs.watchParams, instrs.superCommittee, []string{BlockHeaderRPC, NodeMetadataRPC})
 	http.HandleFunc("/report-"+instrs.Network.TargetChain, m.renderReport)
 	http.HandleFunc("/report-download-"+instrs.Network.TargetChain, m.produceCSV)
 	http.HandleFunc("/network-"+instrs.Network.TargetChain, m.networkSnapshotJSON)
 	http.HandleFunc("/status-"+instrs.Network.TargetChain, m.statusJSON)
-	http.ListenAndServe(":"+strconv.Itoa(instrs.HTTPReporter.Port), nil)
+	http.HandleFunc("/status-"+instrs.Network.TargetChain+"/", m.shardStatusJSON)
+	http.HandleFunc("/health-"+instrs.Network.TargetChain, m.healthJSON)
+	http.HandleFunc("/health", m.healthJSON)
+	http.HandleFunc("/alerts-"+instrs.Network.TargetChain, m.alertsJSON)
+	http.Handle("/alerts-"+instrs.Network.TargetChain+"/",
+		m.ackAlertJSON(instrs.HTTPReporter.Admin.BasicAuth, instrs.Network.TargetChain),
+	)
+	http.Handle("/maintenance-"+instrs.Network.TargetChain,
+		m.maintenanceHandler(instrs.HTTPReporter.Admin.BasicAuth),
+	)
+	http.Handle("/audit-"+instrs.Network.TargetChain,
+		requireBasicAuth(http.HandlerFunc(m.auditJSON), instrs.HTTPReporter.Admin.BasicAuth),
+	)
+	http.HandleFunc("/version", m.versionJSON)
+	http.HandleFunc("/readyz", m.readyzJSON)
+	http.HandleFunc("/healthz", m.healthzJSON)
+	http.Handle("/config", requireBasicAuth(configJSON(instrs.watchParams), instrs.HTTPReporter.Admin.BasicAuth))
+	http.Handle("/metrics", promhttp.Handler())
+	handler := limitConnections(
+		allowCORS(
+			guardPprof(http.DefaultServeMux, instrs.HTTPReporter.Pprof, instrs.HTTPReporter.Admin.BasicAuth),
+			instrs.HTTPReporter.CORSOrigins,
+		),
+		instrs.HTTPReporter.MaxConnections,
+	)
+	liveness := http.NewServeMux()
+	liveness.HandleFunc("/healthz", m.healthzJSON)
+	livenessHandler := limitConnections(liveness, instrs.HTTPReporter.MaxConnections)
+	if public := instrs.HTTPReporter.Public; public.Address != "" {
+		go serveListener("public", public, livenessHandler)
+	}
+	// With an admin listener the endpoints are served there only, port
+	// answers liveness like public and the socket asks for the admin
+	// basic-auth like the admin listener
+	portHandler, socketHandler := handler, handler
+	if admin := instrs.HTTPReporter.Admin; admin.Address != "" {
+		go serveListener("admin", admin, handler)
+		portHandler, socketHandler = livenessHandler, requireBasicAuth(handler, admin.BasicAuth)
+		if instrs.HTTPReporter.Port != 0 {
+			stdlog.Printf("[startReportingHTTPServer] Serving /healthz only on port %d, the other endpoints on admin",
+				instrs.HTTPReporter.Port,
+			)
+		}
+	}
+	if socket := instrs.HTTPReporter.UnixSocket; socket != "" {
+		l, err := m.listenReportingSocket(socket)
+		if err != nil {
+			errlog.Printf("[startReportingHTTPServer] Could not listen on %s: %v", socket, err)
+		} else {
+			stdlog.Printf("[startReportingHTTPServer] Serving on unix socket %s", socket)
+			go http.Serve(l, socketHandler)
+		}
+	}
+	if instrs.GRPCReporter.Port != 0 {
+		go m.startGRPCServer(instrs.GRPCReporter.Port)
+	}
+	// Port is optional when serving on a unix socket only
+	if instrs.HTTPReporter.Port != 0 {
+		err := http.ListenAndServe(":"+strconv.Itoa(instrs.HTTPReporter.Port), portHandler)
+		errlog.Printf("[startReportingHTTPServer] Listener on port %d: %v", instrs.HTTPReporter.Port, err)
+	}
+}
+
+// Run on shutdown, before the daemon exits
+func (m *monitor) stopReporting() {
+	m.closeReportingSocket()
+	m.stopGRPCServer()
+	m.stopWorkers()
+	m.tracer.shutdown()
+	m.statsd.close()
+	m.influx.shutdown()
+	recording.close()
+}
+
+const defaultMaxConnections = 256
+
+// Importing net/http/pprof registers /debug/pprof/ on the default mux,
+// it is answered with a 404 unless enabled. Enabled it asks for the admin
+// basic-auth credentials when those are set, without them only loopback
+// clients and the unix socket are answered
+func guardPprof(next http.Handler, enabled bool, auth basicAuthParams) http.Handler {
+	profiles := requireBasicAuth(next, auth)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.URL.Path, "/debug/pprof") {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if !enabled {
+			http.NotFound(w, req)
+			return
+		}
+		if auth.Username == "" && !loopbackClient(req) {
+			http.Error(w, "pprof is only served to loopback clients without the admin basic-auth", http.StatusForbidden)
+			return
+		}
+		profiles.ServeHTTP(w, req)
+	})
+}
+
+// Requests on the unix socket have no client address
+func loopbackClient(req *http.Request) bool {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Only requests from a listed origin, or any with *, get CORS headers and
+// their preflight answered, all others are served as before
+func allowCORS(next http.Handler, origins []string) http.Handler {
+	if len(origins) == 0 {
+		return next
+	}
+	allowed := map[string]bool{}
+	for _, o := range origins {
+		allowed[strings.TrimSuffix(o, "/")] = true
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		if origin == "" || !(allowed["*"] || allowed[origin]) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			if headers := req.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+			}
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Requests beyond max in flight get a 503 rather than queueing up
+func limitConnections(next http.Handler, max int) http.Handler {
+	if max <= 0 {
+		max = defaultMaxConnections
+	}
+	inFlight := make(chan struct{}, max)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case inFlight <- struct{}{}:
+			defer func() { <-inFlight }()
+			next.ServeHTTP(w, req)
+		default:
+			http.Error(w, "too many connections", http.StatusServiceUnavailable)
+		}
+	})
 }
@@ -2,14 +2,17 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net"
+	"net/url"
 	"os"
 	"os/signal"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -36,6 +39,9 @@ var (
 	}
 	w               *cobraSrvWrapper = &cobraSrvWrapper{nil}
 	monitorNodeYAML string
+	dryRun          bool
+	logLevel        string
+	logFormat       string
 	stdlog          *log.Logger
 	errlog          *log.Logger
 	// Add services here that we might want to depend on, see all services on
@@ -59,92 +65,208 @@ type Service struct {
 
 func (service *Service) monitorNetwork() error {
 	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
-	// Set up listener for defined host and port
-	listener, err := net.Listen(
-		"tcp",
-		":"+strconv.Itoa(service.instruction.HTTPReporter.Port+1),
-	)
-	if err != nil {
-		return err
-	}
-	// set up channel on which to send accepted connections
-	listen := make(chan net.Conn, 100)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	// All reporting endpoints are served on the configured http-reporter port
 	go service.startReportingHTTPServer(service.instruction)
-	go acceptConnection(listener, listen)
-	// loop work cycle with accept connections or interrupt
-	// by system signal
+	// loop work cycle until interrupted by system signal, SIGHUP reloads
+	// the nodes of node-distribution
 	killSignal := <-interrupt
+	for killSignal == syscall.SIGHUP {
+		go service.reloadCommittees()
+		killSignal = <-interrupt
+	}
 	stdlog.Println("[monitorNetwork] Got signal:", killSignal)
-	stdlog.Println("[monitorNetwork] Stopping listening on ", listener.Addr())
-	listener.Close()
+	service.stopReporting()
+	service.alerts.shutdown()
 	if killSignal == os.Interrupt {
 		return errSysIntrpt
 	}
 	return errDaemonKilled
 }
 
-// Accept a client connection and collect it in a channel
-func acceptConnection(listener net.Listener, listen chan<- net.Conn) {
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			continue
-		}
-		listen <- conn
-	}
-}
-
 type watchParams struct {
 	Auth struct {
 		PagerDuty struct {
 			EventServiceKey string `yaml:"event-service-key"`
 		} `yaml:"pagerduty"`
+		Slack    slackParams    `yaml:"slack"`
+		Telegram telegramParams `yaml:"telegram"`
+		Discord  discordParams  `yaml:"discord"`
+		OpsGenie opsGenieParams `yaml:"opsgenie"`
+		Webhook  webhookParams  `yaml:"webhook"`
+		Email    emailParams    `yaml:"email"`
+		Teams    teamsParams    `yaml:"teams"`
+		Twilio   twilioParams   `yaml:"twilio"`
 	} `yaml:"auth"`
+	Alerting struct {
+		NotifyOnRecovery  bool           `yaml:"notify-on-recovery"`
+		AutoResolve       *bool          `yaml:"auto-resolve,omitempty"`
+		Severity          string         `yaml:"severity"`
+		Templates         templateParams `yaml:"templates"`
+		DedupStrategy     string         `yaml:"dedup-strategy"`
+		StrictStartup     bool           `yaml:"strict-startup"`
+		GroupWindow       seconds        `yaml:"group-window"`
+		RetriggerInterval seconds        `yaml:"retrigger-interval"`
+		PageAfterChecks   int            `yaml:"page-after-checks"`
+		AckPeriod         seconds        `yaml:"ack-period"`
+		DeliveryFailures  int            `yaml:"delivery-failures"`
+		AuditLog          string         `yaml:"audit-log"`
+		Digest            struct {
+			PagerDuty digestParams `yaml:"pagerduty"`
+		} `yaml:"digest"`
+		MinSeverity struct {
+			PagerDuty string `yaml:"pagerduty"`
+			Slack     string `yaml:"slack"`
+			Telegram  string `yaml:"telegram"`
+			Discord   string `yaml:"discord"`
+			OpsGenie  string `yaml:"opsgenie"`
+			Webhook   string `yaml:"webhook"`
+			Email     string `yaml:"email"`
+			Teams     string `yaml:"teams"`
+		} `yaml:"min-severity"`
+		Routing     alertRoutes         `yaml:"routing"`
+		Maintenance []maintenanceWindow `yaml:"maintenance"`
+		Suppress    suppressParams      `yaml:"suppress"`
+		RateLimit   rateLimitParams     `yaml:"rate-limit"`
+	} `yaml:"alerting"`
 	Network struct {
 		TargetChain string `yaml:"target-chain"`
 		RPCPort     int    `yaml:"public-rpc"`
+		Proxy       string `yaml:"proxy"`
+		// PEM files, RPC calls use https with mutual TLS when set
+		ClientCert string `yaml:"client-cert"`
+		ClientKey  string `yaml:"client-key"`
+		// Replaces public-rpc for the nodes of a shard, keyed by shard ID
+		ShardPorts map[int]int `yaml:"shard-rpc-ports"`
+		// Method names keyed by block-header, node-metadata, cx-pending
+		// and cross-link, the Harmony names where not set
+		RPCMethods map[string]string `yaml:"rpc-methods"`
 	} `yaml:"network-config"`
-	// Assumes Seconds
+	// Seconds or Go durations such as 2m, jitter is a percentage of the interval
 	InspectSchedule struct {
-		BlockHeader  int `yaml:"block-header"`
-		NodeMetadata int `yaml:"node-metadata"`
-		CxPending    int `yaml:"cx-pending"`
-		CrossLink    int `yaml:"cross-link"`
+		BlockHeader  seconds `yaml:"block-header"`
+		NodeMetadata seconds `yaml:"node-metadata"`
+		CxPending    seconds `yaml:"cx-pending"`
+		CrossLink    seconds `yaml:"cross-link"`
+		Jitter       int     `yaml:"jitter"`
 	} `yaml:"inspect-schedule"`
 	Performance struct {
-		WorkerPoolSize int `yaml:"num-workers"`
-		HTTPTimeout    int `yaml:"http-timeout"`
+		WorkerPoolSize int     `yaml:"num-workers"`
+		HTTPTimeout    seconds `yaml:"http-timeout"`
+		UserAgent      string  `yaml:"user-agent"`
+		MaxShards      int     `yaml:"max-concurrent-shards"`
 	} `yaml:"performance"`
 	HTTPReporter struct {
-		Port int `yaml:"port"`
+		Port           int    `yaml:"port"`
+		MaxConnections int    `yaml:"max-connections"`
+		UnixSocket     string `yaml:"unix-socket"`
+		// Origins allowed to fetch the endpoints from a browser, * for any
+		CORSOrigins []string `yaml:"cors-allowed-origins"`
+		// Serves the net/http/pprof profiles under /debug/pprof/
+		Pprof bool `yaml:"pprof"`
+		// Optional listeners next to port, public serves /healthz only
+		// and admin the endpoints of port, which then serves /healthz only
+		Public listenerParams `yaml:"public"`
+		Admin  listenerParams `yaml:"admin"`
 	} `yaml:"http-reporter"`
+	// Zero disables the gRPC server
+	GRPCReporter struct {
+		Port int `yaml:"port"`
+	} `yaml:"grpc-reporter"`
+	// Optional, spans of the inspection cycles and their RPC calls
+	Tracing struct {
+		OTLP otlpParams `yaml:"otlp"`
+	} `yaml:"tracing"`
+	// Optional, StatsD or DogStatsD agent the shard heights, consensus,
+	// reachable nodes and cycle durations are sent to
+	StatsD statsdParams `yaml:"statsd"`
+	// Optional, InfluxDB every check result and node reply is written to
+	InfluxDB influxParams `yaml:"influxdb"`
+	// Level and format of the daemon's own log lines
+	Logging              logParams `yaml:"logging"`
 	ShardHealthReporting struct {
 		Consensus struct {
-			Interval int `yaml:"interval"`
-			Warning  int `yaml:"warning"`
+			checkToggle `yaml:",inline"`
+			Interval    seconds `yaml:"interval"`
+			Warning     int     `yaml:"warning"`
+			Critical    int     `yaml:"critical"`
 		} `yaml:"consensus"`
+		// clear-margin is how far back past its threshold a check must
+		// be before a firing alert clears, zero clears right away
 		CxPending struct {
-			Warning int `yaml:"pending-limit"`
+			checkToggle `yaml:",inline"`
+			Warning     int `yaml:"pending-limit"`
+			Critical    int `yaml:"critical"`
+			ClearMargin int `yaml:"clear-margin"`
 		} `yaml:"cx-pending"`
 		CrossLink struct {
-			Warning int `yaml:"warning"`
+			checkToggle `yaml:",inline"`
+			Warning     int               `yaml:"warning"`
+			Critical    int               `yaml:"critical"`
+			AgeLimit    crossLinkAgeLimit `yaml:"age-limit"`
 		} `yaml:"cross-link"`
 		ShardHeight struct {
-			Warning int `yaml:"tolerance"`
+			checkToggle `yaml:",inline"`
+			Warning     int `yaml:"tolerance"`
+			// Blocks behind the shard a node alerts as critical instead
+			Critical    int `yaml:"critical"`
+			ClearMargin int `yaml:"clear-margin"`
+			// Percentages, quantile of 50 compares to the median height
+			Quorum   int `yaml:"quorum"`
+			Quantile int `yaml:"quantile"`
+			// Nodes off that alert on the shard, a count or a committee fraction
+			NodesOff nodeLimit `yaml:"nodes-off"`
+			// Quorum of the internal nodes of the shard on their own
+			Internal struct {
+				Quorum int `yaml:"quorum"`
+			} `yaml:"internal"`
 		} `yaml:"shard-height"`
-		Connectivity  struct {
-			Warning int `yaml:"tolerance"`
+		Connectivity struct {
+			checkToggle `yaml:",inline"`
+			Warning     int `yaml:"tolerance"`
+			ClearMargin int `yaml:"clear-margin"`
+			// Nodes below tolerance that alert, a count or a committee fraction
+			NodesOff nodeLimit `yaml:"nodes-off"`
+			// Tolerance of the internal nodes of the shard on their own
+			Internal struct {
+				Tolerance int `yaml:"tolerance"`
+			} `yaml:"internal"`
 		} `yaml:"connectivity"`
+		BlockTime  blockTimeParams  `yaml:"block-time"`
+		BlockAge   blockAgeParams   `yaml:"block-age"`
+		Signing    signingParams    `yaml:"signing"`
+		ViewSpread viewSpreadParams `yaml:"view-spread"`
+		ClockSkew  clockSkewParams  `yaml:"clock-skew"`
+		WarmUp     warmUpParams     `yaml:"warm-up"`
+		Beacon     beaconParams     `yaml:"beacon"`
+		Regression regressionParams `yaml:"height-regression"`
+		Metadata   metadataParams   `yaml:"metadata-changes"`
+		Escalation escalationParams `yaml:"escalation"`
 	} `yaml:"shard-health-reporting"`
+	BalanceWatch struct {
+		Interval         seconds          `yaml:"interval"`
+		WatchedAddresses []watchedAddress `yaml:"watched-addresses"`
+	} `yaml:"balance-watch"`
 	DistributionFiles struct {
-		MachineIPList []string `yaml:"machine-ip-list"`
+		MachineIPList []string           `yaml:"machine-ip-list"`
+		RPCDiscovery  rpcDiscoveryParams `yaml:"rpc-discovery"`
+		LoadBalanced  map[int]string     `yaml:"load-balanced"`
+		LoadRetry     loadRetryParams    `yaml:"load-retry"`
+		Selection     shardSelection     `yaml:",inline"`
+		// Percent a reload may change the node count of a shard by
+		CountChange int `yaml:"count-change"`
 	} `yaml:"node-distribution"`
 }
 
 type committee struct {
 	file    string
 	members []string
+	// Optional member address to label, e.g. validator-seoul-1
+	labels map[string]string
+	// Member address to node group of the tagged members
+	groups map[string]string
+	// The only member is a load balancer in front of the shard
+	loadBalanced bool
 }
 
 type instruction struct {
@@ -152,6 +274,36 @@ type instruction struct {
 	superCommittee map[int]committee
 }
 
+// Expands a leading ~ to the home directory and makes the path absolute
+func expandPath(p string) (string, error) {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("could not expand %s: %v", p, err)
+		}
+		p = filepath.Join(home, strings.TrimPrefix(p, "~"))
+	}
+	return filepath.Abs(p)
+}
+
+func resolveConfigPath(yamlPath string) (string, error) {
+	if yamlPath == "" {
+		return "", fmt.Errorf("missing --%s", mFlag)
+	}
+	resolved, err := expandPath(yamlPath)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("config %s (resolved to %s) does not exist", yamlPath, resolved)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("config %s (resolved to %s) is not a regular file", yamlPath, resolved)
+	}
+	return resolved, nil
+}
+
 func newInstructions(yamlPath string) (*instruction, error) {
 	rawYAML, err := ioutil.ReadFile(yamlPath)
 	if err != nil {
@@ -162,37 +314,170 @@ func newInstructions(yamlPath string) (*instruction, error) {
 	if err != nil {
 		return nil, err
 	}
+	for i, f := range t.DistributionFiles.MachineIPList {
+		if resolved, err := expandPath(f); err == nil {
+			t.DistributionFiles.MachineIPList[i] = resolved
+		}
+	}
+	for _, f := range []*string{
+		&t.Network.ClientCert, &t.Network.ClientKey,
+		&t.HTTPReporter.Public.TLSCert, &t.HTTPReporter.Public.TLSKey,
+		&t.HTTPReporter.Admin.TLSCert, &t.HTTPReporter.Admin.TLSKey,
+		&t.Alerting.AuditLog, &t.Logging.File,
+	} {
+		if resolved, err := expandPath(*f); *f != "" && err == nil {
+			*f = resolved
+		}
+	}
 	oops := t.sanityCheck()
 	if oops != nil {
 		return nil, oops
 	}
+	rpcMethods = resolveRPCMethods(t.Network.RPCMethods)
+	if t.DistributionFiles.RPCDiscovery.Enabled {
+		configureRPCClient(t.Performance.HTTPTimeout, t.Network.Proxy, t.Performance.UserAgent,
+			t.Network.ClientCert, t.Network.ClientKey,
+		)
+	}
+	byShard, err := loadCommittees(t)
+	if err != nil {
+		return nil, err
+	}
+	return &instruction{t, byShard}, nil
+}
+
+// The committees of node-distribution, on startup and on each SIGHUP
+func loadCommittees(t watchParams) (map[int]committee, error) {
+	var byShard map[int]committee
+	var err error
+	if t.DistributionFiles.RPCDiscovery.Enabled {
+		err = t.DistributionFiles.LoadRetry.retry(discoveredCommittee, func() (err error) {
+			byShard, err = discoverCommittees(t.DistributionFiles.RPCDiscovery, t.Network.RPCPort)
+			return err
+		})
+	} else {
+		byShard, err = committeesFromFiles(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := addLoadBalanced(byShard, t.DistributionFiles.LoadBalanced, t); err != nil {
+		return nil, err
+	}
+	if unknown := t.DistributionFiles.Selection.apply(byShard); len(unknown) > 0 {
+		return nil, unknownShardsError(unknown)
+	}
+	if len(byShard) == 0 {
+		return nil, errors.New("No shard could be loaded from node-distribution")
+	}
+	if err := checkDuplicates(byShard); err != nil {
+		return nil, err
+	}
+	return byShard, nil
+}
+
+// A port on the line, as in 1.2.3.4:9501, wins over the default port
+func nodeAddress(entry string, port int) (string, error) {
+	host, p, err := net.SplitHostPort(entry)
+	if err != nil {
+		return net.JoinHostPort(entry, strconv.Itoa(port)), nil
+	}
+	if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+		return "", fmt.Errorf("invalid port %q in %s", p, entry)
+	}
+	return net.JoinHostPort(host, p), nil
+}
+
+// NOTE: The trailing number of each file basename is its shardID
+func committeesFromFiles(t watchParams) (map[int]committee, error) {
 	byShard := make(map[int]committee, len(t.DistributionFiles.MachineIPList))
+	dups := []string{}
 	for _, file := range t.DistributionFiles.MachineIPList {
 		shard := path.Base(strings.TrimSuffix(file, path.Ext(file)))
 		id, err := strconv.Atoi(string(shard[len(shard)-1]))
 		if err != nil {
 			return nil, err
 		}
+		port := t.Network.RPCPort
+		if p, exists := t.Network.ShardPorts[id]; exists {
+			port = p
+		}
 		ipList := []string{}
-		f, err := os.Open(file)
+		labels := make(map[string]string)
+		groups := make(map[string]string)
+		// Only reading is retried, what is read is parsed once
+		var content []byte
+		err = t.DistributionFiles.LoadRetry.retry(file, func() (err error) {
+			content, err = ioutil.ReadFile(file)
+			return err
+		})
 		if err != nil {
-			return nil, nil
+			if t.Alerting.StrictStartup {
+				return nil, err
+			}
+			errlog.Printf("[committeesFromFiles] Starting without shard %d, Error: %v", id, err)
+			continue
 		}
-		defer f.Close()
-		scanner := bufio.NewScanner(f)
-		for scanner.Scan() {
-			ipList = append(ipList, scanner.Text()+":"+strconv.Itoa(t.Network.RPCPort))
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		// Address to the line it was first seen on within this file
+		seen := make(map[string]int)
+		for line := 1; scanner.Scan(); line++ {
+			// Blank lines and # comments are skipped, words between
+			// the IP and a trailing # comment are the node's label
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+				continue
+			}
+			ip := fields[0]
+			address, err := nodeAddress(ip, port)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", file, line, err)
+			}
+			if first, check := seen[address]; check {
+				dups = append(dups, fmt.Sprintf("%s:%d: %s (first seen on line %d)",
+					file, line, ip, first,
+				))
+				continue
+			}
+			seen[address] = line
+			label := []string{}
+			for _, word := range fields[1:] {
+				if strings.HasPrefix(word, "#") {
+					break
+				}
+				if strings.HasPrefix(word, groupPrefix) {
+					group, err := parseGroup(word)
+					if err != nil {
+						return nil, fmt.Errorf("%s:%d: %v", file, line, err)
+					}
+					groups[address] = group
+					continue
+				}
+				label = append(label, word)
+			}
+			if len(label) > 0 {
+				labels[address] = strings.Join(label, " ")
+			}
+			ipList = append(ipList, address)
 		}
 		err = scanner.Err()
 		if err != nil {
 			return nil, err
 		}
-		byShard[id] = committee{file, ipList}
+		byShard[id] = committee{file, ipList, labels, groups, false}
+	}
+	if len(dups) > 0 {
+		return nil, errors.New("Duplicate IPs detected within distribution files.\n" +
+			strings.Join(dups, "\n"))
 	}
+	return byShard, nil
+}
+
+func checkDuplicates(byShard map[int]committee) error {
 	dups := []string{}
 	nodeList := make(map[string]string)
-	for i, s := range byShard {
-		for _, m := range s.members {
+	for _, i := range sortedShards(byShard) {
+		for _, m := range byShard[i].members {
 			if _, check := nodeList[m]; check {
 				dups = append(dups, strconv.FormatInt(int64(i), 10)+": "+m)
 				dups = append(dups, nodeList[m]+": "+m)
@@ -202,12 +487,12 @@ func newInstructions(yamlPath string) (*instruction, error) {
 		}
 	}
 	if len(nodeList) == 0 {
-		return nil, errors.New("empty node list")
+		return errors.New("empty node list")
 	}
 	if len(dups) > 0 {
-		return nil, errors.New("Duplicate IPs detected.\n" + strings.Join(dups, "\n"))
+		return errors.New("Duplicate IPs detected.\n" + strings.Join(dups, "\n"))
 	}
-	return &instruction{t, byShard}, nil
+	return nil
 }
 
 func (w *watchParams) sanityCheck() error {
@@ -215,29 +500,162 @@ func (w *watchParams) sanityCheck() error {
 	if w.Network.RPCPort == 0 {
 		errList = append(errList, "Missing public-rpc under network-config in yaml config")
 	}
+	if w.Network.Proxy != "" {
+		if _, err := parseProxy(w.Network.Proxy); err != nil {
+			errList = append(errList, fmt.Sprintf("Invalid proxy under network-config in yaml config: %v", err))
+		}
+	}
+	for shard, p := range w.Network.ShardPorts {
+		if p < 1 || p > 65535 {
+			errList = append(errList, fmt.Sprintf("Invalid port %d for shard %d under network-config, shard-rpc-ports in yaml config", p, shard))
+		}
+	}
+	if unknown := unknownRPCMethodChecks(w.Network.RPCMethods); len(unknown) > 0 {
+		errList = append(errList, fmt.Sprintf(
+			"Unknown checks %s under network-config, rpc-methods in yaml config",
+			strings.Join(unknown, ", "),
+		))
+	}
+	if empty := emptyRPCMethodChecks(w.Network.RPCMethods); len(empty) > 0 {
+		errList = append(errList, fmt.Sprintf(
+			"Empty method names for %s under network-config, rpc-methods in yaml config",
+			strings.Join(empty, ", "),
+		))
+	}
+	if _, err := clientTLSConfig(w.Network.ClientCert, w.Network.ClientKey); err != nil {
+		errList = append(errList, fmt.Sprintf("Invalid client-cert or client-key under network-config in yaml config: %v", err))
+	}
 	if w.InspectSchedule.BlockHeader == 0 {
 		errList = append(errList, "Missing block-header under inspect-schedule in yaml config")
 	}
+	if w.InspectSchedule.BlockHeader < 0 {
+		errList = append(errList, "Negative block-header under inspect-schedule in yaml config")
+	}
 	if w.InspectSchedule.NodeMetadata == 0 {
 		errList = append(errList, "Missing node-metadata under inspect-schedule in yaml config")
 	}
+	if w.InspectSchedule.NodeMetadata < 0 {
+		errList = append(errList, "Negative node-metadata under inspect-schedule in yaml config")
+	}
 	if w.InspectSchedule.CxPending == 0 {
 		errList = append(errList, "Missing cx-pending under inspect-schedule in yaml config")
 	}
+	if w.InspectSchedule.CxPending < 0 {
+		errList = append(errList, "Negative cx-pending under inspect-schedule in yaml config")
+	}
 	if w.InspectSchedule.CrossLink == 0 {
 		errList = append(errList, "Missing cross-link under inspect-schedule in yaml config")
 	}
+	if w.InspectSchedule.CrossLink < 0 {
+		errList = append(errList, "Negative cross-link under inspect-schedule in yaml config")
+	}
+	if w.InspectSchedule.Jitter < 0 || w.InspectSchedule.Jitter > maxJitter {
+		errList = append(errList, fmt.Sprintf(
+			"Invalid jitter %d under inspect-schedule in yaml config, must be between 0 and %d",
+			w.InspectSchedule.Jitter, maxJitter,
+		))
+	}
+	for _, p := range []struct {
+		name    string
+		percent int
+	}{
+		{"quorum", w.ShardHealthReporting.ShardHeight.Quorum},
+		{"quantile", w.ShardHealthReporting.ShardHeight.Quantile},
+		{"internal, quorum", w.ShardHealthReporting.ShardHeight.Internal.Quorum},
+	} {
+		if p.percent < 0 || p.percent > 100 {
+			errList = append(errList, fmt.Sprintf(
+				"Invalid %s %d under shard-health-reporting, shard-height in yaml config, must be between 0 and 100",
+				p.name, p.percent,
+			))
+		}
+	}
 	if w.Performance.WorkerPoolSize == 0 {
 		errList = append(errList, "Missing num-workers under performance in yaml config")
 	}
+	if w.Performance.MaxShards < 0 {
+		errList = append(errList, "Negative max-concurrent-shards under performance in yaml config")
+	}
 	if w.Performance.HTTPTimeout == 0 {
 		errList = append(errList, "Missing http-timeout under performance in yaml config")
 	}
-	if w.HTTPReporter.Port == 0 {
-		errList = append(errList, "Missing port under http-reporter in yaml config")
+	if w.Performance.HTTPTimeout < 0 {
+		errList = append(errList, "Negative http-timeout under performance in yaml config")
+	}
+	if endpoint := w.Tracing.OTLP.Endpoint; endpoint != "" {
+		if err := validOTLPEndpoint(endpoint); err != nil {
+			errList = append(errList, fmt.Sprintf("Invalid endpoint under tracing, otlp in yaml config: %v", err))
+		}
+	}
+	errList = append(errList, w.StatsD.check()...)
+	errList = append(errList, w.InfluxDB.check()...)
+	errList = append(errList, w.Logging.check()...)
+	if w.HTTPReporter.Port == 0 && w.HTTPReporter.UnixSocket == "" && w.HTTPReporter.Admin.Address == "" {
+		errList = append(errList, "Missing port, unix-socket or admin listener under http-reporter in yaml config")
+	} else if w.HTTPReporter.Port < 0 || w.HTTPReporter.Port > 65535 {
+		errList = append(errList, fmt.Sprintf("Invalid port %d under http-reporter in yaml config", w.HTTPReporter.Port))
+	}
+	if w.HTTPReporter.UnixSocket != "" {
+		if err := checkSocketPath(w.HTTPReporter.UnixSocket); err != nil {
+			errList = append(errList, fmt.Sprintf("Invalid unix-socket under http-reporter in yaml config, %v", err))
+		}
+	}
+	for _, o := range w.HTTPReporter.CORSOrigins {
+		if u, err := url.Parse(strings.TrimSuffix(o, "/")); o != "*" &&
+			(err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "") {
+			errList = append(errList, fmt.Sprintf("Invalid origin %s under http-reporter, cors-allowed-origins in yaml config", o))
+		}
+	}
+	if err := w.HTTPReporter.Public.check(); err != nil {
+		errList = append(errList, fmt.Sprintf("Invalid public under http-reporter in yaml config: %v", err))
+	}
+	if err := w.HTTPReporter.Admin.check(); err != nil {
+		errList = append(errList, fmt.Sprintf("Invalid admin under http-reporter in yaml config: %v", err))
+	}
+	if w.HTTPReporter.MaxConnections < 0 {
+		errList = append(errList, "Negative max-connections under http-reporter in yaml config")
+	}
+	if p := w.GRPCReporter.Port; p < 0 || p > 65535 {
+		errList = append(errList, fmt.Sprintf("Invalid port %d under grpc-reporter in yaml config", p))
+	} else if p != 0 && (p == w.HTTPReporter.Port || p == w.Network.RPCPort) {
+		errList = append(errList, fmt.Sprintf(
+			"Port %d under grpc-reporter collides with http-reporter or public-rpc in yaml config", p,
+		))
+	}
+	if w.HTTPReporter.Port != 0 && w.HTTPReporter.Port == w.Network.RPCPort {
+		errList = append(errList, fmt.Sprintf(
+			"Port %d under http-reporter collides with public-rpc under network-config in yaml config",
+			w.HTTPReporter.Port,
+		))
+	}
+	public, admin := w.HTTPReporter.Public, w.HTTPReporter.Admin
+	for _, l := range []struct {
+		name string
+		listenerParams
+	}{{"public", public}, {"admin", admin}} {
+		if l.sharesPort(w.HTTPReporter.Port, "") {
+			errList = append(errList, fmt.Sprintf(
+				"Address %s under http-reporter, %s collides with port under http-reporter in yaml config", l.Address, l.name,
+			))
+		}
+		if l.sharesPort(w.GRPCReporter.Port, "") {
+			errList = append(errList, fmt.Sprintf(
+				"Address %s under http-reporter, %s collides with port under grpc-reporter in yaml config", l.Address, l.name,
+			))
+		}
+	}
+	if host, p, err := net.SplitHostPort(admin.Address); err == nil {
+		if n, _ := strconv.Atoi(p); public.sharesPort(n, host) {
+			errList = append(errList, fmt.Sprintf(
+				"Address %s under http-reporter, public collides with admin %s in yaml config", public.Address, admin.Address,
+			))
+		}
 	}
 	if w.ShardHealthReporting.Consensus.Interval == 0 {
-		errList = append(errList, "Missing warning under shard-health-reporting, interval in yaml config")
+		errList = append(errList, "Missing interval under shard-health-reporting, consensus in yaml config")
+	}
+	if w.ShardHealthReporting.Consensus.Interval < 0 {
+		errList = append(errList, "Negative interval under shard-health-reporting, consensus in yaml config")
 	}
 	if w.ShardHealthReporting.Consensus.Warning == 0 {
 		errList = append(errList, "Missing warning under shard-health-reporting, consensus in yaml config")
@@ -254,10 +672,285 @@ func (w *watchParams) sanityCheck() error {
 	if w.ShardHealthReporting.Connectivity.Warning == 0 {
 		errList = append(errList, "Missing tolerance under shard-health-reporting, connectivity in yaml config")
 	}
+	if w.ShardHealthReporting.CrossLink.AgeLimit.Blocks < 0 {
+		errList = append(errList, "Negative blocks under shard-health-reporting, cross-link, age-limit in yaml config")
+	}
+	if w.ShardHealthReporting.CrossLink.AgeLimit.Seconds < 0 {
+		errList = append(errList, "Negative seconds under shard-health-reporting, cross-link, age-limit in yaml config")
+	}
+	if w.ShardHealthReporting.BlockTime.Target < 0 {
+		errList = append(errList, "Negative target under shard-health-reporting, block-time in yaml config")
+	}
+	if w.ShardHealthReporting.BlockTime.Tolerance < 0 {
+		errList = append(errList, "Negative tolerance-percent under shard-health-reporting, block-time in yaml config")
+	}
+	if w.ShardHealthReporting.BlockTime.Window < 0 {
+		errList = append(errList, "Negative window under shard-health-reporting, block-time in yaml config")
+	}
+	margins := []struct {
+		key    string
+		margin int
+	}{
+		{"cx-pending, clear-margin", w.ShardHealthReporting.CxPending.ClearMargin},
+		{"shard-height, clear-margin", w.ShardHealthReporting.ShardHeight.ClearMargin},
+		{"connectivity, clear-margin", w.ShardHealthReporting.Connectivity.ClearMargin},
+		{"connectivity, internal, tolerance", w.ShardHealthReporting.Connectivity.Internal.Tolerance},
+		{"connectivity, nodes-off", w.ShardHealthReporting.Connectivity.NodesOff.Count},
+		{"shard-height, nodes-off", w.ShardHealthReporting.ShardHeight.NodesOff.Count},
+		{"block-time, clear-margin-percent", w.ShardHealthReporting.BlockTime.ClearMargin},
+		{"signing, window", w.ShardHealthReporting.Signing.Window},
+		{"view-spread, tolerance", w.ShardHealthReporting.ViewSpread.Tolerance},
+		{"clock-skew, tolerance", int(w.ShardHealthReporting.ClockSkew.Tolerance)},
+		{"block-age, max-age", int(w.ShardHealthReporting.BlockAge.MaxAge)},
+		{"block-age, clear-margin", int(w.ShardHealthReporting.BlockAge.ClearMargin)},
+		{"warm-up, samples", w.ShardHealthReporting.WarmUp.Samples},
+		{"beacon, shard", w.ShardHealthReporting.Beacon.Shard},
+		{"beacon, stall", int(w.ShardHealthReporting.Beacon.Stall)},
+		{"beacon, lag-tolerance", w.ShardHealthReporting.Beacon.LagTolerance},
+		{"height-regression, tolerance", w.ShardHealthReporting.Regression.Tolerance},
+		{"signing, clear-margin-percent", w.ShardHealthReporting.Signing.ClearMargin},
+		{"cross-link, age-limit, clear-margin, blocks", w.ShardHealthReporting.CrossLink.AgeLimit.ClearMargin.Blocks},
+		{"cross-link, age-limit, clear-margin, seconds", int(w.ShardHealthReporting.CrossLink.AgeLimit.ClearMargin.Seconds)},
+	}
+	for _, m := range margins {
+		if m.margin < 0 {
+			errList = append(errList, fmt.Sprintf("Negative %s under shard-health-reporting in yaml config", m.key))
+		}
+	}
+	if unknown := unknownMetadataFields(w.ShardHealthReporting.Metadata.Fields); len(unknown) > 0 {
+		errList = append(errList, fmt.Sprintf("Unknown fields %s under shard-health-reporting, metadata-changes in yaml config",
+			strings.Join(unknown, ", "),
+		))
+	}
+	if _, ok := severityRank[w.ShardHealthReporting.Beacon.Severity]; w.ShardHealthReporting.Beacon.Severity != "" && !ok {
+		errList = append(errList, fmt.Sprintf(
+			"Unknown severity %s under shard-health-reporting, beacon in yaml config",
+			w.ShardHealthReporting.Beacon.Severity,
+		))
+	}
+	if _, ok := severityRank[w.Alerting.Severity]; w.Alerting.Severity != "" && !ok {
+		errList = append(errList, fmt.Sprintf("Unknown severity %s under alerting in yaml config", w.Alerting.Severity))
+	}
+	for channel, s := range map[string]string{
+		"pagerduty": w.Alerting.MinSeverity.PagerDuty,
+		"slack":     w.Alerting.MinSeverity.Slack,
+		"telegram":  w.Alerting.MinSeverity.Telegram,
+		"discord":   w.Alerting.MinSeverity.Discord,
+		"opsgenie":  w.Alerting.MinSeverity.OpsGenie,
+		"webhook":   w.Alerting.MinSeverity.Webhook,
+		"email":     w.Alerting.MinSeverity.Email,
+		"teams":     w.Alerting.MinSeverity.Teams,
+	} {
+		if _, ok := severityRank[s]; s != "" && !ok {
+			errList = append(errList, fmt.Sprintf(
+				"Unknown severity %s under alerting, min-severity, %s in yaml config", s, channel,
+			))
+		}
+	}
+	if s := w.Alerting.DedupStrategy; s != "" && !dedupStrategies[s] {
+		errList = append(errList, fmt.Sprintf("Unknown dedup-strategy %s under alerting in yaml config, use %s, %s or %s",
+			s, dedupPerCheck, dedupPerShard, dedupPerNetwork,
+		))
+	}
+	if _, err := parseTemplates("pagerduty", w.Alerting.Templates.PagerDuty); err != nil {
+		errList = append(errList, fmt.Sprintf("%v under alerting, templates in yaml config", err))
+	}
+	for i, u := range w.Auth.Webhook.URLs {
+		if err := u.check(); err != nil {
+			errList = append(errList, fmt.Sprintf("Invalid entry %d under auth, webhook, urls in yaml config: %v", i, err))
+		}
+	}
+	for channel, t := range map[string]messageTemplate{
+		"slack":    w.Alerting.Templates.Slack,
+		"telegram": w.Alerting.Templates.Telegram,
+		"discord":  w.Alerting.Templates.Discord,
+		"opsgenie": w.Alerting.Templates.OpsGenie,
+		"webhook":  w.Alerting.Templates.Webhook,
+		"email":    w.Alerting.Templates.Email,
+		"teams":    w.Alerting.Templates.Teams,
+		"twilio":   w.Alerting.Templates.Twilio,
+	} {
+		if _, err := parseTemplates(channel, t); err != nil {
+			errList = append(errList, fmt.Sprintf("%v under alerting, templates in yaml config", err))
+		}
+	}
+	if w.Auth.Slack.WebhookURL != "" {
+		if err := validChatURL(w.Auth.Slack.WebhookURL); err != nil {
+			errList = append(errList, fmt.Sprintf("Invalid webhook-url under auth, slack in yaml config: %v", err))
+		}
+	} else if len(w.Auth.Slack.CheckWebhooks) > 0 || w.Alerting.Routing.routesSlack() {
+		errList = append(errList, "Missing webhook-url under auth, slack in yaml config")
+	}
+	errList = append(errList, w.Alerting.Routing.check()...)
+	if t := w.Auth.Telegram; t.BotToken != "" || t.ChatID != "" {
+		if t.BotToken == "" {
+			errList = append(errList, "Missing bot-token under auth, telegram in yaml config")
+		}
+		if t.ChatID == "" {
+			errList = append(errList, "Missing chat-id under auth, telegram in yaml config")
+		}
+	}
+	if api := w.Auth.Telegram.APIURL; api != "" {
+		if err := validChatURL(api); err != nil {
+			errList = append(errList, fmt.Sprintf("Invalid api-url under auth, telegram in yaml config: %v", err))
+		}
+	}
+	if w.Auth.Teams.WebhookURL != "" {
+		if err := validChatURL(w.Auth.Teams.WebhookURL); err != nil {
+			errList = append(errList, fmt.Sprintf("Invalid webhook-url under auth, teams in yaml config: %v", err))
+		}
+	}
+	if w.Auth.Discord.WebhookURL != "" {
+		if err := validChatURL(w.Auth.Discord.WebhookURL); err != nil {
+			errList = append(errList, fmt.Sprintf("Invalid webhook-url under auth, discord in yaml config: %v", err))
+		}
+	}
+	for check, webhook := range w.Auth.Slack.CheckWebhooks {
+		if !alertChecks[check] {
+			errList = append(errList, fmt.Sprintf("Unknown check %s under auth, slack, check-webhooks in yaml config", check))
+		} else if err := validChatURL(webhook); err != nil {
+			errList = append(errList, fmt.Sprintf("Invalid webhook for %s under auth, slack, check-webhooks in yaml config: %v", check, err))
+		}
+	}
+	if o := w.Auth.OpsGenie; o.APIKey == "" && (o.Team != "" || len(o.CheckTeams) > 0) {
+		errList = append(errList, "Missing api-key under auth, opsgenie in yaml config")
+	}
+	if api := w.Auth.OpsGenie.APIURL; api != "" {
+		if err := validChatURL(api); err != nil {
+			errList = append(errList, fmt.Sprintf("Invalid api-url under auth, opsgenie in yaml config: %v", err))
+		}
+	}
+	for check := range w.Auth.OpsGenie.CheckTeams {
+		if !alertChecks[check] {
+			errList = append(errList, fmt.Sprintf("Unknown check %s under auth, opsgenie, check-teams in yaml config", check))
+		}
+	}
+	if e := w.Auth.Email; e.Host != "" {
+		if e.From == "" {
+			errList = append(errList, "Missing from under auth, email in yaml config")
+		}
+		if len(e.To) == 0 {
+			errList = append(errList, "Missing to under auth, email in yaml config")
+		}
+		if e.TLS != "" && !emailTLSModes[e.TLS] {
+			errList = append(errList, fmt.Sprintf("Unknown tls %s under auth, email in yaml config, use %s, %s or %s",
+				e.TLS, emailStartTLS, emailTLS, emailNoTLS,
+			))
+		}
+		if e.Port < 0 || e.Port > 65535 {
+			errList = append(errList, fmt.Sprintf("Invalid port %d under auth, email in yaml config", e.Port))
+		}
+	} else if len(w.Auth.Email.To) > 0 {
+		errList = append(errList, "Missing host under auth, email in yaml config")
+	}
+	if t := w.Auth.Twilio; t.AccountSID != "" || t.AuthToken != "" || t.From != "" || len(t.To) > 0 {
+		for field, value := range map[string]string{
+			"account-sid": t.AccountSID, "auth-token": t.AuthToken, "from": t.From,
+		} {
+			if value == "" {
+				errList = append(errList, fmt.Sprintf("Missing %s under auth, twilio in yaml config", field))
+			}
+		}
+		if len(t.To) == 0 {
+			errList = append(errList, "Missing to under auth, twilio in yaml config")
+		}
+		if t.From != "" && !strings.HasPrefix(t.From, "MG") && !phoneNumberPattern.MatchString(t.From) {
+			errList = append(errList, fmt.Sprintf("Invalid from %s under auth, twilio in yaml config, use a number such as +14155550100", t.From))
+		}
+		for _, to := range t.To {
+			if !phoneNumberPattern.MatchString(to) {
+				errList = append(errList, fmt.Sprintf("Invalid number %s under auth, twilio, to in yaml config, use a number such as +14155550100", to))
+			}
+		}
+	}
+	h := w.ShardHealthReporting
+	errList = append(errList, checkTier("warning", "consensus", h.Consensus.Warning, h.Consensus.Critical)...)
+	errList = append(errList, checkTier("tolerance", "shard-height", h.ShardHeight.Warning, h.ShardHeight.Critical)...)
+	errList = append(errList, checkTier("pending-limit", "cx-pending", h.CxPending.Warning, h.CxPending.Critical)...)
+	errList = append(errList, checkTier("warning", "cross-link", h.CrossLink.Warning, h.CrossLink.Critical)...)
+	errList = append(errList, checkTier("max-age", "block-age", int(h.BlockAge.MaxAge), int(h.BlockAge.Critical))...)
+	if w.Alerting.DeliveryFailures < 0 {
+		errList = append(errList, "Negative delivery-failures under alerting in yaml config")
+	}
+	if w.Alerting.GroupWindow < 0 {
+		errList = append(errList, "Negative group-window under alerting in yaml config")
+	}
+	if w.Alerting.RetriggerInterval < 0 {
+		errList = append(errList, "Negative retrigger-interval under alerting in yaml config")
+	}
+	if w.Alerting.PageAfterChecks < 0 {
+		errList = append(errList, "Negative page-after-checks under alerting in yaml config")
+	}
+	if w.Alerting.AckPeriod < 0 {
+		errList = append(errList, "Negative ack-period under alerting in yaml config")
+	}
+	if w.Alerting.RateLimit.PerMinute < 0 {
+		errList = append(errList, "Negative per-minute under alerting, rate-limit in yaml config")
+	}
+	for check, limit := range w.Alerting.RateLimit.Checks {
+		if !alertChecks[check] {
+			errList = append(errList, fmt.Sprintf("Unknown check %s under alerting, rate-limit, checks in yaml config", check))
+		} else if limit < 0 {
+			errList = append(errList, fmt.Sprintf("Negative limit of %s under alerting, rate-limit, checks in yaml config", check))
+		}
+	}
+	for i, window := range w.Alerting.Maintenance {
+		for _, e := range window.check() {
+			errList = append(errList, fmt.Sprintf("Invalid window %d under alerting, maintenance in yaml config: %s", i+1, e))
+		}
+	}
+	if w.Alerting.Digest.PagerDuty.Interval < 0 {
+		errList = append(errList, "Negative interval under alerting, digest, pagerduty in yaml config")
+	}
+	if w.ShardHealthReporting.Escalation.After < 0 {
+		errList = append(errList, "Negative after under shard-health-reporting, escalation in yaml config")
+	}
+	if s := w.ShardHealthReporting.Escalation.Severity; s != "" {
+		base := w.Alerting.Severity
+		if base == "" {
+			base = severityCritical
+		}
+		if _, ok := severityRank[s]; !ok {
+			errList = append(errList, fmt.Sprintf("Unknown severity %s under shard-health-reporting, escalation in yaml config", s))
+		} else if w.ShardHealthReporting.Escalation.After > 0 && severityRank[s] <= severityRank[base] {
+			errList = append(errList, fmt.Sprintf(
+				"Escalation severity %s under shard-health-reporting is not higher than alerting severity %s in yaml config", s, base,
+			))
+		}
+	}
+	if len(w.BalanceWatch.WatchedAddresses) > 0 && w.BalanceWatch.Interval == 0 {
+		errList = append(errList, "Missing interval under balance-watch in yaml config")
+	}
+	if w.BalanceWatch.Interval < 0 {
+		errList = append(errList, "Negative interval under balance-watch in yaml config")
+	}
+	for i, a := range w.BalanceWatch.WatchedAddresses {
+		if a.Address == "" {
+			errList = append(errList, fmt.Sprintf("Missing address for entry %d under balance-watch, watched-addresses in yaml config", i))
+		}
+		if a.MinBalance < 0 {
+			errList = append(errList, fmt.Sprintf("Negative min-balance for %s under balance-watch, watched-addresses in yaml config", a.Address))
+		}
+	}
+	if w.DistributionFiles.CountChange < 0 {
+		errList = append(errList, "Negative count-change under node-distribution in yaml config")
+	}
+	if w.DistributionFiles.RPCDiscovery.Enabled && len(w.DistributionFiles.RPCDiscovery.Endpoints) == 0 {
+		errList = append(errList, "Missing endpoints under node-distribution, rpc-discovery in yaml config")
+	}
+	if w.DistributionFiles.LoadRetry.Attempts < 0 || w.DistributionFiles.LoadRetry.Backoff < 0 {
+		errList = append(errList, "Negative attempts or backoff under node-distribution, load-retry in yaml config")
+	}
 	for _, f := range w.DistributionFiles.MachineIPList {
-		_, err := os.Stat(f)
-		if os.IsNotExist(err) {
+		if w.DistributionFiles.RPCDiscovery.Enabled {
+			break
+		}
+		// With retries a missing file may still show up while loading
+		info, err := os.Stat(f)
+		if os.IsNotExist(err) && w.DistributionFiles.LoadRetry.Attempts <= 1 {
 			errList = append(errList, fmt.Sprintf("File not found: %s", f))
+		} else if err == nil && !info.Mode().IsRegular() {
+			errList = append(errList, fmt.Sprintf("Not a regular file: %s", f))
 		}
 	}
 
@@ -274,9 +967,14 @@ func versionS() string {
 	)
 }
 
+// Same version and commit as reported by versionS
+func defaultUserAgent() string {
+	return fmt.Sprintf("harmony-watchdog/%v-%v", version, commit)
+}
+
 func init() {
-	stdlog = log.New(os.Stdout, "", log.Ldate|log.Ltime)
-	errlog = log.New(os.Stderr, "", log.Ldate|log.Ltime)
+	stdlog = newLogger(levelInfo)
+	errlog = newLogger(levelError)
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "version",
 		Short: "Show version",
@@ -288,4 +986,6 @@ func init() {
 	rootCmd.AddCommand(serviceCmd())
 	rootCmd.AddCommand(monitorCmd())
 	rootCmd.AddCommand(generateSampleYAML())
+	rootCmd.AddCommand(validateCmd())
+	rootCmd.AddCommand(listNodesCmd())
 }
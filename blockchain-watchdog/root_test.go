@@ -0,0 +1,240 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Params reading the given shard files, named like shard0.txt
+func distributionParams(t *testing.T, files map[string]string) watchParams {
+	dir := t.TempDir()
+	p := watchParams{}
+	p.Network.RPCPort = 9500
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		p.DistributionFiles.MachineIPList = append(p.DistributionFiles.MachineIPList, path)
+	}
+	return p
+}
+
+func TestDuplicateIPWithinFile(t *testing.T) {
+	p := distributionParams(t, map[string]string{
+		"shard0.txt": "1.2.3.4\n5.6.7.8\n1.2.3.4\n",
+	})
+	_, err := committeesFromFiles(p)
+	if err == nil {
+		t.Fatal("no error for an IP listed twice in one file")
+	}
+	want := p.DistributionFiles.MachineIPList[0] + ":3: 1.2.3.4 (first seen on line 1)"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not name %q", err, want)
+	}
+}
+
+func TestDistributionFileCommentsAndBlankLines(t *testing.T) {
+	p := distributionParams(t, map[string]string{
+		"shard0.txt": "# validators us-west\n" +
+			"\n" +
+			"   \n" +
+			"  1.2.3.4  \n" +
+			"#5.6.7.8\n" +
+			"  # 9.9.9.9 disabled\n" +
+			"7.7.7.7 # validator-3 us-west\n" +
+			"\t8.8.8.8\tvalidator-4 # spare\n",
+	})
+	byShard, err := committeesFromFiles(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1.2.3.4:9500", "7.7.7.7:9500", "8.8.8.8:9500"}
+	if got := byShard[0].members; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("members %v, want %v", got, want)
+	}
+	if _, labelled := byShard[0].labels["7.7.7.7:9500"]; labelled {
+		t.Errorf("trailing comment kept as label %q", byShard[0].labels["7.7.7.7:9500"])
+	}
+	if got := byShard[0].labels["8.8.8.8:9500"]; got != "validator-4" {
+		t.Errorf("label %q, want validator-4", got)
+	}
+}
+
+func TestNodeAddress(t *testing.T) {
+	for _, tc := range []struct {
+		entry string
+		want  string
+		fails bool
+	}{
+		{entry: "1.2.3.4", want: "1.2.3.4:9500"},
+		{entry: "1.2.3.4:9501", want: "1.2.3.4:9501"},
+		{entry: "node.example.com", want: "node.example.com:9500"},
+		{entry: "node.example.com:443", want: "node.example.com:443"},
+		{entry: "[2001:db8::1]:9502", want: "[2001:db8::1]:9502"},
+		{entry: "1.2.3.4:0", fails: true},
+		{entry: "1.2.3.4:http", fails: true},
+		{entry: "1.2.3.4:70000", fails: true},
+	} {
+		got, err := nodeAddress(tc.entry, 9500)
+		if tc.fails {
+			if err == nil {
+				t.Errorf("nodeAddress(%q) = %q, want an error", tc.entry, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("nodeAddress(%q) = %q, %v, want %q", tc.entry, got, err, tc.want)
+		}
+	}
+}
+
+func TestShardFilePorts(t *testing.T) {
+	p := distributionParams(t, map[string]string{
+		"shard0.txt": "1.2.3.4\n1.2.3.5:9600\n",
+		"shard1.txt": "5.6.7.8\n5.6.7.9:9700\n",
+	})
+	p.Network.ShardPorts = map[int]int{1: 9501}
+	byShard, err := committeesFromFiles(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for shard, want := range map[int][]string{
+		0: {"1.2.3.4:9500", "1.2.3.5:9600"},
+		1: {"5.6.7.8:9501", "5.6.7.9:9700"},
+	} {
+		if got := byShard[shard].members; strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("shard %d members %v, want %v", shard, got, want)
+		}
+	}
+}
+
+func TestShardFileInvalidPort(t *testing.T) {
+	p := distributionParams(t, map[string]string{"shard0.txt": "1.2.3.4\n1.2.3.5:abc\n"})
+	_, err := committeesFromFiles(p)
+	if err == nil || !strings.Contains(err.Error(), p.DistributionFiles.MachineIPList[0]+":2:") {
+		t.Errorf("error %v, want one naming line 2", err)
+	}
+}
+
+func TestIntervalsTakeDurations(t *testing.T) {
+	p := watchParams{}
+	err := yaml.Unmarshal([]byte(`
+shard-health-reporting:
+  consensus:
+    interval: 30s
+  cross-link:
+    age-limit:
+      seconds: 15m
+      clear-margin:
+        seconds: 60
+  block-age:
+    max-age: 1m
+    critical: 5m
+    clear-margin: 10
+  clock-skew:
+    tolerance: 5s
+  escalation:
+    after: 30m
+balance-watch:
+  interval: 2m
+`), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := p.ShardHealthReporting.CrossLink.AgeLimit
+	if p.ShardHealthReporting.Consensus.Interval != 30 || p.BalanceWatch.Interval != 120 ||
+		limit.Seconds != 900 || limit.ClearMargin.Seconds != 60 {
+		t.Errorf("consensus interval %d, balance-watch interval %d, age-limit seconds %d and clear-margin %d",
+			p.ShardHealthReporting.Consensus.Interval, p.BalanceWatch.Interval, limit.Seconds, limit.ClearMargin.Seconds,
+		)
+	}
+	age := p.ShardHealthReporting.BlockAge
+	if age.MaxAge != 60 || age.Critical != 300 || age.ClearMargin != 10 {
+		t.Errorf("block-age max-age %d, critical %d and clear-margin %d", age.MaxAge, age.Critical, age.ClearMargin)
+	}
+	if p.ShardHealthReporting.ClockSkew.Tolerance != 5 || p.ShardHealthReporting.Escalation.After != 1800 {
+		t.Errorf("clock-skew tolerance %d and escalation after %d",
+			p.ShardHealthReporting.ClockSkew.Tolerance, p.ShardHealthReporting.Escalation.After,
+		)
+	}
+}
+
+// Values round trip as bare seconds, so fractions can't be kept
+func TestSubSecondDurationsRejected(t *testing.T) {
+	for _, tc := range []struct {
+		timeout string
+		want    seconds
+		err     bool
+	}{
+		{timeout: "2", want: 2},
+		{timeout: "2s", want: 2},
+		{timeout: "1m", want: 60},
+		{timeout: "500ms", err: true},
+		{timeout: "1500ms", err: true},
+		{timeout: "soon", err: true},
+	} {
+		p := watchParams{}
+		err := yaml.Unmarshal([]byte("performance:\n  http-timeout: "+tc.timeout+"\n"), &p)
+		switch {
+		case tc.err && err == nil:
+			t.Errorf("http-timeout %s accepted as %d", tc.timeout, p.Performance.HTTPTimeout)
+		case !tc.err && err != nil:
+			t.Errorf("http-timeout %s rejected: %v", tc.timeout, err)
+		case !tc.err && p.Performance.HTTPTimeout != tc.want:
+			t.Errorf("http-timeout %s read as %d, want %d", tc.timeout, p.Performance.HTTPTimeout, tc.want)
+		}
+	}
+}
+
+func TestNegativeIntervalsRejected(t *testing.T) {
+	p := watchParams{}
+	p.ShardHealthReporting.Consensus.Interval = -10
+	p.BalanceWatch.Interval = -5
+	p.ShardHealthReporting.CrossLink.AgeLimit.Seconds = -1
+	err := p.sanityCheck()
+	if err == nil {
+		t.Fatal("negative intervals accepted")
+	}
+	for _, want := range []string{
+		"Negative interval under shard-health-reporting, consensus",
+		"Negative interval under balance-watch",
+		"Negative seconds under shard-health-reporting, cross-link, age-limit",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not report %q", want)
+		}
+	}
+}
+
+func TestListenerAddressesCollide(t *testing.T) {
+	for _, tc := range []struct {
+		port          int
+		public, admin string
+		collides      bool
+	}{
+		{port: 8080, public: ":8080", collides: true},
+		{port: 8080, admin: "127.0.0.1:8080", collides: true},
+		{public: "127.0.0.1:9000", admin: "127.0.0.1:9000", collides: true},
+		{public: "0.0.0.0:9000", admin: "127.0.0.1:9000", collides: true},
+		{public: "10.0.0.1:9000", admin: "[::]:9000", collides: true},
+		{public: "10.0.0.1:9000", admin: "127.0.0.1:9000"},
+		{port: 8080, public: ":9001", admin: "127.0.0.1:9002"},
+		{public: ":0", admin: ":0"},
+	} {
+		p := watchParams{}
+		p.HTTPReporter.Port = tc.port
+		p.HTTPReporter.Public.Address = tc.public
+		p.HTTPReporter.Admin.Address = tc.admin
+		err := p.sanityCheck()
+		if got := err != nil && strings.Contains(err.Error(), "collides"); got != tc.collides {
+			t.Errorf("port %d, public %q and admin %q: collision reported %v, want %v: %v",
+				tc.port, tc.public, tc.admin, got, tc.collides, err,
+			)
+		}
+	}
+}
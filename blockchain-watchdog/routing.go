@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Sends the alerts it matches to a PagerDuty service or Slack webhook of
+// its own. Empty shards and checks match all, min-severity is compared to
+// the current severity, escalated if so
+type routeRule struct {
+	Shards          []int    `yaml:"shards"`
+	Checks          []string `yaml:"checks"`
+	MinSeverity     string   `yaml:"min-severity"`
+	EventServiceKey string   `yaml:"event-service-key"`
+	SlackWebhook    string   `yaml:"slack-webhook"`
+}
+
+// In the order of the config, each channel takes the first matching rule
+// that sets it
+type alertRoutes []routeRule
+
+func (r routeRule) matches(al alert) bool {
+	if severityRank[al.Severity] < severityRank[r.MinSeverity] {
+		return false
+	}
+	if len(r.Shards) > 0 {
+		shard, err := strconv.Atoi(al.Shard)
+		if err != nil || !containsInt(r.Shards, shard) {
+			return false
+		}
+	}
+	return len(r.Checks) == 0 || containsString(r.Checks, al.Check)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// Target of the first matching rule that sets one, fallback otherwise
+func (r alertRoutes) route(al alert, target func(routeRule) string, fallback string) string {
+	for _, rule := range r {
+		if t := target(rule); t != "" && rule.matches(al) {
+			return t
+		}
+	}
+	return fallback
+}
+
+func (a *alerter) serviceFor(al alert) string {
+	return a.routing.route(al, func(r routeRule) string { return r.EventServiceKey }, a.serviceKey)
+}
+
+// Rules only reroute alerts Slack gets anyway, it needs a webhook-url
+func (r alertRoutes) routesSlack() bool {
+	for _, rule := range r {
+		if rule.SlackWebhook != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (r alertRoutes) check() []string {
+	errList := []string{}
+	for i, rule := range r {
+		where := fmt.Sprintf("alerting, routing, rule %d", i+1)
+		for _, s := range rule.Shards {
+			if s < 0 {
+				errList = append(errList, fmt.Sprintf("Negative shard %d under %s in yaml config", s, where))
+			}
+		}
+		if rule.EventServiceKey == "" && rule.SlackWebhook == "" {
+			errList = append(errList, fmt.Sprintf("Missing event-service-key or slack-webhook under %s in yaml config", where))
+		}
+		if _, ok := severityRank[rule.MinSeverity]; rule.MinSeverity != "" && !ok {
+			errList = append(errList, fmt.Sprintf("Unknown min-severity %s under %s in yaml config", rule.MinSeverity, where))
+		}
+		for _, c := range rule.Checks {
+			if !alertChecks[c] {
+				errList = append(errList, fmt.Sprintf("Unknown check %s under %s in yaml config", c, where))
+			}
+		}
+		if rule.SlackWebhook != "" {
+			if err := validChatURL(rule.SlackWebhook); err != nil {
+				errList = append(errList, fmt.Sprintf("Invalid slack-webhook under %s in yaml config: %v", where, err))
+			}
+		}
+	}
+	return errList
+}
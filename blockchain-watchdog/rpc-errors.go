@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/valyala/fasthttp"
+)
+
+// Why an RPC call failed, tells a firewall dropping packets (timeout) from
+// a node whose RPC server is down (connection-refused) from a node that
+// answers but can't serve the call (rpc-error)
+const (
+	failureTimeout    = "timeout"
+	failureRefused    = "connection-refused"
+	failureReset      = "connection-reset"
+	failureDNS        = "dns"
+	failureTLS        = "tls"
+	failureHTTPStatus = "http-status"
+	failureEmptyReply = "empty-reply"
+	failureRPCError   = "rpc-error"
+	failureMalformed  = "malformed"
+	failureOther      = "other"
+)
+
+var rpcFailures = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: "watchdog",
+		Name:      "rpc_failures_total",
+		Help:      "Failed RPC calls to monitored nodes by failure category",
+	},
+	[]string{"chain", "shard", "method", "category"},
+)
+
+func init() {
+	prometheus.MustRegister(rpcFailures)
+}
+
+var errEmptyReply = errors.New("empty reply received")
+
+type httpStatusError int
+
+func (e httpStatusError) Error() string {
+	return fmt.Sprintf("http status code not 200, received: %d", int(e))
+}
+
+// Error member of a JSON-RPC reply, the node is up but failed the call
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *jsonRPCError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// JSON-RPC error of the reply body if it carries one
+func replyError(body []byte) error {
+	reply := struct {
+		Error *jsonRPCError `json:"error"`
+	}{}
+	if json.Unmarshal(body, &reply) != nil || reply.Error == nil {
+		return nil
+	}
+	return reply.Error
+}
+
+func classifyRPCError(err error) string {
+	var (
+		replayed  *replayedError
+		rpcErr    *jsonRPCError
+		statusErr httpStatusError
+		dnsErr    *net.DNSError
+		netErr    net.Error
+		headerErr tls.RecordHeaderError
+		authErr   x509.UnknownAuthorityError
+		certErr   x509.CertificateInvalidError
+		hostErr   x509.HostnameError
+	)
+	switch {
+	case errors.As(err, &replayed):
+		return replayed.category
+	case errors.As(err, &rpcErr):
+		return failureRPCError
+	case errors.As(err, &statusErr):
+		return failureHTTPStatus
+	case errors.Is(err, errEmptyReply):
+		return failureEmptyReply
+	case errors.As(err, &dnsErr):
+		return failureDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return failureRefused
+	case errors.As(err, &headerErr), errors.As(err, &authErr),
+		errors.As(err, &certErr), errors.As(err, &hostErr),
+		strings.Contains(err.Error(), "tls:"), strings.Contains(err.Error(), "x509:"):
+		return failureTLS
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, fasthttp.ErrConnectionClosed):
+		return failureReset
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, fasthttp.ErrTimeout),
+		errors.Is(err, fasthttp.ErrDialTimeout), errors.As(err, &netErr) && netErr.Timeout():
+		return failureTimeout
+	}
+	return failureOther
+}
+
+func (m *monitor) countRPCFailure(address, rpc, category string) {
+	rpcFailures.WithLabelValues(m.chain, m.shardLabel(address), rpc, category).Inc()
+}
+
+// Failure categories of the shard's nodes with their counts, e.g.
+// "timeout: 3, connection-refused: 1", most frequent first
+func failureSummary(down []noReply, shard int) string {
+	counts := map[string]int{}
+	for _, d := range down {
+		if d.ShardID == shard {
+			counts[d.Category]++
+		}
+	}
+	categories := make([]string, 0, len(counts))
+	for c := range counts {
+		categories = append(categories, c)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if counts[categories[i]] != counts[categories[j]] {
+			return counts[categories[i]] > counts[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	parts := make([]string, 0, len(categories))
+	for _, c := range categories {
+		parts = append(parts, fmt.Sprintf("%s: %d", c, counts[c]))
+	}
+	return strings.Join(parts, ", ")
+}
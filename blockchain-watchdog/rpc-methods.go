@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Logical checks whose RPC method name can be overridden under
+// network-config, rpc-methods for forks or renamed endpoints
+var rpcMethodChecks = map[string]string{
+	"block-header":  BlockHeaderRPC,
+	"node-metadata": NodeMetadataRPC,
+	"cx-pending":    PendingCXRPC,
+	"cross-link":    LastCrossLinkRPC,
+}
+
+// Method sent on the wire for each RPC constant, the constants stay the
+// internal keys of reply channels and metrics. Set by newInstructions
+var rpcMethods = map[string]string{}
+
+func rpcMethod(rpc string) string {
+	if method, exists := rpcMethods[rpc]; exists {
+		return method
+	}
+	return rpc
+}
+
+func unknownRPCMethodChecks(overrides map[string]string) []string {
+	unknown := []string{}
+	for check := range overrides {
+		if _, exists := rpcMethodChecks[check]; !exists {
+			unknown = append(unknown, check)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func emptyRPCMethodChecks(overrides map[string]string) []string {
+	empty := []string{}
+	for check, method := range overrides {
+		if method == "" {
+			empty = append(empty, check)
+		}
+	}
+	sort.Strings(empty)
+	return empty
+}
+
+// Keyed by RPC constant, only overridden entries
+func resolveRPCMethods(overrides map[string]string) map[string]string {
+	resolved := map[string]string{}
+	for check, method := range overrides {
+		if rpc, exists := rpcMethodChecks[check]; exists && method != "" {
+			resolved[rpc] = method
+		}
+	}
+	return resolved
+}
+
+func printRPCMethods() {
+	checks := []string{}
+	for check := range rpcMethodChecks {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+	fmt.Println("RPC methods:")
+	for _, check := range checks {
+		fmt.Printf("  %s: %s\n", check, rpcMethod(rpcMethodChecks[check]))
+	}
+}
@@ -8,6 +8,8 @@ const (
 	SuperCommitteeRPC = "hmy_getSuperCommittees"
 	LastCrossLinkRPC  = "hmy_getLastCrossLinks"
 	LatestHeadersRPC  = "hmy_getLatestChainHeaders"
+	BalanceRPC        = "hmy_getBalance"
+	HeaderByNumberRPC = "hmy_getHeaderByNumber"
 	JSONVersion       = "2.0"
 )
 
@@ -36,6 +38,13 @@ type NodeMetadataReply struct {
 		NotConnected int `json:"not-connected"`
 		TotalKnown   int `json:"total-known-peers"`
 	} `json:"p2p-connectivity"`
+	Consensus struct {
+		BlockNum     uint64 `json:"blocknum"`
+		ViewID       uint64 `json:"viewId"`
+		ViewChangeID uint64 `json:"viewChangeId"`
+		Phase        string `json:"phase"`
+		Mode         string `json:"mode"`
+	} `json:"consensus"`
 }
 
 type NodeMetadata struct {
@@ -120,7 +129,7 @@ type Header struct {
 func getRPCRequest(rpc string) map[string]interface{} {
 	return map[string]interface{}{
 		"jsonrpc": JSONVersion,
-		"method":  rpc,
+		"method":  rpcMethod(rpc),
 		"params":  []interface{}{},
 		"id": "1",
 	}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"strings"
+)
+
+// Comments written above each key of generate-sample, keyed by the dotted
+// path of the key. Keys without an entry are printed bare
+var sampleComments = map[string]string{
+	"auth": "Optional, credentials of the alert channels, uncomment those in use",
+	"auth.pagerduty.event-service-key": "Optional, PagerDuty events API integration key of 32 characters,\n" +
+		"without it alerts are only logged",
+	"auth.slack.webhook-url": "Optional, Slack incoming webhook alerts are posted to when they\n" +
+		"start firing, once escalated and on recovery",
+	"auth.slack.check-webhooks": "Optional, webhook per check name, its alerts go to that channel\n" +
+		"instead of webhook-url",
+	"auth.telegram.bot-token":  "Optional, token of the Telegram bot that posts alerts like Slack",
+	"auth.telegram.chat-id":    "Chat the bot posts to, e.g. a group ID such as -1001234567890",
+	"auth.telegram.api-url":    "Optional, a self-hosted Bot API server instead of api.telegram.org",
+	"auth.discord.webhook-url": "Optional, Discord channel webhook alerts are posted to like Slack",
+	"auth.teams.webhook-url": "Optional, Microsoft Teams incoming webhook alerts are posted to\n" +
+		"as adaptive cards like Slack",
+	"auth.opsgenie.api-key": "Optional, key of an OpsGenie API integration, alerts are opened when\n" +
+		"they start firing and closed once they clear",
+	"auth.opsgenie.team":        "Optional, team the OpsGenie alerts are assigned to",
+	"auth.opsgenie.check-teams": "Optional, team per check name instead of team",
+	"auth.opsgenie.api-url":     "Optional, https://api.eu.opsgenie.com for the EU instance",
+	"auth.webhook.urls": "Optional, URLs alerts are posted to as JSON when they start firing,\n" +
+		"once escalated and when they clear",
+	"auth.webhook.urls.signing-secret": "Optional, secret of this url, each post to it then carries the\n" +
+		"hex HMAC-SHA256 of its body",
+	"auth.webhook.urls.signature-header": "Header the signature is sent in, defaults to X-Watchdog-Signature",
+	"auth.webhook.headers":               "Optional, headers sent with every post, e.g. a token",
+	"auth.twilio.account-sid":            "Optional, Twilio account critical alerts are texted from",
+	"auth.twilio.from":                   "Number of the account, or a messaging service SID MG...",
+	"auth.twilio.to":                     "On-call numbers, each gets every critical alert",
+	"auth.email.host": "Optional, SMTP server alerts are mailed through when they start\n" +
+		"firing, once escalated and on recovery",
+	"auth.email.port":             "Defaults to 587",
+	"auth.email.tls":              "One of starttls, tls (implicit, usually port 465), none; defaults to starttls",
+	"auth.email.username":         "Optional, PLAIN auth, only over TLS or to localhost",
+	"auth.email.to":               "Recipients of every alert mail",
+	"alerting":                    "Optional, how alerts are delivered",
+	"alerting.notify-on-recovery": "Post a recovery message to the chat channels once the alert clears",
+	"alerting.auto-resolve":       "Resolve the PagerDuty incident once the alert clears, defaults to true",
+	"alerting.severity":           "One of info, warning, error, critical; defaults to critical",
+	"alerting.templates": "Optional Go text/template overrides per channel, an empty\n" +
+		"subject or body keeps the built-in wording. Available fields:\n" +
+		".Shard .Check .Value .Threshold .Delta .Severity .Chain .Timestamp\n" +
+		".FirstSeen .Duration .Nodes, e.g. {{join .Nodes \", \"}}",
+	"alerting.dedup-strategy": "How alerts map to incidents, one of per-check, per-shard,\n" +
+		"per-network; defaults to per-check",
+	"alerting.strict-startup": "Stop the daemon when the startup self-check fails for the primary\n" +
+		"key or a machine-ip-list file can't be read",
+	"alerting.group-window": "Seconds to hold an alert for others on the same shard, several\n" +
+		"are sent as one incident. Zero sends each right away",
+	"alerting.retrigger-interval": "Seconds before an alert still in breach is triggered again under\n" +
+		"the same incident key. Zero triggers it on every check",
+	"alerting.page-after-checks": "Checks in a row an alert has to be found in breach before it is\n" +
+		"sent to PagerDuty, chat channels get it at once. Zero pages right away",
+	"alerting.ack-period": "Seconds an alert acknowledged on /alerts-<chain>/<id>/ack stops\n" +
+		"repeating, defaults to an hour",
+	"alerting.delivery-failures": "Delivery attempts of a channel failing in a row before an\n" +
+		"alert-delivery alert goes out through the other channels",
+	"alerting.audit-log": "Optional, file every event sent to a channel and whether it was\n" +
+		"delivered is appended to as JSON lines, served on /audit-<chain>",
+	"alerting.digest.pagerduty.interval": "Seconds between digests of non-critical alerts, zero sends every\n" +
+		"alert right away",
+	"alerting.routing": "Optional rules sending the alerts of shards, checks and at or above\n" +
+		"min-severity to a PagerDuty service or Slack webhook of their own. Each\n" +
+		"channel takes the first matching rule that sets it, the rest as above",
+	"alerting.rate-limit": "Optional, new alerts per minute across all checks and per check.\n" +
+		"Past either the rest go out as one rollup alert, zero is unlimited",
+	"alerting.suppress": "Optional nodes, by address or by a word of their label in the\n" +
+		"distribution file, whose alerts are never sent. Their failures still\n" +
+		"show on the report and status pages",
+	"alerting.maintenance": "Optional windows, RFC 3339 start and end, during which the alerts of\n" +
+		"the shards listed, or of only the nodes listed, are not sent. Checks\n" +
+		"keep running and reporting, more can be added on /maintenance-<chain>",
+	"alerting.min-severity.pagerduty": "Alerts below this severity are not sent but still show on\n" +
+		"/alerts, empty sends all",
+	"alerting.min-severity.slack":    "Alerts below this severity are not posted to Slack, empty posts all",
+	"alerting.min-severity.telegram": "Alerts below this severity are not posted to Telegram, empty posts all",
+	"alerting.min-severity.discord":  "Alerts below this severity are not posted to Discord, empty posts all",
+	"alerting.min-severity.opsgenie": "Alerts below this severity are not opened in OpsGenie, empty opens all",
+	"alerting.min-severity.webhook":  "Alerts below this severity are not posted to the webhooks, empty posts all",
+	"alerting.min-severity.email":    "Alerts below this severity are not mailed, empty mails all",
+	"alerting.min-severity.teams":    "Alerts below this severity are not posted to Teams, empty posts all",
+	"network-config.target-chain":    "Required, name of the chain, e.g. mainnet or testnet",
+	"network-config.public-rpc":      "Required, RPC port of the nodes",
+	"network-config.proxy": "Optional, http:// or socks5:// proxy all RPC calls are sent\n" +
+		"through, e.g. socks5://127.0.0.1:1080",
+	"network-config.client-cert": "Optional, PEM client certificate and key for nodes requiring mTLS",
+	"network-config.shard-rpc-ports": "Optional, RPC port per shard ID overriding public-rpc, e.g.\n" +
+		"  shard-rpc-ports:\n" +
+		"    3: 9501",
+	"network-config.rpc-methods": "Optional, RPC method per request, one of block-header,\n" +
+		"node-metadata, cx-pending and cross-link",
+	"inspect-schedule": "Required, seconds between polls of each request, or a duration\n" +
+		"such as 90s or 2m",
+	"inspect-schedule.jitter": "Optional, percent (at most 50) of the block-header and\n" +
+		"node-metadata intervals each node is polled at a random point within",
+	"performance.num-workers":  "Required, number of concurrent RPC requests",
+	"performance.http-timeout": "Required, whole seconds before an RPC request is given up, e.g. 2 or 2s",
+	"performance.user-agent":   "Optional, User-Agent header of the RPC requests",
+	"performance.max-concurrent-shards": "Optional, count of shards whose nodes are polled at once, zero\n" +
+		"polls every shard at once",
+	"http-reporter.port": "Port of the status, metrics and health endpoints, required\n" +
+		"unless unix-socket or admin is set. With admin it serves /healthz only",
+	"http-reporter.max-connections": "Number of connections served at once",
+	"http-reporter.unix-socket":     "Optional, path of a unix socket serving the same endpoints",
+	"http-reporter.cors-allowed-origins": "Optional, origins allowed to read the endpoints from a browser,\n" +
+		"* allows any",
+	"http-reporter.pprof": "Serve the Go profiler on /debug/pprof, asking for the admin basic-auth\n" +
+		"when set, else to loopback clients only",
+	"http-reporter.public": "Optional, listener serving only /healthz, e.g. to a load balancer.\n" +
+		"TLS is used when both tls-cert and tls-key are set",
+	"http-reporter.admin": "Optional, listener serving every endpoint, basic-auth protects it\n" +
+		"when a username is set",
+	"grpc-reporter.port": "Optional, port of the gRPC status service, zero disables it",
+	"tracing.otlp": "Optional, OTLP/HTTP collector URL the spans of each inspection cycle,\n" +
+		"its RPC calls with their DNS lookup and dial, and the aggregation of the\n" +
+		"replies are sent to, no endpoint disables tracing",
+	"tracing.otlp.headers": "Sent with every export, e.g. the collector's API key",
+	"statsd": "Optional, StatsD agent on host:port sent shard_height, consensus_up,\n" +
+		"reachable_nodes and cycle_duration in ms, no host disables it",
+	"statsd.prefix": "Start of every metric name, watchdog when empty",
+	"statsd.dogstatsd-tags": "Send chain, shard and check as DogStatsD tags, e.g. to a Datadog\n" +
+		"agent, else they are part of the name as in watchdog.mainnet.shard_0.shard_height",
+	"influxdb": "Optional, InfluxDB url every check result of a shard and every node's\n" +
+		"block header reply are written to as line protocol, no url disables it",
+	"influxdb.version":  "1 writes to database, 2 to bucket of org",
+	"influxdb.token":    "Sent as Authorization: Token, user:password for InfluxDB 1.x",
+	"influxdb.database": "Version 1 only, retention-policy is optional",
+	"logging.level":     "Optional, lowest level logged, info, warn or error, --log-level overrides it",
+	"logging.format": "Optional, text or json, one object per line with time, level,\n" +
+		"component, msg and the shard, check and node a line is about",
+	"logging.file": "Optional, file all lines are written to instead of stdout and stderr,\n" +
+		"rotated to <name>-<time>.<ext> once past max-size megabytes or interval",
+	"logging.max-age":     "Optional, days a rotated file is kept, zero keeps them",
+	"logging.max-backups": "Optional, rotated files kept, the oldest are removed first, zero keeps all",
+	"shard-health-reporting": "Thresholds of each check, a check with enabled: false\n" +
+		"neither polls nor alerts",
+	"shard-health-reporting.consensus.interval": "Required, seconds between consensus checks, e.g. 10 or 30s",
+	"shard-health-reporting.consensus.warning":  "Required, seconds without a new block before alerting",
+	"shard-health-reporting.consensus.critical": "Optional, seconds past which the alert is critical, sent as\n" +
+		"a warning until then. Zero keeps a single tier",
+	"shard-health-reporting.cx-pending.pending-limit": "Required, count of pending cross-shard\n" +
+		"transactions before alerting",
+	"shard-health-reporting.cx-pending.critical":     "Optional, pending count past which the alert is critical",
+	"shard-health-reporting.cx-pending.clear-margin": "Count below pending-limit before the alert clears",
+	"shard-health-reporting.cross-link.warning":      "Required, seconds without a new cross-link before alerting",
+	"shard-health-reporting.cross-link.critical":     "Optional, seconds past which the alert is critical",
+	"shard-health-reporting.cross-link.age-limit": "Optional, blocks the last cross-link may lag behind and\n" +
+		"seconds, e.g. 900 or 15m, since its block was made, zero disables each",
+	"shard-health-reporting.shard-height.tolerance":    "Required, blocks a node may be behind the others",
+	"shard-health-reporting.shard-height.critical":     "Optional, blocks behind past which a node alert is critical",
+	"shard-health-reporting.shard-height.clear-margin": "Blocks below tolerance before the alert clears",
+	"shard-health-reporting.shard-height.quorum": "Percent of nodes out of sync before alerting on the shard,\n" +
+		"zero alerts on each node",
+	"shard-health-reporting.shard-height.quantile": "Percentile of the node heights compared against, 50 is\n" +
+		"the median",
+	"shard-health-reporting.connectivity.tolerance":    "Required, percent of nodes that may be unreachable",
+	"shard-health-reporting.connectivity.clear-margin": "Percent below tolerance before the alert clears",
+	"shard-health-reporting.connectivity.nodes-off": "Optional, nodes below tolerance that alert on the shard, a count\n" +
+		"or a fraction such as 0.33 of the committee, zero disables",
+	"shard-health-reporting.shard-height.nodes-off": "Optional, nodes off the shard height that alert on the shard,\n" +
+		"a count or a fraction such as 0.33 of the committee, zero disables",
+	"shard-health-reporting.connectivity.internal": "Optional, thresholds for the nodes tagged group=internal on\n" +
+		"their own, zero disables",
+	"shard-health-reporting.shard-height.internal": "Optional, quorum percent of the nodes tagged group=internal\n" +
+		"on their own, zero disables",
+	"shard-health-reporting.block-time.target": "Optional, expected seconds between blocks, zero disables it",
+	"shard-health-reporting.block-time.tolerance-percent": "Percent the average block time may be\n" +
+		"above target",
+	"shard-health-reporting.block-time.window":               "Count of blocks averaged",
+	"shard-health-reporting.block-time.clear-margin-percent": "Percent below tolerance before the alert clears",
+	"shard-health-reporting.block-age.max-age": "Optional, seconds the latest block may be old, zero only\n" +
+		"reports the age",
+	"shard-health-reporting.block-age.critical":     "Optional, seconds of age past which the alert is critical",
+	"shard-health-reporting.block-age.clear-margin": "Seconds below max-age before the alert clears",
+	"shard-health-reporting.signing.window": "Optional, count of sampled blocks the signing rate of each\n" +
+		"validator key is taken over",
+	"shard-health-reporting.signing.threshold-percent":    "Percent of signed blocks below which to alert, zero disables it",
+	"shard-health-reporting.signing.clear-margin-percent": "Percent above threshold before the alert clears",
+	"shard-health-reporting.view-spread.tolerance": "Optional, count of views the nodes may differ by, zero only\n" +
+		"reports the views",
+	"shard-health-reporting.clock-skew.tolerance": "Optional, seconds a node clock may be off the median of all\n" +
+		"nodes, zero disables it",
+	"shard-health-reporting.warm-up.samples": "Optional, count of block header cycles block-time and signing\n" +
+		"alerts of a shard are held back for a baseline",
+	"shard-health-reporting.beacon": "Optional, stricter checks for the beacon shard, zero disables\n" +
+		"stall and lag-tolerance each",
+	"shard-health-reporting.beacon.shard":                "Shard ID of the beacon chain",
+	"shard-health-reporting.beacon.stall":                "Seconds without a new beacon block before alerting",
+	"shard-health-reporting.beacon.lag-tolerance":        "Blocks a beacon node may be behind the others",
+	"shard-health-reporting.height-regression.tolerance": "Blocks a node may drop below its highest height",
+	"shard-health-reporting.metadata-changes.fields":     "Metadata fields whose changes are logged",
+	"shard-health-reporting.metadata-changes.alert":      "Also alert on a change",
+	"shard-health-reporting.escalation.after": "Optional, seconds an alert may fire before it is raised to\n" +
+		"severity, zero disables it",
+	"shard-health-reporting.escalation.event-service-key": "Optional, PagerDuty integration key of an additional\n" +
+		"service escalated alerts are also sent to",
+	"balance-watch": "Optional, alerts when a watched address falls below min-balance",
+	"balance-watch.interval": "Seconds between balance checks, e.g. 300 or 5m, required with\n" +
+		"watched-addresses",
+	"balance-watch.watched-addresses": "Address, shard ID and min-balance in ONE of each watched\n" +
+		"address",
+	"node-distribution": "Required, where the nodes of each shard come from",
+	"node-distribution.machine-ip-list": "One file per shard, the trailing number of the basename is the\n" +
+		"shard ID. Each line holds an IP, optionally with :port and a label",
+	"node-distribution.rpc-discovery": "Optional, learn the shard of each endpoint from its node metadata\n" +
+		"instead of machine-ip-list",
+	"node-distribution.load-balanced": "Optional, shards polled through one load balancer, e.g.\n" +
+		"  load-balanced:\n" +
+		"    3: api.s3.t.hmny.io:9500",
+	"node-distribution.include-shards": "Optional, shard IDs to monitor, empty monitors every shard",
+	"node-distribution.exclude-shards": "Optional, shard IDs left out, e.g. a shard under maintenance",
+	"node-distribution.load-retry": "Tries of each distribution source on startup, backoff in\n" +
+		"seconds doubles after each retry",
+	"node-distribution.count-change": "Optional, percent a SIGHUP reload or rpc-discovery refresh may\n" +
+		"change the node count of a shard by before an info alert, zero leaves it out",
+}
+
+// Printed commented out with everything below them. They hold placeholder
+// credentials, live they would post to made up channels or fail the
+// sanity check
+var sampleCommentedOut = map[string]bool{
+	"auth":             true,
+	"alerting.routing": true,
+	"shard-health-reporting.escalation.event-service-key": true,
+}
+
+// Writes the comment of each key above it, keys are found by indentation
+// which yaml.Marshal keeps at two spaces per level. List items sit at the
+// indentation of their key
+func annotateSample(raw string) string {
+	var out strings.Builder
+	keys := []string{}
+	commentedOut := -1
+	for _, line := range strings.Split(raw, "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		indent := len(line) - len(trimmed)
+		if commentedOut >= 0 && (indent < commentedOut || indent == commentedOut && !strings.HasPrefix(trimmed, "- ")) {
+			commentedOut = -1
+		}
+		keyStart := indent
+		if strings.HasPrefix(trimmed, "- ") {
+			trimmed = trimmed[2:]
+			keyStart += 2
+		}
+		if colon := strings.Index(trimmed, ":"); colon > 0 && !strings.ContainsAny(trimmed[:colon], " '\"") {
+			depth := keyStart / 2
+			if depth < len(keys) {
+				keys = keys[:depth]
+			}
+			for len(keys) < depth {
+				keys = append(keys, "")
+			}
+			keys = append(keys, trimmed[:colon])
+			if comment, ok := sampleComments[strings.Join(keys, ".")]; ok {
+				for _, c := range strings.Split(comment, "\n") {
+					out.WriteString(strings.Repeat(" ", indent) + "# " + c + "\n")
+				}
+			}
+			if commentedOut < 0 && sampleCommentedOut[strings.Join(keys, ".")] {
+				commentedOut = indent
+			}
+		}
+		if commentedOut >= 0 {
+			line = strings.Repeat(" ", indent) + "# " + line[indent:]
+		}
+		out.WriteString(line + "\n")
+	}
+	return strings.TrimRight(out.String(), "\n")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"time"
+)
+
+const pagerDutyEndpoint = "events.pagerduty.com:443"
+
+type selfCheckResult struct {
+	name     string
+	ok       bool
+	critical bool
+	detail   string
+}
+
+// PagerDuty integration keys are 32 characters, the events API has no
+// way to check a key without sending an event, so only the format is
+var pagerDutyKeyFormat = regexp.MustCompile(`^[0-9a-zA-Z]{32}$`)
+
+// Pings one node of each shard and checks the alert channels once before
+// monitoring starts, with alerting, strict-startup a failed critical
+// channel keeps the daemon from starting
+func (service *Service) selfCheck() error {
+	configureRPCClient(service.Performance.HTTPTimeout, service.Network.Proxy, service.Performance.UserAgent,
+		service.Network.ClientCert, service.Network.ClientKey,
+	)
+	results := []selfCheckResult{}
+	for _, s := range sortedShards(service.superCommittee) {
+		results = append(results, pingShard(s, service.superCommittee[s].members))
+	}
+	timeout := service.Performance.HTTPTimeout.duration()
+	results = append(results,
+		checkPagerDutyKey("pagerduty", service.Auth.PagerDuty.EventServiceKey, true, timeout),
+	)
+	if key := service.ShardHealthReporting.Escalation.EventServiceKey; key != "" {
+		results = append(results, checkPagerDutyKey("pagerduty escalation", key, false, timeout))
+	}
+	for i, rule := range service.Alerting.Routing {
+		if rule.EventServiceKey != "" {
+			results = append(results,
+				checkPagerDutyKey(fmt.Sprintf("pagerduty route %d", i+1), rule.EventServiceKey, false, timeout),
+			)
+		}
+	}
+
+	passed, failedCritical := 0, []string{}
+	for _, r := range results {
+		state := "PASS"
+		if r.ok {
+			passed++
+		} else {
+			state = "FAIL"
+			if r.critical {
+				failedCritical = append(failedCritical, r.name)
+			}
+		}
+		stdlog.Printf("[selfCheck] %-22s %s %s", r.name, state, r.detail)
+	}
+	stdlog.Printf("[selfCheck] %d of %d checks passed", passed, len(results))
+	if len(failedCritical) > 0 && service.Alerting.StrictStartup {
+		return fmt.Errorf("startup self-check failed for %v with strict-startup set", failedCritical)
+	}
+	return nil
+}
+
+// The first member answering is enough, a shard with none answering fails
+func pingShard(shard int, members []string) selfCheckResult {
+	result := selfCheckResult{name: fmt.Sprintf("shard %d", shard)}
+	if len(members) == 0 {
+		result.detail = "no nodes"
+		return result
+	}
+	requestBody, _ := json.Marshal(getRPCRequest(BlockHeaderRPC))
+	type r struct {
+		Result BlockHeaderReply `json:"result"`
+	}
+	var lastErr error
+	for _, address := range members {
+		raw, _, err := request(rpcScheme+address, requestBody)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		reply := r{}
+		if err := json.Unmarshal(raw, &reply); err != nil {
+			lastErr = err
+			continue
+		}
+		result.ok = true
+		result.detail = fmt.Sprintf("%s at block %d", address, reply.Result.BlockNumber)
+		return result
+	}
+	result.detail = fmt.Sprintf("none of %d nodes reachable, last error: %v", len(members), lastErr)
+	return result
+}
+
+func checkPagerDutyKey(name, key string, critical bool, timeout time.Duration) selfCheckResult {
+	result := selfCheckResult{name: name, critical: critical}
+	if key == "" {
+		result.ok, result.critical = true, false
+		result.detail = "not configured, skipped"
+		return result
+	}
+	if !pagerDutyKeyFormat.MatchString(key) {
+		result.detail = "event-service-key is not a 32 character integration key"
+		return result
+	}
+	conn, err := net.DialTimeout("tcp", pagerDutyEndpoint, timeout)
+	if err != nil {
+		result.detail = fmt.Sprintf("events API unreachable: %v", err)
+		return result
+	}
+	conn.Close()
+	result.ok = true
+	result.detail = "key format valid, events API reachable"
+	return result
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Set by --name and --description of the service commands, so watchdogs
+	// of several networks, or two of the same, each get a unit of their own
+	serviceName        string
+	serviceDescription string
+)
+
+// A systemd unit name, a prefix and an optional @instance of the characters
+// systemd allows. Units are at most 255 bytes, .service included
+var unitNamePattern = regexp.MustCompile(`^[A-Za-z0-9:_.\\-]+(@[A-Za-z0-9:_.\\-]+)?$`)
+
+const maxUnitName = 255 - len(".service")
+
+// The name and description the daemon is registered with, by default
+// harmony-watchdogd@<target-chain>
+func serviceUnit(chain string) (string, string, error) {
+	name, descr := fmt.Sprintf(nameFMT, chain), description
+	if serviceName != "" {
+		name = strings.TrimSuffix(serviceName, ".service")
+	}
+	if serviceDescription != "" {
+		descr = serviceDescription
+	}
+	if len(name) > maxUnitName {
+		return "", "", fmt.Errorf("service name %q is longer than %d characters", name, maxUnitName)
+	}
+	if !unitNamePattern.MatchString(name) {
+		return "", "", fmt.Errorf(
+			"service name %q is not a valid systemd unit name, use letters, digits and :_.\\- with an optional @instance",
+			name,
+		)
+	}
+	if strings.ContainsAny(descr, "\r\n") {
+		return "", "", fmt.Errorf("service description can't span lines")
+	}
+	return name, descr, nil
+}
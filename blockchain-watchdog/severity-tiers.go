@@ -0,0 +1,47 @@
+package main
+
+// Critical thresholds of the checks with two tiers, in the unit of the
+// check's own threshold. Past that one alone such a check alerts as a
+// warning, past critical as critical. Zero keeps a single tier at the
+// check's configured severity
+type criticalThresholds struct {
+	Consensus   uint64
+	ShardHeight uint64
+	CxPending   uint64
+	CrossLink   uint64
+	BlockAge    uint64
+}
+
+func criticalOf(params watchParams) criticalThresholds {
+	r := params.ShardHealthReporting
+	return criticalThresholds{
+		Consensus:   uint64(r.Consensus.Critical),
+		ShardHeight: uint64(r.ShardHeight.Critical),
+		CxPending:   uint64(r.CxPending.Critical),
+		CrossLink:   uint64(r.CrossLink.Critical),
+		BlockAge:    uint64(r.BlockAge.Critical),
+	}
+}
+
+// Severity of a value already past the warning threshold, empty without a
+// critical one so the alert gets the default
+func tieredSeverity(value float64, critical uint64) string {
+	if critical == 0 {
+		return ""
+	}
+	if value >= float64(critical) {
+		return severityCritical
+	}
+	return severityWarning
+}
+
+// The critical threshold has to be past the warning one
+func checkTier(name, section string, warning, critical int) []string {
+	if critical < 0 {
+		return []string{"Negative critical under shard-health-reporting, " + section + " in yaml config"}
+	}
+	if critical > 0 && critical <= warning {
+		return []string{"Critical under shard-health-reporting, " + section + " is not past " + name + " in yaml config"}
+	}
+	return nil
+}
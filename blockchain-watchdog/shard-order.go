@@ -0,0 +1,41 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Shards are iterated in ascending shard ID wherever they are inspected or
+// printed, so logs, /status and list-nodes read the same from run to run
+func sortedShards(byShard map[int]committee) []int {
+	shards := make([]int, 0, len(byShard))
+	for s := range byShard {
+		shards = append(shards, s)
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+// Shard IDs of a per shard summary of the monitor loops
+func summaryShards(summary any) []string {
+	shards := make([]string, 0, len(summary))
+	for s := range summary {
+		shards = append(shards, s)
+	}
+	sortShardIDs(shards)
+	return shards
+}
+
+// Numerically, so shard 10 comes after shard 9
+func sortShardIDs(shards []string) {
+	sort.SliceStable(shards, func(i, j int) bool { return shardIDLess(shards[i], shards[j]) })
+}
+
+func shardIDLess(a, b string) bool {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return x < y
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSortedShardsStable(t *testing.T) {
+	byShard := map[int]committee{}
+	for _, s := range []int{10, 3, 0, 7, 1, 2, 9, 4} {
+		byShard[s] = committee{}
+	}
+	want := []int{0, 1, 2, 3, 4, 7, 9, 10}
+	for i := 0; i < 50; i++ {
+		if got := sortedShards(byShard); !reflect.DeepEqual(got, want) {
+			t.Fatalf("call %d returned %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestSummaryShardsStable(t *testing.T) {
+	summary := any{"10": nil, "2": nil, "0": nil, "1": nil, "11": nil, "beacon": nil}
+	want := []string{"0", "1", "2", "10", "11", "beacon"}
+	for i := 0; i < 50; i++ {
+		if got := summaryShards(summary); !reflect.DeepEqual(got, want) {
+			t.Fatalf("call %d returned %v, want %v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Narrows the shards of node-distribution without touching its files, an
+// empty include-shards keeps every shard before exclude-shards is applied
+type shardSelection struct {
+	Include []int `yaml:"include-shards"`
+	Exclude []int `yaml:"exclude-shards"`
+}
+
+// Drops the shards not selected from byShard and returns the listed shard
+// IDs it doesn't hold
+func (s shardSelection) apply(byShard map[int]committee) []int {
+	unknown := []int{}
+	for _, id := range append(append([]int{}, s.Include...), s.Exclude...) {
+		if _, exists := byShard[id]; !exists {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(s.Include) > 0 {
+		included := map[int]bool{}
+		for _, id := range s.Include {
+			included[id] = true
+		}
+		for id := range byShard {
+			if !included[id] {
+				delete(byShard, id)
+			}
+		}
+	}
+	for _, id := range s.Exclude {
+		delete(byShard, id)
+	}
+	return unknown
+}
+
+func unknownShardsError(unknown []int) error {
+	ids := []string{}
+	for _, id := range unknown {
+		ids = append(ids, fmt.Sprint(id))
+	}
+	return fmt.Errorf("Unknown shards %s under node-distribution, include-shards or exclude-shards in yaml config",
+		strings.Join(ids, ", "),
+	)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultSigningWindow = 100
+
+// Window is assumed as sampled blocks, a zero threshold disables the check
+type signingParams struct {
+	checkToggle `yaml:",inline"`
+	Window      int `yaml:"window"`
+	Threshold   int `yaml:"threshold-percent"`
+	ClearMargin int `yaml:"clear-margin-percent"`
+}
+
+type validatorSigning struct {
+	Address string  `json:"address"`
+	BLSKey  string  `json:"bls-key"`
+	Signed  int     `json:"signed"`
+	Blocks  int     `json:"blocks"`
+	Rate    float64 `json:"signing-rate-percent"`
+}
+
+// Rolling window of commit participation per BLS key, fed by the last commit
+// bitmap of successive block header polls. Blocks between polls are not seen
+type signingTracker struct {
+	window  int
+	last    map[string]uint64
+	history map[string][]bool
+}
+
+func newSigningTracker(window int) *signingTracker {
+	if window <= 0 {
+		window = defaultSigningWindow
+	}
+	return &signingTracker{
+		window:  window,
+		last:    map[string]uint64{},
+		history: map[string][]bool{},
+	}
+}
+
+// Bit i of the bitmap, least significant bit first, is committee member i
+func signedBy(bitmap []byte, index int) bool {
+	if index/8 >= len(bitmap) {
+		return false
+	}
+	return bitmap[index/8]&(1<<uint(index%8)) != 0
+}
+
+// Records the latest block of a shard once and returns the signing rate of
+// each member of the shard committee
+func (t *signingTracker) record(shard string, latest BlockHeader,
+	members []CommitteeMember,
+) []validatorSigning {
+	height := latest.Payload.BlockNumber
+	bitmap, err := hex.DecodeString(strings.TrimPrefix(latest.Payload.LastCommitBitmap, "0x"))
+	if prev, exists := t.last[shard]; err == nil && (!exists || height > prev) {
+		t.last[shard] = height
+		for i, member := range members {
+			samples := append(t.history[member.BLSKey], signedBy(bitmap, i))
+			if len(samples) > t.window {
+				samples = samples[len(samples)-t.window:]
+			}
+			t.history[member.BLSKey] = samples
+		}
+	}
+	rates := []validatorSigning{}
+	for _, member := range members {
+		samples := t.history[member.BLSKey]
+		if len(samples) == 0 {
+			continue
+		}
+		signed := 0
+		for _, s := range samples {
+			if s {
+				signed++
+			}
+		}
+		rates = append(rates, validatorSigning{
+			member.Address, member.BLSKey, signed, len(samples),
+			float64(signed) / float64(len(samples)) * 100,
+		})
+	}
+	sort.SliceStable(rates, func(i, j int) bool { return rates[i].Rate < rates[j].Rate })
+	return rates
+}
+
+func (m *monitor) signingMonitor(
+	params signingParams, tracker *signingTracker,
+	chain string, blockHeaderData any,
+) {
+	m.inUse.Lock()
+	deciders := m.SuperCommittee.CurrentCommittee.Deciders
+	m.inUse.Unlock()
+	if params.Threshold == 0 || len(deciders) == 0 {
+		return
+	}
+	signing := map[string][]validatorSigning{}
+	for _, shard := range summaryShards(blockHeaderData) {
+		summary := blockHeaderData[shard]
+		latest := summary.(any)["latest-block"].(BlockHeader)
+		rates := tracker.record(shard, latest, deciders["shard-"+shard].Committee)
+		signing[shard] = rates
+		m.markPolled(shard, signingCheck)
+		below := 0
+		for _, r := range rates {
+			incidentKey := fmt.Sprintf("Validator %s signing rate below threshold! - %s", r.BLSKey, chain)
+			// Wait for a full window and the warm-up before judging a key
+			full := r.Blocks >= tracker.window && m.warmedUp(shard)
+			overTrigger := full && r.Rate < float64(params.Threshold)
+			pastClear := r.Rate >= float64(params.Threshold+params.ClearMargin)
+			if !m.alerts.breached(incidentKey, overTrigger, pastClear) {
+				m.alerts.clear(incidentKey)
+				continue
+			}
+			below++
+			message := fmt.Sprintf(signingMessage,
+				r.Address, r.BLSKey, r.Rate, params.Threshold, r.Signed, r.Blocks, shard, chain,
+			)
+			err := m.alerts.trigger(alert{
+				Key: incidentKey, Chain: chain, Shard: shard,
+				Check: signingCheck, Message: message,
+				Value:     strconv.FormatFloat(r.Rate, 'f', 2, 64),
+				Threshold: strconv.Itoa(params.Threshold),
+			})
+			if err != nil {
+				errlog.Print(err)
+			} else {
+				stdlog.Printf("[signingMonitor] Alert triggered! %s", incidentKey)
+			}
+		}
+		stdlog.Printf("[signingMonitor] Shard %s, Keys tracked: %d, Below threshold: %d",
+			shard, len(rates), below,
+		)
+	}
+	m.inUse.Lock()
+	m.signingRates = signing
+	m.inUse.Unlock()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Incoming webhook of the Slack channel alerts are posted to, the alerts of
+// a check listed in check-webhooks go to that check's webhook instead, e.g.
+// shard-height drift to the channel of the node operators
+type slackParams struct {
+	WebhookURL    string            `yaml:"webhook-url"`
+	CheckWebhooks map[string]string `yaml:"check-webhooks"`
+}
+
+// Timeout of a single post to a chat API
+const chatTimeout = 10 * time.Second
+
+type slackSender struct {
+	params  slackParams
+	routing alertRoutes
+	client  *http.Client
+}
+
+// A routing rule's webhook wins over check-webhooks
+func (s *slackSender) webhook(al alert) string {
+	fallback := s.params.WebhookURL
+	if w, exists := s.params.CheckWebhooks[al.Check]; exists {
+		fallback = w
+	}
+	return s.routing.route(al, func(r routeRule) string { return r.SlackWebhook }, fallback)
+}
+
+// Slack needs &, < and > escaped, the rest of the text is sent as is
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func (s *slackSender) send(al alert, title, body string) error {
+	text := fmt.Sprintf("*%s*\n```%s```", slackEscaper.Replace(title), slackEscaper.Replace(strings.TrimSpace(body)))
+	payload, _ := json.Marshal(map[string]string{"text": text})
+	res, err := s.client.Post(s.webhook(al), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return chatStatus("slack", res)
+}
+
+// A revoked webhook, an unknown chat or a malformed message fails the same
+// way on every retry
+func chatStatus(channel string, res *http.Response) error {
+	if res.StatusCode/100 == 2 {
+		return nil
+	}
+	err := fmt.Errorf("%s replied %s", channel, res.Status)
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode < 500 {
+		return permanent{err}
+	}
+	return err
+}
+
+// Webhooks and chat APIs are all reached over http or https
+func validChatURL(address string) error {
+	u, err := url.Parse(address)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("expected an http or https URL")
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metrics are sent over UDP to host:port, names start with prefix.
+// With dogstatsd-tags chain, shard and check are DogStatsD tags, else
+// they are part of the name, e.g. watchdog.mainnet.shard_0.shard_height
+type statsdParams struct {
+	Host   string `yaml:"host"`
+	Port   int    `yaml:"port"`
+	Prefix string `yaml:"prefix"`
+	Tags   bool   `yaml:"dogstatsd-tags"`
+}
+
+const (
+	defaultStatsdPrefix = "watchdog"
+	// Fits an ethernet frame after the IP and UDP headers, longer packets
+	// may be fragmented and dropped on the way
+	statsdPacketSize = 1432
+)
+
+func (p statsdParams) check() []string {
+	errList := []string{}
+	if p.Host == "" && p.Port == 0 {
+		return errList
+	}
+	if p.Host == "" {
+		errList = append(errList, "Missing host under statsd in yaml config")
+	}
+	if p.Port <= 0 || p.Port > 65535 {
+		errList = append(errList, fmt.Sprintf("Invalid port %d under statsd in yaml config", p.Port))
+	}
+	if strings.ContainsAny(p.Prefix, ":|@# ") {
+		errList = append(errList, fmt.Sprintf("Invalid prefix %s under statsd in yaml config", p.Prefix))
+	}
+	return errList
+}
+
+type statsdTag struct {
+	key, value string
+}
+
+// Metrics go out as they are recorded, a write never waits on the agent
+// and a failed one is dropped
+type statsdEmitter struct {
+	params  statsdParams
+	chain   string
+	conn    net.Conn
+	lock    sync.Mutex
+	failing bool
+}
+
+// Nil when no host is set, every method of a nil emitter is a no-op
+func newStatsdEmitter(params statsdParams, chain string) *statsdEmitter {
+	if params.Host == "" {
+		return nil
+	}
+	if params.Prefix == "" {
+		params.Prefix = defaultStatsdPrefix
+	}
+	address := net.JoinHostPort(params.Host, strconv.Itoa(params.Port))
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		errlog.Printf("[statsd] Could not reach %s, metrics are not sent, Error: %v", address, err)
+		return nil
+	}
+	stdlog.Printf("[statsd] Sending metrics to %s", address)
+	return &statsdEmitter{params: params, chain: chain, conn: conn}
+}
+
+// StatsD reserves : | @ # and , in names and tags, dots split names
+func statsdSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', '#', ',', '.', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+func (e *statsdEmitter) line(metric, value, kind string, tags ...statsdTag) string {
+	var b strings.Builder
+	b.WriteString(e.params.Prefix + ".")
+	if !e.params.Tags {
+		b.WriteString(statsdSafe(e.chain) + ".")
+		for _, t := range tags {
+			b.WriteString(t.key + "_" + statsdSafe(t.value) + ".")
+		}
+	}
+	b.WriteString(metric + ":" + value + "|" + kind)
+	if e.params.Tags {
+		b.WriteString("|#chain:" + statsdSafe(e.chain))
+		for _, t := range tags {
+			b.WriteString("," + t.key + ":" + statsdSafe(t.value))
+		}
+	}
+	return b.String()
+}
+
+func (e *statsdEmitter) gauge(metric string, value float64, tags ...statsdTag) string {
+	return e.line(metric, strconv.FormatFloat(value, 'f', -1, 64), "g", tags...)
+}
+
+func (e *statsdEmitter) timing(metric string, seconds float64, tags ...statsdTag) string {
+	return e.line(metric, strconv.FormatFloat(seconds*1000, 'f', 3, 64), "ms", tags...)
+}
+
+// Lines are packed into as few packets as fit them
+func (e *statsdEmitter) send(lines []string) {
+	if e == nil || len(lines) == 0 {
+		return
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	var packet strings.Builder
+	var err error
+	flush := func() {
+		if packet.Len() == 0 {
+			return
+		}
+		if _, werr := e.conn.Write([]byte(packet.String())); werr != nil {
+			err = werr
+		}
+		packet.Reset()
+	}
+	for _, l := range lines {
+		if packet.Len() > 0 && packet.Len()+1+len(l) > statsdPacketSize {
+			flush()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(l)
+	}
+	flush()
+	// Logged once per outage rather than for every packet
+	if err != nil && !e.failing {
+		errlog.Printf("[statsd] Could not send metrics to %s, Error: %v", e.conn.RemoteAddr(), err)
+	} else if err == nil && e.failing {
+		stdlog.Printf("[statsd] Sending metrics to %s again", e.conn.RemoteAddr())
+	}
+	e.failing = err != nil
+}
+
+func (e *statsdEmitter) recordConsensus(heights map[string]uint64, progress map[string]bool) {
+	if e == nil {
+		return
+	}
+	lines := []string{}
+	for shard, height := range heights {
+		lines = append(lines, e.gauge("shard_height", float64(height), statsdTag{"shard", shard}))
+	}
+	for shard, ok := range progress {
+		v := 0.0
+		if ok {
+			v = 1
+		}
+		lines = append(lines, e.gauge("consensus_up", v, statsdTag{"shard", shard}))
+	}
+	e.send(lines)
+}
+
+// Nodes of the committee that replied to the latest block header poll
+func (e *statsdEmitter) recordReachable(reachable map[int]int) {
+	if e == nil {
+		return
+	}
+	lines := []string{}
+	for shard, count := range reachable {
+		lines = append(lines, e.gauge("reachable_nodes", float64(count), statsdTag{"shard", strconv.Itoa(shard)}))
+	}
+	e.send(lines)
+}
+
+func (e *statsdEmitter) recordCycle(check string, total float64, shards map[string]float64) {
+	if e == nil {
+		return
+	}
+	lines := []string{e.timing("cycle_duration", total, statsdTag{"check", check}, statsdTag{"shard", "all"})}
+	for shard, took := range shards {
+		lines = append(lines, e.timing("cycle_duration", took, statsdTag{"check", check}, statsdTag{"shard", shard}))
+	}
+	e.send(lines)
+}
+
+func (e *statsdEmitter) close() {
+	if e == nil {
+		return
+	}
+	e.conn.Close()
+}
@@ -0,0 +1,171 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package statuspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// WatchdogClient is the client API for Watchdog service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WatchdogClient interface {
+	// Same content as the /status-<chain> endpoint
+	GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*Status, error)
+	// Sends the status once right away, then after each block header
+	// inspection cycle until the client goes away
+	WatchStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (Watchdog_WatchStatusClient, error)
+}
+
+type watchdogClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWatchdogClient(cc grpc.ClientConnInterface) WatchdogClient {
+	return &watchdogClient{cc}
+}
+
+func (c *watchdogClient) GetStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*Status, error) {
+	out := new(Status)
+	err := c.cc.Invoke(ctx, "/watchdog.Watchdog/GetStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *watchdogClient) WatchStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (Watchdog_WatchStatusClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Watchdog_ServiceDesc.Streams[0], "/watchdog.Watchdog/WatchStatus", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &watchdogWatchStatusClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Watchdog_WatchStatusClient interface {
+	Recv() (*Status, error)
+	grpc.ClientStream
+}
+
+type watchdogWatchStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *watchdogWatchStatusClient) Recv() (*Status, error) {
+	m := new(Status)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// WatchdogServer is the server API for Watchdog service.
+// All implementations must embed UnimplementedWatchdogServer
+// for forward compatibility
+type WatchdogServer interface {
+	// Same content as the /status-<chain> endpoint
+	GetStatus(context.Context, *StatusRequest) (*Status, error)
+	// Sends the status once right away, then after each block header
+	// inspection cycle until the client goes away
+	WatchStatus(*StatusRequest, Watchdog_WatchStatusServer) error
+	mustEmbedUnimplementedWatchdogServer()
+}
+
+// UnimplementedWatchdogServer must be embedded to have forward compatible implementations.
+type UnimplementedWatchdogServer struct {
+}
+
+func (UnimplementedWatchdogServer) GetStatus(context.Context, *StatusRequest) (*Status, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedWatchdogServer) WatchStatus(*StatusRequest, Watchdog_WatchStatusServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchStatus not implemented")
+}
+func (UnimplementedWatchdogServer) mustEmbedUnimplementedWatchdogServer() {}
+
+// UnsafeWatchdogServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WatchdogServer will
+// result in compilation errors.
+type UnsafeWatchdogServer interface {
+	mustEmbedUnimplementedWatchdogServer()
+}
+
+func RegisterWatchdogServer(s grpc.ServiceRegistrar, srv WatchdogServer) {
+	s.RegisterService(&Watchdog_ServiceDesc, srv)
+}
+
+func _Watchdog_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WatchdogServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/watchdog.Watchdog/GetStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WatchdogServer).GetStatus(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Watchdog_WatchStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WatchdogServer).WatchStatus(m, &watchdogWatchStatusServer{stream})
+}
+
+type Watchdog_WatchStatusServer interface {
+	Send(*Status) error
+	grpc.ServerStream
+}
+
+type watchdogWatchStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *watchdogWatchStatusServer) Send(m *Status) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Watchdog_ServiceDesc is the grpc.ServiceDesc for Watchdog service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Watchdog_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "watchdog.Watchdog",
+	HandlerType: (*WatchdogServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetStatus",
+			Handler:    _Watchdog_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchStatus",
+			Handler:       _Watchdog_WatchStatus_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "status.proto",
+}
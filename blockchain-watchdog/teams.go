@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Incoming webhook of the Microsoft Teams channel alerts are posted to as
+// adaptive cards
+type teamsParams struct {
+	WebhookURL string `yaml:"webhook-url"`
+}
+
+// Teams rejects payloads over 28KB
+const teamsMaxBody = 20000
+
+type teamsSender struct {
+	params teamsParams
+	client *http.Client
+}
+
+type teamsFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+// Colors of the card title
+var teamsColors = map[string]string{
+	severityInfo:     "accent",
+	severityWarning:  "warning",
+	severityError:    "attention",
+	severityCritical: "attention",
+}
+
+// Value and threshold under names of their own where the check is known,
+// e.g. how long consensus is stalled or how far a node is behind
+func teamsFacts(al alert) []teamsFact {
+	facts := []teamsFact{{"Chain", al.Chain}}
+	if al.Shard != "" {
+		facts = append(facts, teamsFact{"Shard", al.Shard})
+	}
+	facts = append(facts, teamsFact{"Check", al.Check}, teamsFact{"Severity", al.Severity})
+	value, threshold := "Value", "Threshold"
+	switch {
+	case al.Check == consensusCheck:
+		stalled, stalledErr := strconv.Atoi(al.Value)
+		after, afterErr := strconv.Atoi(al.Threshold)
+		if stalledErr == nil && afterErr == nil {
+			return append(facts,
+				teamsFact{"Stalled for", (time.Duration(stalled) * time.Second).String()},
+				teamsFact{"Alerts after", (time.Duration(after) * time.Second).String()},
+			)
+		}
+	case al.Check == shardHeightCheck && len(al.Nodes) == 1:
+		node, nodeErr := strconv.ParseUint(al.Value, 10, 64)
+		shard, shardErr := strconv.ParseUint(al.Threshold, 10, 64)
+		if nodeErr == nil && shardErr == nil && shard > node {
+			facts = append(facts, teamsFact{"Behind by", strconv.FormatUint(shard-node, 10) + " blocks"})
+		}
+		value, threshold = "Node height", "Shard height"
+	}
+	if value != "" && al.Value != "" {
+		facts = append(facts, teamsFact{value, al.Value})
+	}
+	if al.Threshold != "" {
+		facts = append(facts, teamsFact{threshold, al.Threshold})
+	}
+	if len(al.Nodes) > 0 {
+		facts = append(facts, teamsFact{"Nodes", strings.Join(al.Nodes, ", ")})
+	}
+	return facts
+}
+
+func teamsCard(al alert, title, body string) map[string]interface{} {
+	color := teamsColors[al.Severity]
+	if strings.HasPrefix(title, recoveredPrefix) {
+		color = "good"
+	}
+	return map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.2",
+		"body": []map[string]interface{}{
+			{"type": "TextBlock", "text": title, "weight": "bolder", "size": "medium", "color": color, "wrap": true},
+			{"type": "FactSet", "facts": teamsFacts(al)},
+			{
+				"type": "TextBlock", "text": cutMessage(strings.TrimSpace(body), teamsMaxBody),
+				"fontType": "monospace", "wrap": true, "separator": true,
+			},
+		},
+	}
+}
+
+func (t *teamsSender) send(al alert, title, body string) error {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     teamsCard(al, title, body),
+		}},
+	})
+	res, err := t.client.Post(t.params.WebhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return chatStatus("teams", res)
+}
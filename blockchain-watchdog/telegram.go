@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Token of the bot as given by @BotFather and the chat alerts are sent to,
+// e.g. a group ID such as -1001234567890. api-url is only needed for a
+// self-hosted Bot API server
+type telegramParams struct {
+	BotToken string `yaml:"bot-token"`
+	ChatID   string `yaml:"chat-id"`
+	APIURL   string `yaml:"api-url"`
+}
+
+const (
+	telegramAPI = "https://api.telegram.org"
+	// Telegram rejects messages over 4096 characters
+	telegramMaxBody = 3500
+)
+
+type telegramSender struct {
+	params telegramParams
+	client *http.Client
+}
+
+// Characters MarkdownV2 reserves outside of code blocks, and within them
+var (
+	telegramEscaper = strings.NewReplacer(
+		`\`, `\\`, "_", `\_`, "*", `\*`, "[", `\[`, "]", `\]`, "(", `\(`, ")", `\)`,
+		"~", `\~`, "`", "\\`", ">", `\>`, "#", `\#`, "+", `\+`, "-", `\-`, "=", `\=`,
+		"|", `\|`, "{", `\{`, "}", `\}`, ".", `\.`, "!", `\!`,
+	)
+	telegramCodeEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+)
+
+// The title in bold over the message as a code block, cut to fit
+func telegramText(title, body string) string {
+	body = cutMessage(strings.TrimSpace(body), telegramMaxBody)
+	return fmt.Sprintf("*%s*\n```\n%s\n```", telegramEscaper.Replace(title), telegramCodeEscaper.Replace(body))
+}
+
+func (t *telegramSender) send(al alert, title, body string) error {
+	api := t.params.APIURL
+	if api == "" {
+		api = telegramAPI
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"chat_id":                  t.params.ChatID,
+		"text":                     telegramText(title, body),
+		"parse_mode":               "MarkdownV2",
+		"disable_web_page_preview": true,
+	})
+	res, err := t.client.Post(
+		strings.TrimSuffix(api, "/")+"/bot"+t.params.BotToken+"/sendMessage",
+		"application/json", bytes.NewReader(payload),
+	)
+	if err != nil {
+		// The token is part of the URL the client puts in its errors
+		return errors.New(strings.Replace(err.Error(), t.params.BotToken, redacted, -1))
+	}
+	res.Body.Close()
+	return chatStatus("telegram", res)
+}
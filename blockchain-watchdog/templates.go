@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Go text/template strings, an empty subject or body keeps the built-in
+// incident key or message respectively
+type messageTemplate struct {
+	Subject string `yaml:"subject"`
+	Body    string `yaml:"body"`
+}
+
+// One entry per notification channel
+type templateParams struct {
+	PagerDuty messageTemplate `yaml:"pagerduty"`
+	Slack     messageTemplate `yaml:"slack"`
+	Telegram  messageTemplate `yaml:"telegram"`
+	Discord   messageTemplate `yaml:"discord"`
+	OpsGenie  messageTemplate `yaml:"opsgenie"`
+	Webhook   messageTemplate `yaml:"webhook"`
+	Email     messageTemplate `yaml:"email"`
+	Teams     messageTemplate `yaml:"teams"`
+	Twilio    messageTemplate `yaml:"twilio"`
+}
+
+// Fields available to templates, e.g. {{.Shard}} or {{.Value}}. Delta is
+// how far Value is from Threshold when both are whole numbers, e.g. the
+// blocks a node is behind, Duration how long the alert has been firing
+type alertData struct {
+	Shard     string
+	Check     string
+	Value     string
+	Threshold string
+	Delta     string
+	Severity  string
+	Chain     string
+	Timestamp string
+	FirstSeen string
+	Duration  string
+	Key       string
+	Message   string
+	Nodes     []string
+}
+
+// Functions available to templates, e.g. {{join .Nodes ", "}}
+var templateFuncs = template.FuncMap{"join": strings.Join}
+
+// Empty unless both are whole numbers
+func thresholdDelta(value, threshold string) string {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return ""
+	}
+	t, err := strconv.ParseInt(threshold, 10, 64)
+	if err != nil {
+		return ""
+	}
+	if v < t {
+		return strconv.FormatInt(t-v, 10)
+	}
+	return strconv.FormatInt(v-t, 10)
+}
+
+type alertTemplates struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+func parseTemplates(channel string, t messageTemplate) (*alertTemplates, error) {
+	parsed := &alertTemplates{}
+	var err error
+	if t.Subject != "" {
+		parsed.subject, err = template.New(channel + "-subject").Funcs(templateFuncs).Parse(t.Subject)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s subject template: %v", channel, err)
+		}
+	}
+	if t.Body != "" {
+		parsed.body, err = template.New(channel + "-body").Funcs(templateFuncs).Parse(t.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s body template: %v", channel, err)
+		}
+	}
+	// Catches unknown fields at startup rather than during an incident
+	for _, t := range []*template.Template{parsed.subject, parsed.body} {
+		if t != nil {
+			if err := t.Execute(ioutil.Discard, alertData{}); err != nil {
+				return nil, fmt.Errorf("invalid %s template: %v", channel, err)
+			}
+		}
+	}
+	return parsed, nil
+}
+
+func executeTemplate(t *template.Template, data alertData, fallback string) string {
+	if t == nil {
+		return fallback
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, data); err != nil {
+		errlog.Printf("[executeTemplate] Using built-in wording, %s failed: %v", t.Name(), err)
+		return fallback
+	}
+	return out.String()
+}
+
+// Subject and body of the alert, built-in key and message as fallback
+func (t *alertTemplates) render(al alert, now time.Time) (string, string) {
+	if t == nil {
+		return al.Key, al.Message
+	}
+	firstSeen := al.firstSeen
+	if firstSeen.IsZero() {
+		firstSeen = now
+	}
+	data := alertData{
+		Shard:     al.Shard,
+		Check:     al.Check,
+		Value:     al.Value,
+		Threshold: al.Threshold,
+		Delta:     thresholdDelta(al.Value, al.Threshold),
+		Severity:  al.Severity,
+		Chain:     al.Chain,
+		Timestamp: now.UTC().Format(time.RFC3339),
+		FirstSeen: firstSeen.UTC().Format(time.RFC3339),
+		Duration:  now.Sub(firstSeen).Round(time.Second).String(),
+		Key:       al.Key,
+		Message:   al.Message,
+		Nodes:     al.Nodes,
+	}
+	return executeTemplate(t.subject, data, al.Key), executeTemplate(t.body, data, al.Message)
+}
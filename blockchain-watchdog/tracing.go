@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Spans are sent as OTLP/HTTP JSON to endpoint, e.g.
+// http://localhost:4318/v1/traces, with headers on every export. No
+// endpoint leaves tracing off
+type otlpParams struct {
+	Endpoint string            `yaml:"endpoint"`
+	Headers  map[string]string `yaml:"headers"`
+}
+
+const (
+	// Finished spans waiting for export, further spans are dropped so a
+	// slow or down collector never holds up an inspection
+	spanBuffer     = 4096
+	spanBatch      = 512
+	exportInterval = 5 * time.Second
+	exportTimeout  = 10 * time.Second
+
+	spanKindInternal = 1
+	spanKindClient   = 3
+	statusError      = 2
+)
+
+type spanAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type spanStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// A span as encoded by OTLP JSON, IDs are hex and times are nanoseconds
+// since the epoch as strings
+type span struct {
+	TraceID    string          `json:"traceId"`
+	SpanID     string          `json:"spanId"`
+	ParentID   string          `json:"parentSpanId,omitempty"`
+	Name       string          `json:"name"`
+	Kind       int             `json:"kind"`
+	Start      string          `json:"startTimeUnixNano"`
+	End        string          `json:"endTimeUnixNano"`
+	Attributes []spanAttribute `json:"attributes"`
+	Status     spanStatus      `json:"status"`
+	tracer     *tracer
+}
+
+type spanKey struct{}
+
+type tracer struct {
+	params   otlpParams
+	resource []spanAttribute
+	spans    chan *span
+	dropped  int
+	failing  bool
+	lock     sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+	client   *http.Client
+}
+
+// Nil when tracing is off, every method of a nil tracer and span is a no-op
+func newTracer(params otlpParams, chain string) *tracer {
+	if params.Endpoint == "" {
+		return nil
+	}
+	t := &tracer{
+		params: params,
+		resource: attributes(
+			"service.name", "harmony-watchdog",
+			"service.version", version,
+			"chain", chain,
+		),
+		spans:  make(chan *span, spanBuffer),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+		client: &http.Client{Timeout: exportTimeout},
+	}
+	dialTimings = &dialLog{byAddr: map[string][]dialTiming{}}
+	go t.export()
+	stdlog.Printf("[tracer] Exporting spans to %s", params.Endpoint)
+	return t
+}
+
+func attributes(pairs ...string) []spanAttribute {
+	attrs := make([]spanAttribute, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		a := spanAttribute{Key: pairs[i]}
+		a.Value.StringValue = pairs[i+1]
+		attrs = append(attrs, a)
+	}
+	return attrs
+}
+
+func randomID(size int) string {
+	id := make([]byte, size)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Starts a span as a child of the span of ctx, if any, and returns ctx
+// carrying the new span. Attributes are key value pairs
+func (t *tracer) start(ctx context.Context, name string, kind int, pairs ...string) (context.Context, *span) {
+	if t == nil {
+		return ctx, nil
+	}
+	now := time.Now()
+	s := &span{
+		SpanID:     randomID(8),
+		Name:       name,
+		Kind:       kind,
+		Start:      strconv.FormatInt(now.UnixNano(), 10),
+		Attributes: attributes(pairs...),
+		tracer:     t,
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok && parent != nil {
+		s.TraceID, s.ParentID = parent.TraceID, parent.SpanID
+	} else {
+		s.TraceID = randomID(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Marks the span failed with err if set and queues it for export
+func (s *span) end(err error) {
+	s.endAt(time.Now(), err)
+}
+
+// A finished child of the span of ctx, for a phase timed by the code it
+// was spent in
+func (t *tracer) record(ctx context.Context, name string, start, end time.Time, err error, pairs ...string) {
+	if t == nil {
+		return
+	}
+	_, s := t.start(ctx, name, spanKindInternal, pairs...)
+	s.Start = strconv.FormatInt(start.UnixNano(), 10)
+	s.endAt(end, err)
+}
+
+func (s *span) set(key, value string) {
+	if s == nil {
+		return
+	}
+	s.Attributes = append(s.Attributes, attributes(key, value)...)
+}
+
+func (s *span) endAt(end time.Time, err error) {
+	if s == nil {
+		return
+	}
+	s.End = strconv.FormatInt(end.UnixNano(), 10)
+	if err != nil {
+		s.Status = spanStatus{statusError, err.Error()}
+	}
+	select {
+	case s.tracer.spans <- s:
+	default:
+		s.tracer.lock.Lock()
+		s.tracer.dropped++
+		s.tracer.lock.Unlock()
+	}
+}
+
+func (t *tracer) export() {
+	defer close(t.done)
+	batch := []*span{}
+	tick := time.NewTicker(exportInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case s := <-t.spans:
+			batch = append(batch, s)
+			if len(batch) < spanBatch {
+				continue
+			}
+		case <-tick.C:
+		case <-t.stop:
+			for len(t.spans) > 0 {
+				batch = append(batch, <-t.spans)
+			}
+			t.send(batch)
+			return
+		}
+		t.send(batch)
+		batch = []*span{}
+	}
+}
+
+// Failures are logged once until an export succeeds again
+func (t *tracer) send(batch []*span) {
+	t.lock.Lock()
+	dropped := t.dropped
+	t.dropped = 0
+	t.lock.Unlock()
+	if dropped > 0 {
+		stdlog.Printf("[tracer] Dropped %d spans, the export buffer was full", dropped)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	err := t.post(batch)
+	switch {
+	case err != nil && !t.failing:
+		errlog.Printf("[tracer] Could not export %d spans to %s, Error: %v",
+			len(batch), t.params.Endpoint, err,
+		)
+	case err == nil && t.failing:
+		stdlog.Printf("[tracer] Exporting spans to %s again", t.params.Endpoint)
+	}
+	t.failing = err != nil
+}
+
+func (t *tracer) post(batch []*span) error {
+	payload := map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{"attributes": t.resource},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "harmony-watchdog", "version": version},
+				"spans": batch,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.params.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.params.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("collector replied %s", res.Status)
+	}
+	return nil
+}
+
+// Exports the spans still buffered, run on shutdown
+func (t *tracer) shutdown() {
+	if t == nil {
+		return
+	}
+	close(t.stop)
+	select {
+	case <-t.done:
+	case <-time.After(exportTimeout):
+		errlog.Print("[tracer] Gave up exporting the last spans")
+	}
+}
+
+func validOTLPEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("expected an http or https URL such as http://localhost:4318/v1/traces")
+	}
+	return nil
+}
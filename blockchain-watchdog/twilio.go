@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Twilio account the text messages are sent from, and the on-call numbers
+// they go to. Only critical alerts are texted. from is a number of the
+// account or the SID of a messaging service, MG...
+type twilioParams struct {
+	AccountSID string   `yaml:"account-sid"`
+	AuthToken  string   `yaml:"auth-token"`
+	From       string   `yaml:"from"`
+	To         []string `yaml:"to"`
+}
+
+const (
+	twilioAPI = "https://api.twilio.com/2010-04-01/Accounts/"
+	// Two segments, the gist fits and long texts get split or dropped
+	twilioMaxBody = 300
+)
+
+// Numbers in E.164 form, e.g. +14155550100
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// One per number, so a failing number is retried without texting the
+// others again
+type twilioSender struct {
+	params twilioParams
+	to     string
+	client *http.Client
+}
+
+func (t *twilioSender) send(al alert, title, body string) error {
+	form := url.Values{
+		"To":   {t.to},
+		"Body": {cutMessage(title+"\n"+strings.TrimSpace(body), twilioMaxBody)},
+	}
+	if strings.HasPrefix(t.params.From, "MG") {
+		form.Set("MessagingServiceSid", t.params.From)
+	} else {
+		form.Set("From", t.params.From)
+	}
+	req, err := http.NewRequest(http.MethodPost,
+		twilioAPI+url.PathEscape(t.params.AccountSID)+"/Messages.json", strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return permanent{err}
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.params.AccountSID, t.params.AuthToken)
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return chatStatus("twilio", res)
+}
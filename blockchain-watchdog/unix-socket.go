@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// The socket itself is created on start, so only its directory can be checked up front
+func checkSocketPath(socket string) error {
+	dir := filepath.Dir(socket)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("directory %s of unix-socket does not exist", dir)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s of unix-socket is not a directory", dir)
+	}
+	probe, err := ioutil.TempFile(dir, ".harmony-watchdog-")
+	if err != nil {
+		return fmt.Errorf("directory %s of unix-socket is not writable", dir)
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	if info, err := os.Lstat(socket); err == nil && info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("unix-socket %s exists and is not a socket", socket)
+	}
+	return nil
+}
+
+// A socket file left behind by an earlier run that didn't shut down cleanly
+// would make listening fail, anything that isn't a socket is left alone
+func (m *monitor) listenReportingSocket(socket string) (net.Listener, error) {
+	if info, err := os.Lstat(socket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("unix-socket %s exists and is not a socket", socket)
+		}
+		stdlog.Printf("[listenReportingSocket] Removing stale socket %s", socket)
+		if err := os.Remove(socket); err != nil {
+			return nil, err
+		}
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	m.inUse.Lock()
+	m.reportingSocket = l
+	m.inUse.Unlock()
+	return l, nil
+}
+
+// Closing a unix listener also unlinks its socket file
+func (m *monitor) closeReportingSocket() {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	if m.reportingSocket != nil {
+		m.reportingSocket.Close()
+		m.reportingSocket = nil
+	}
+}
+
+func unixSocketTransport(socket string) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+}
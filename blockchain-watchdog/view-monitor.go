@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Tolerance is assumed as view numbers, zero disables alerting on the spread
+type viewSpreadParams struct {
+	checkToggle `yaml:",inline"`
+	Tolerance   int `yaml:"tolerance"`
+}
+
+type nodeView struct {
+	Node         string `json:"node"`
+	ViewID       uint64 `json:"view-id"`
+	ViewChangeID uint64 `json:"view-change-id"`
+	Phase        string `json:"phase"`
+	Mode         string `json:"mode"`
+}
+
+type shardViews struct {
+	Min    uint64     `json:"min-view-id"`
+	Max    uint64     `json:"max-view-id"`
+	Spread uint64     `json:"spread"`
+	Nodes  []nodeView `json:"nodes"`
+}
+
+// Nodes not reporting consensus state, i.e. older versions, are left out
+func (m *monitor) viewMonitor(params viewSpreadParams, chain string, data MetadataContainer) {
+	stdlog.Print("[viewMonitor] Running consensus view check")
+	views := map[string]shardViews{}
+	for _, metadata := range data.Nodes {
+		c := metadata.Payload.Consensus
+		if c.ViewID == 0 {
+			continue
+		}
+		shard := strconv.FormatUint(uint64(metadata.Payload.ShardID), 10)
+		v, exists := views[shard]
+		if !exists || c.ViewID < v.Min {
+			v.Min = c.ViewID
+		}
+		if c.ViewID > v.Max {
+			v.Max = c.ViewID
+		}
+		v.Spread = v.Max - v.Min
+		v.Nodes = append(v.Nodes, nodeView{
+			m.nodeName(metadata.IP), c.ViewID, c.ViewChangeID, c.Phase, c.Mode,
+		})
+		views[shard] = v
+	}
+	shards := []string{}
+	for shard := range views {
+		shards = append(shards, shard)
+	}
+	sortShardIDs(shards)
+	for _, shard := range shards {
+		v := views[shard]
+		sort.SliceStable(v.Nodes, func(i, j int) bool { return v.Nodes[i].Node < v.Nodes[j].Node })
+		m.markPolled(shard, viewSpreadCheck)
+		stdlog.Printf("[viewMonitor] Shard: %s, View IDs: %d to %d, Spread: %d",
+			shard, v.Min, v.Max, v.Spread,
+		)
+		if params.Tolerance == 0 {
+			continue
+		}
+		spread := v.Spread > uint64(params.Tolerance)
+		m.setDegraded(shard, viewSpreadCheck, spread)
+		incidentKey := fmt.Sprintf("Shard %s nodes disagree on view! - %s", shard, chain)
+		if !spread {
+			m.alerts.clear(incidentKey)
+			continue
+		}
+		message := fmt.Sprintf(viewSpreadMessage,
+			shard, v.Min, v.Max, v.Spread, params.Tolerance, len(v.Nodes), chain,
+		)
+		err := m.alerts.trigger(alert{
+			Key: incidentKey, Chain: chain, Shard: shard,
+			Check: viewSpreadCheck, Message: message,
+			Value:     strconv.FormatUint(v.Spread, 10),
+			Threshold: strconv.Itoa(params.Tolerance),
+		})
+		if err != nil {
+			errlog.Print(err)
+		} else {
+			stdlog.Printf("[viewMonitor] Alert triggered! %s", incidentKey)
+		}
+	}
+	m.inUse.Lock()
+	m.views = views
+	m.inUse.Unlock()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// Samples is the number of block header cycles a shard needs before the
+// checks judging against a baseline, block-time and signing, may alert.
+// Zero lets them alert from the first cycle
+type warmUpParams struct {
+	Samples int `yaml:"samples"`
+}
+
+type shardWarmUp struct {
+	Samples int  `json:"samples"`
+	Active  bool `json:"active"`
+}
+
+type readiness struct {
+	Ready    bool                   `json:"ready"`
+	Required int                    `json:"required-samples"`
+	Shards   map[string]shardWarmUp `json:"shards"`
+}
+
+// Counts one block header cycle of the shard, logs when it leaves warm-up
+func (m *monitor) recordSample(shard string) {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	if m.samples == nil {
+		m.samples = map[string]int{}
+	}
+	m.samples[shard]++
+	if m.samples[shard] == m.warmUp {
+		stdlog.Printf("[warmUp] Shard %s warming up -> active after %d samples", shard, m.warmUp)
+	}
+}
+
+func (m *monitor) warmedUp(shard string) bool {
+	m.inUse.Lock()
+	defer m.inUse.Unlock()
+	return m.samples[shard] >= m.warmUp
+}
+
+// A shard is ready once warmed up with at least one sample, replies with
+// 503 until all known shards are
+func (m *monitor) readyzJSON(w http.ResponseWriter, req *http.Request) {
+	r := readiness{true, m.warmUp, map[string]shardWarmUp{}}
+	m.inUse.Lock()
+	for _, shard := range m.nodes {
+		key := strconv.Itoa(shard)
+		samples := m.samples[key]
+		active := samples > 0 && samples >= m.warmUp
+		r.Shards[key] = shardWarmUp{samples, active}
+		r.Ready = r.Ready && active
+	}
+	m.inUse.Unlock()
+	if !r.Ready || len(r.Shards) == 0 {
+		r.Ready = false
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(r)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// URLs every alert is posted to as JSON, for incident tooling without an
+// integration of its own. Headers are sent with each post, e.g. a token
+type webhookParams struct {
+	URLs    []webhookURL      `yaml:"urls"`
+	Headers map[string]string `yaml:"headers"`
+}
+
+// With a signing-secret each post to the URL carries the hex HMAC-SHA256
+// of its body in signature-header
+type webhookURL struct {
+	URL             string `yaml:"url"`
+	SigningSecret   string `yaml:"signing-secret"`
+	SignatureHeader string `yaml:"signature-header"`
+}
+
+const defaultSignatureHeader = "X-Watchdog-Signature"
+
+func (u webhookURL) check() error {
+	if err := validChatURL(u.URL); err != nil {
+		return err
+	}
+	if u.SignatureHeader != "" && u.SigningSecret == "" {
+		return errors.New("signature-header without a signing-secret")
+	}
+	return nil
+}
+
+func (u webhookURL) signatureHeader() string {
+	if u.SignatureHeader == "" {
+		return defaultSignatureHeader
+	}
+	return u.SignatureHeader
+}
+
+// Hex of the HMAC-SHA256 of body keyed by secret, as receivers recompute it
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Posted when an alert starts firing, once escalated and when it clears.
+// Key stays the same for all events of an alert
+type webhookEvent struct {
+	Event     string   `json:"event"`
+	Key       string   `json:"key"`
+	Chain     string   `json:"chain"`
+	Shard     string   `json:"shard"`
+	Check     string   `json:"check"`
+	Severity  string   `json:"severity"`
+	Subject   string   `json:"subject"`
+	Message   string   `json:"message"`
+	Value     string   `json:"value,omitempty"`
+	Threshold string   `json:"threshold,omitempty"`
+	Nodes     []string `json:"nodes"`
+	FirstSeen string   `json:"first-seen"`
+	Timestamp string   `json:"timestamp"`
+}
+
+const (
+	webhookTrigger = "trigger"
+	webhookResolve = "resolve"
+)
+
+// One per URL, so a failing URL is retried without posting again to the
+// others
+type webhookSender struct {
+	target  webhookURL
+	headers map[string]string
+	client  *http.Client
+}
+
+func (w *webhookSender) post(e webhookEvent) error {
+	if e.Nodes == nil {
+		e.Nodes = []string{}
+	}
+	payload, _ := json.Marshal(e)
+	req, err := http.NewRequest(http.MethodPost, w.target.URL, bytes.NewReader(payload))
+	if err != nil {
+		return permanent{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	// Signed last so a header of the same name cannot replace it
+	if w.target.SigningSecret != "" {
+		req.Header.Set(w.target.signatureHeader(), webhookSignature(w.target.SigningSecret, payload))
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return chatStatus("webhook", res)
+}
+
+func newWebhookEvent(event string, al alert, subject, message string) webhookEvent {
+	return webhookEvent{
+		Event: event, Key: al.Key, Chain: al.Chain, Shard: al.Shard, Check: al.Check,
+		Severity: al.Severity, Subject: subject, Message: strings.TrimSpace(message),
+		Value: al.Value, Threshold: al.Threshold, Nodes: al.Nodes,
+		FirstSeen: al.firstSeen.UTC().Format(time.RFC3339),
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+func (w *webhookSender) send(al alert, title, body string) error {
+	return w.post(newWebhookEvent(webhookTrigger, al, title, body))
+}
+
+// Tooling tracking the alert is told it cleared whatever notify-on-recovery
+func (w *webhookSender) close(al alert, note string) error {
+	return w.post(newWebhookEvent(webhookResolve, al, recoveredPrefix+al.Key, note))
+}
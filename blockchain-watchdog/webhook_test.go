@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// A receiver recording the body and signature header of the last post
+func webhookReceiver(t *testing.T, header string) (string, func() ([]byte, string)) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(header)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func() ([]byte, string) { return body, signature }
+}
+
+func TestWebhookSignedPerURL(t *testing.T) {
+	first, firstPost := webhookReceiver(t, defaultSignatureHeader)
+	second, secondPost := webhookReceiver(t, "X-Sig")
+	unsigned, unsignedPost := webhookReceiver(t, defaultSignatureHeader)
+	for _, target := range []webhookURL{
+		{URL: first, SigningSecret: "first-secret"},
+		// A header of the same name does not replace the signature
+		{URL: second, SigningSecret: "second-secret", SignatureHeader: "X-Sig"},
+		{URL: unsigned},
+	} {
+		w := &webhookSender{target, map[string]string{"X-Sig": "forged"}, &http.Client{}}
+		if err := w.send(alert{Key: "k", Shard: "0"}, "title", "body"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tc := range []struct {
+		post   func() ([]byte, string)
+		secret string
+	}{
+		{firstPost, "first-secret"},
+		{secondPost, "second-secret"},
+	} {
+		body, signature := tc.post()
+		mac := hmac.New(sha256.New, []byte(tc.secret))
+		mac.Write(body)
+		if want := hex.EncodeToString(mac.Sum(nil)); signature != want {
+			t.Errorf("signature %q, want %q keyed by %s", signature, want, tc.secret)
+		}
+	}
+	if body, signature := unsignedPost(); len(body) == 0 || signature != "" {
+		t.Errorf("entry without a secret posted %q signed %q", body, signature)
+	}
+}
+
+func TestWebhookURLCheck(t *testing.T) {
+	for _, tc := range []struct {
+		entry webhookURL
+		valid bool
+	}{
+		{webhookURL{URL: "https://incidents.example.com/hooks"}, true},
+		{webhookURL{URL: "https://incidents.example.com/hooks", SigningSecret: "s", SignatureHeader: "X-Sig"}, true},
+		{webhookURL{URL: "incidents.example.com/hooks"}, false},
+		{webhookURL{URL: "https://incidents.example.com/hooks", SignatureHeader: "X-Sig"}, false},
+	} {
+		if err := tc.entry.check(); (err == nil) != tc.valid {
+			t.Errorf("check of %+v: %v", tc.entry, err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "watchdog",
+			Name:      "worker_queue_depth",
+			Help:      "Jobs waiting for a worker, sampled whenever a worker picks up a job",
+		},
+		[]string{"pool"},
+	)
+	poolBusyWorkers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "watchdog",
+			Name:      "worker_busy",
+			Help:      "Workers currently running an RPC call",
+		},
+		[]string{"pool"},
+	)
+	poolShards = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "watchdog",
+			Name:      "worker_shards_in_flight",
+			Help:      "Shards whose inspection batch is currently being polled",
+		},
+		[]string{"pool"},
+	)
+	poolWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "watchdog",
+			Name:      "worker_queue_wait_seconds",
+			Help:      "Time jobs spent queued before a worker picked them up",
+			Buckets:   rpcLatencyBuckets,
+		},
+		[]string{"pool"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(poolQueueDepth, poolBusyWorkers, poolShards, poolWait)
+	rand.Seed(time.Now().UnixNano())
+}
+
+// Interval is the inspection interval of the pool, a queue that stays
+// non-empty for longer than that means num-workers is too small
+type workerPool struct {
+	Name        string  `json:"pool"`
+	Size        int     `json:"workers"`
+	Busy        int32   `json:"busy-workers"`
+	Shards      int32   `json:"shards-in-flight"`
+	QueueDepth  int     `json:"queue-depth"`
+	LastWait    float64 `json:"last-wait-seconds"`
+	interval    time.Duration
+	nonEmpty    time.Time
+	lastWarning time.Time
+	lock        sync.Mutex
+}
+
+func (m *monitor) startWorkers(name string, size, interval int, jobs chan work,
+	channels map[string](chan reply), groups map[string]*sync.WaitGroup,
+) {
+	p := &workerPool{Name: name, Size: size, interval: time.Duration(interval) * time.Second}
+	m.inUse.Lock()
+	if m.pools == nil {
+		m.pools = map[string]*workerPool{}
+	}
+	m.pools[name] = p
+	m.inUse.Unlock()
+	m.workers.Add(size)
+	for i := 0; i < size; i++ {
+		go m.worker(p, jobs, channels, groups)
+	}
+}
+
+// How long shutdown waits for the workers to drop their RPC calls
+const workersDrainTimeout = 5 * time.Second
+
+// Cancels the RPC calls in flight and waits for every worker to return,
+// queued jobs are left unpolled
+func (m *monitor) stopWorkers() {
+	if m.cancel == nil {
+		return
+	}
+	m.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		stdlog.Print("[stopWorkers] All workers stopped")
+	case <-time.After(workersDrainTimeout):
+		errlog.Printf("[stopWorkers] Workers not stopped after %s", workersDrainTimeout)
+	}
+}
+
+// Called by a worker right after it took a job off the queue
+func (p *workerPool) picked(queued time.Time, depth int) {
+	now := time.Now()
+	wait := now.Sub(queued)
+	poolQueueDepth.WithLabelValues(p.Name).Set(float64(depth))
+	poolWait.WithLabelValues(p.Name).Observe(wait.Seconds())
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.QueueDepth = depth
+	p.LastWait = wait.Seconds()
+	if depth == 0 {
+		p.nonEmpty = time.Time{}
+		return
+	}
+	if p.nonEmpty.IsZero() {
+		p.nonEmpty = now
+	}
+	if p.interval > 0 && now.Sub(p.nonEmpty) > p.interval && now.Sub(p.lastWarning) > p.interval {
+		p.lastWarning = now
+		stdlog.Printf("[workerPool] WARNING %s queue non-empty for %s, longer than its interval %s, consider raising num-workers",
+			p.Name, now.Sub(p.nonEmpty).Round(time.Second), p.interval,
+		)
+	}
+}
+
+func (p *workerPool) busy(delta int32) {
+	atomic.AddInt32(&p.Busy, delta)
+	poolBusyWorkers.WithLabelValues(p.Name).Add(float64(delta))
+}
+
+func (p *workerPool) shards(delta int32) {
+	atomic.AddInt32(&p.Shards, delta)
+	poolShards.WithLabelValues(p.Name).Add(float64(delta))
+}
+
+// Adds all jobs of the cycle to group before returning and queues them a
+// shard at a time, with at most max-concurrent-shards shards polled at once
+// across the pools. A shard keeps its slot until all its replies are in
+func (m *monitor) queueShards(ctx context.Context, pool string, jobs chan work, shardMap map[string]int,
+	rpc string, body []byte, interval int, group *sync.WaitGroup,
+) {
+	byShard := map[int][]string{}
+	for n, shard := range shardMap {
+		byShard[shard] = append(byShard[shard], n)
+	}
+	group.Add(len(shardMap))
+	m.inUse.Lock()
+	p, slots := m.pools[pool], m.shardSlots
+	m.inUse.Unlock()
+	// Shards are started by shard ID, the nodes of each by address
+	shards := []int{}
+	for shard := range byShard {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	for _, shard := range shards {
+		nodes := byShard[shard]
+		sort.Strings(nodes)
+		go func(shard int, nodes []string) {
+			if slots != nil {
+				slots <- struct{}{}
+				defer func() { <-slots }()
+			}
+			p.shards(1)
+			defer p.shards(-1)
+			ctx, s := m.tracer.start(ctx, "shard "+strconv.Itoa(shard), spanKindInternal,
+				"shard", strconv.Itoa(shard), "method", rpc, "nodes", strconv.Itoa(len(nodes)),
+			)
+			defer s.end(nil)
+			batch := sync.WaitGroup{}
+			batch.Add(len(nodes))
+			for _, n := range nodes {
+				queueAfter(m.ctx, jobs, work{n, rpc, body, time.Now(), batch.Done, ctx}, m.jitterDelay(interval))
+			}
+			batch.Wait()
+		}(shard, nodes)
+	}
+}
+
+func (m *monitor) poolsSnapshot() []workerPool {
+	m.inUse.Lock()
+	pools := make([]*workerPool, 0, len(m.pools))
+	for _, p := range m.pools {
+		pools = append(pools, p)
+	}
+	m.inUse.Unlock()
+	snapshot := []workerPool{}
+	for _, p := range pools {
+		p.lock.Lock()
+		snapshot = append(snapshot, workerPool{
+			Name:       p.Name,
+			Size:       p.Size,
+			Busy:       atomic.LoadInt32(&p.Busy),
+			Shards:     atomic.LoadInt32(&p.Shards),
+			QueueDepth: p.QueueDepth,
+			LastWait:   p.LastWait,
+		})
+		p.lock.Unlock()
+	}
+	sort.SliceStable(snapshot, func(i, j int) bool { return snapshot[i].Name < snapshot[j].Name })
+	return snapshot
+}
+
+// Jitter is capped so the cycle still has most of its interval to
+// collect the replies of the last delayed job
+const maxJitter = 50
+
+// Random delay within the first jitter percent of an interval in seconds
+func (m *monitor) jitterDelay(interval int) time.Duration {
+	if m.jitter <= 0 {
+		return 0
+	}
+	window := time.Duration(interval) * time.Second * time.Duration(m.jitter) / 100
+	return time.Duration(rand.Int63n(int64(window) + 1))
+}
+
+// The caller adds the job to its wait group before, so a delayed job is
+// still polled exactly once and waited for within its cycle. Once ctx is
+// cancelled the job is dropped instead of blocking on a queue nobody reads
+func queueAfter(ctx context.Context, jobs chan work, j work, delay time.Duration) {
+	send := func() {
+		select {
+		case jobs <- j:
+		case <-ctx.Done():
+		}
+	}
+	if delay <= 0 {
+		send()
+		return
+	}
+	time.AfterFunc(delay, func() {
+		j.queued = time.Now()
+		send()
+	})
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A node answering every RPC with result, or never answering at all
+// until the test ends when result is empty
+func fakeNode(t *testing.T, result string) string {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if result == "" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte(`{"jsonrpc":"2.0","id":"0","result":` + result + `}`))
+	}))
+	t.Cleanup(func() {
+		close(release)
+		server.Close()
+	})
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func testMonitor(t *testing.T, httpTimeout seconds) *monitor {
+	configureRPCClient(httpTimeout, "", "", "", "")
+	m := &monitor{chain: "testnet"}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	t.Cleanup(m.cancel)
+	return m
+}
+
+func TestWorkerPoolServesOthersWhileNodeHangs(t *testing.T) {
+	m := testMonitor(t, 2)
+	hanging, healthy := fakeNode(t, ""), fakeNode(t, "{}")
+	jobs := make(chan work, 16)
+	channels := map[string](chan reply){BlockHeaderRPC: make(chan reply, 16)}
+	groups := map[string]*sync.WaitGroup{BlockHeaderRPC: {}}
+	m.startWorkers("test", 2, 1, jobs, channels, groups)
+
+	groups[BlockHeaderRPC].Add(6)
+	queueAfter(m.ctx, jobs, work{hanging, BlockHeaderRPC, []byte(`{}`), time.Now(), nil, nil}, 0)
+	for i := 0; i < 5; i++ {
+		queueAfter(m.ctx, jobs, work{healthy, BlockHeaderRPC, []byte(`{}`), time.Now(), nil, nil}, 0)
+	}
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		select {
+		case r := <-channels[BlockHeaderRPC]:
+			if r.address != healthy || r.oops != nil {
+				t.Fatalf("reply %d from %s, error %v, want the healthy node's", i, r.address, r.oops)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of 5 healthy replies while a node hangs", i)
+		}
+	}
+	select {
+	case r := <-channels[BlockHeaderRPC]:
+		if r.address != hanging || r.oops == nil {
+			t.Fatalf("reply from %s, error %v, want the hanging node timed out", r.address, r.oops)
+		}
+		if took := time.Since(start); took > 3*time.Second {
+			t.Errorf("hanging node given up after %s, http-timeout is 2s", took)
+		}
+	case <-time.After(4 * time.Second):
+		t.Fatal("hanging node never given up")
+	}
+	groups[BlockHeaderRPC].Wait()
+}
+
+func TestWorkerPoolDrainsOnShutdown(t *testing.T) {
+	m := testMonitor(t, 30)
+	hanging := fakeNode(t, "")
+	jobs := make(chan work, 16)
+	channels := map[string](chan reply){BlockHeaderRPC: make(chan reply, 16)}
+	groups := map[string]*sync.WaitGroup{BlockHeaderRPC: {}}
+	m.startWorkers("test", 2, 1, jobs, channels, groups)
+
+	groups[BlockHeaderRPC].Add(2)
+	for i := 0; i < 2; i++ {
+		queueAfter(m.ctx, jobs, work{hanging, BlockHeaderRPC, []byte(`{}`), time.Now(), nil, nil}, 0)
+	}
+	// Both workers are now stuck on the node
+	deadline := time.Now().Add(time.Second)
+	for m.poolsSnapshot()[0].Busy < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	start := time.Now()
+	m.stopWorkers()
+	stopped := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("workers still running after stopWorkers")
+	}
+	if took := time.Since(start); took > workersDrainTimeout {
+		t.Errorf("shutdown took %s, the calls in flight were not cancelled", took)
+	}
+	for i := 0; i < 2; i++ {
+		if r := <-channels[BlockHeaderRPC]; r.oops == nil {
+			t.Errorf("cancelled call to %s succeeded", r.address)
+		}
+	}
+	// A job queued after shutdown is dropped rather than blocking
+	done := make(chan struct{})
+	go func() {
+		queueAfter(m.ctx, make(chan work), work{hanging, BlockHeaderRPC, nil, time.Now(), nil, nil}, 0)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("queueAfter blocked after shutdown")
+	}
+}
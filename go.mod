@@ -8,8 +8,12 @@ require (
 	github.com/ahmetb/go-linq v3.0.0+incompatible
 	github.com/ahmetb/go-linq/v3 v3.1.0 // indirect
 	github.com/google/go-querystring v1.0.0 // indirect
+	github.com/klauspost/cpuid v0.0.0-20180405133222-e7e905edc00e // indirect
+	github.com/prometheus/client_golang v1.11.1
 	github.com/spf13/cobra v0.0.5
 	github.com/takama/daemon v0.11.0
-	github.com/valyala/fasthttp v1.2.0
-	gopkg.in/yaml.v2 v2.2.7
+	github.com/valyala/fasthttp v1.16.0
+	google.golang.org/grpc v1.38.0
+	google.golang.org/protobuf v1.26.0
+	gopkg.in/yaml.v2 v2.3.0
 )